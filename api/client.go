@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"torrentflow/engine"
+)
+
+// Client talks to the remote control API of a running SeedRush instance
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// NewClient creates a client for the API at addr, which may be a bare
+// host:port or a full http(s) URL
+func NewClient(addr, token string) *Client {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	return &Client{
+		baseURL: strings.TrimSuffix(addr, "/"),
+		token:   token,
+		// Creating a torrent hashes every file, which can take a while
+		httpClient: &http.Client{Timeout: 10 * time.Minute},
+	}
+}
+
+// AddMagnet adds a torrent from a magnet link and returns its info hash
+func (c *Client) AddMagnet(magnet string) (string, error) {
+	var resp AddTorrentResponse
+	err := c.doJSON(http.MethodPost, "/api/torrents/magnet", AddMagnetRequest{Magnet: magnet}, &resp)
+	return resp.InfoHash, err
+}
+
+// AddTorrentFile uploads a .torrent file and returns its info hash
+func (c *Client) AddTorrentFile(torrentFile io.Reader) (string, error) {
+	var resp AddTorrentResponse
+	err := c.do(http.MethodPost, "/api/torrents/file", "application/x-bittorrent", torrentFile, &resp)
+	return resp.InfoHash, err
+}
+
+// CreateTorrent creates a torrent from files on the server's filesystem
+// and returns its magnet link
+func (c *Client) CreateTorrent(files []string) (string, error) {
+	var resp CreateTorrentResponse
+	err := c.doJSON(http.MethodPost, "/api/torrents/create", CreateTorrentRequest{Files: files}, &resp)
+	return resp.Magnet, err
+}
+
+// GetTorrents returns all torrents
+func (c *Client) GetTorrents() ([]engine.TorrentInfo, error) {
+	var torrents []engine.TorrentInfo
+	err := c.doJSON(http.MethodGet, "/api/torrents", nil, &torrents)
+	return torrents, err
+}
+
+// GetTorrent returns a single torrent by hash
+func (c *Client) GetTorrent(infoHash string) (engine.TorrentInfo, error) {
+	var info engine.TorrentInfo
+	err := c.doJSON(http.MethodGet, "/api/torrents/"+url.PathEscape(infoHash), nil, &info)
+	return info, err
+}
+
+// PauseTorrent pauses a torrent
+func (c *Client) PauseTorrent(infoHash string) error {
+	return c.doJSON(http.MethodPost, "/api/torrents/"+url.PathEscape(infoHash)+"/pause", nil, nil)
+}
+
+// ResumeTorrent resumes a torrent
+func (c *Client) ResumeTorrent(infoHash string) error {
+	return c.doJSON(http.MethodPost, "/api/torrents/"+url.PathEscape(infoHash)+"/resume", nil, nil)
+}
+
+// RemoveTorrent removes a torrent, optionally deleting its data or moving
+// it to the trash, and reports what happened to the files
+func (c *Client) RemoveTorrent(infoHash string, opts engine.RemoveOptions) (engine.RemoveReport, error) {
+	query := url.Values{}
+	query.Set("deleteFiles", strconv.FormatBool(opts.DeleteFiles))
+	query.Set("trash", strconv.FormatBool(opts.Trash))
+	var report engine.RemoveReport
+	err := c.doJSON(http.MethodDelete, "/api/torrents/"+url.PathEscape(infoHash)+"?"+query.Encode(), nil, &report)
+	return report, err
+}
+
+// GetStats returns global statistics
+func (c *Client) GetStats() (engine.Stats, error) {
+	var stats engine.Stats
+	err := c.doJSON(http.MethodGet, "/api/stats", nil, &stats)
+	return stats, err
+}
+
+func (c *Client) doJSON(method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	return c.do(method, path, "application/json", reader, out)
+}
+
+func (c *Client) do(method, path, contentType string, body io.Reader, out interface{}) error {
+	req, err := http.NewRequest(method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach SeedRush at %s: %w", c.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var apiErr ErrorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&apiErr); err == nil && apiErr.Error != "" {
+			if resp.StatusCode == http.StatusNotFound && apiErr.Error == engine.ErrTorrentNotFound.Error() {
+				return engine.ErrTorrentNotFound
+			}
+			return errors.New(apiErr.Error)
+		}
+		return fmt.Errorf("request failed: %s", resp.Status)
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
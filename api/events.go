@@ -0,0 +1,85 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// eventBufferSize is how many events a slow client may fall behind before
+// events are dropped for it
+const eventBufferSize = 64
+
+// keepAliveInterval keeps idle event streams open through proxies
+const keepAliveInterval = 15 * time.Second
+
+type streamEvent struct {
+	name string
+	data string
+}
+
+// handleEvents streams engine events as Server-Sent Events. The
+// torrents-update event carries the same payload the GUI receives.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	events := make(chan streamEvent, eventBufferSize)
+	unsubscribe := s.engine.Subscribe(func(name string, data ...interface{}) {
+		select {
+		case events <- streamEvent{name: name, data: encodeEventData(data)}:
+		default:
+			// Never block the engine on a slow client
+		}
+	})
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// Send the current state right away so clients don't wait for a change
+	snapshot, _ := json.Marshal(map[string]interface{}{
+		"torrents": s.engine.GetTorrents(),
+		"stats":    s.engine.GetStats(),
+	})
+	fmt.Fprintf(w, "event: torrents-update\ndata: %s\n\n", snapshot)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case ev := <-events:
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.name, ev.data)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// encodeEventData turns event arguments into a single line of data. The
+// torrents-update payload is already JSON and is passed through as-is.
+func encodeEventData(data []interface{}) string {
+	if len(data) == 1 {
+		if s, ok := data[0].(string); ok && json.Valid([]byte(s)) {
+			return s
+		}
+		encoded, _ := json.Marshal(data[0])
+		return string(encoded)
+	}
+	encoded, _ := json.Marshal(data)
+	return string(encoded)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"torrentflow/engine"
+
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// maxTorrentFileSize limits uploaded .torrent files
+const maxTorrentFileSize = 32 << 20
+
+func (s *Server) handleListTorrents(w http.ResponseWriter, r *http.Request) {
+	torrents := s.engine.GetTorrents()
+	if torrents == nil {
+		torrents = []engine.TorrentInfo{}
+	}
+	writeJSON(w, http.StatusOK, torrents)
+}
+
+func (s *Server) handleGetTorrent(w http.ResponseWriter, r *http.Request) {
+	info, err := s.engine.GetTorrent(r.PathValue("hash"))
+	if err != nil {
+		writeEngineError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (s *Server) handleAddMagnet(w http.ResponseWriter, r *http.Request) {
+	var req AddMagnetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	if req.Magnet == "" {
+		writeError(w, http.StatusBadRequest, errors.New("magnet is required"))
+		return
+	}
+
+	hash, err := s.engine.AddMagnet(req.Magnet, engine.AddOptions{SavePath: req.SavePath})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, AddTorrentResponse{InfoHash: hash})
+}
+
+// handleAddTorrentFile accepts a .torrent either as the raw request body or
+// as the "file" field of a multipart form. Pass ?savePath= to store it
+// outside the download directory.
+func (s *Server) handleAddTorrentFile(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxTorrentFileSize)
+
+	var body io.Reader = r.Body
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("missing torrent file: %w", err))
+			return
+		}
+		defer file.Close()
+		body = file
+	}
+
+	mi, err := metainfo.Load(body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to load torrent file: %w", err))
+		return
+	}
+
+	hash, err := s.engine.AddTorrentMetaInfo(mi, engine.AddOptions{SavePath: r.URL.Query().Get("savePath")})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, AddTorrentResponse{InfoHash: hash})
+}
+
+func (s *Server) handleCreateTorrent(w http.ResponseWriter, r *http.Request) {
+	var req CreateTorrentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+
+	magnet, err := s.engine.CreateTorrentFromFiles(req.Files)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, CreateTorrentResponse{Magnet: magnet})
+}
+
+func (s *Server) handlePauseTorrent(w http.ResponseWriter, r *http.Request) {
+	if err := s.engine.PauseTorrent(r.PathValue("hash")); err != nil {
+		writeEngineError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleResumeTorrent(w http.ResponseWriter, r *http.Request) {
+	if err := s.engine.ResumeTorrent(r.PathValue("hash")); err != nil {
+		writeEngineError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRemoveTorrent removes a torrent. Pass ?deleteFiles=true to also
+// delete its data, or ?trash=true to move it to the trash. The response
+// reports what happened to each file.
+func (s *Server) handleRemoveTorrent(w http.ResponseWriter, r *http.Request) {
+	var opts engine.RemoveOptions
+	var err error
+	if opts.DeleteFiles, err = queryBool(r, "deleteFiles"); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if opts.Trash, err = queryBool(r, "trash"); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	report, err := s.engine.RemoveTorrentWithOptions(r.PathValue("hash"), opts)
+	if err != nil {
+		writeEngineError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+// queryBool parses an optional boolean query parameter
+func queryBool(r *http.Request, name string) (bool, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(strings.TrimSpace(v))
+	if err != nil {
+		return false, fmt.Errorf("invalid %s value: %q", name, v)
+	}
+	return b, nil
+}
+
+func (s *Server) handleGetStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.engine.GetStats())
+}
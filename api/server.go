@@ -0,0 +1,146 @@
+// Package api exposes the torrent engine over a local HTTP/JSON API so
+// SeedRush can be driven from scripts, the command line and other machines.
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"torrentflow/config"
+	"torrentflow/engine"
+)
+
+// DefaultAddr is the address the API listens on unless configured otherwise
+const DefaultAddr = config.DefaultAPIAddr
+
+// Server serves the remote control API for an engine
+type Server struct {
+	engine     *engine.Engine
+	token      string
+	httpServer *http.Server
+	listener   net.Listener
+	done       chan struct{} // Closed when the server closes, ending event streams
+	closeOnce  sync.Once
+}
+
+// NewServer creates an API server for eng listening on addr. Every request
+// must carry token, either as a Bearer token or a "token" query parameter.
+func NewServer(eng *engine.Engine, addr, token string) *Server {
+	s := &Server{
+		engine: eng,
+		token:  token,
+		done:   make(chan struct{}),
+	}
+	s.httpServer = &http.Server{
+		Addr:              addr,
+		Handler:           s.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Start begins listening and serves requests in the background
+func (s *Server) Start() error {
+	if s.token == "" {
+		return errors.New("api token must not be empty")
+	}
+
+	ln, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	s.listener = ln
+
+	go func() {
+		if err := s.httpServer.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("❌ API server stopped: %v", err)
+		}
+	}()
+
+	log.Printf("✓ Remote API listening on http://%s", ln.Addr())
+	return nil
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() string {
+	if s.listener == nil {
+		return s.httpServer.Addr
+	}
+	return s.listener.Addr().String()
+}
+
+// Close stops the server, waiting briefly for in-flight requests. Event
+// streams are ended first as Shutdown would wait for them.
+func (s *Server) Close() error {
+	s.closeOnce.Do(func() { close(s.done) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.httpServer.Shutdown(ctx)
+}
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/torrents", s.handleListTorrents)
+	mux.HandleFunc("GET /api/torrents/{hash}", s.handleGetTorrent)
+	mux.HandleFunc("POST /api/torrents/magnet", s.handleAddMagnet)
+	mux.HandleFunc("POST /api/torrents/file", s.handleAddTorrentFile)
+	mux.HandleFunc("POST /api/torrents/create", s.handleCreateTorrent)
+	mux.HandleFunc("POST /api/torrents/{hash}/pause", s.handlePauseTorrent)
+	mux.HandleFunc("POST /api/torrents/{hash}/resume", s.handleResumeTorrent)
+	mux.HandleFunc("DELETE /api/torrents/{hash}", s.handleRemoveTorrent)
+	mux.HandleFunc("GET /api/stats", s.handleGetStats)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests that do not carry the API token
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			// EventSource cannot set headers, so allow the query string
+			token = r.URL.Query().Get("token")
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid or missing api token"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("⚠ Failed to write API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, ErrorResponse{Error: err.Error()})
+}
+
+// writeEngineError maps engine errors to HTTP status codes
+func writeEngineError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, engine.ErrTorrentNotFound):
+		writeError(w, http.StatusNotFound, err)
+		return
+	case errors.Is(err, engine.ErrTorrentBusy):
+		writeError(w, http.StatusConflict, err)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
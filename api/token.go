@@ -0,0 +1,57 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"torrentflow/config"
+)
+
+// LoadOrCreateToken reads the API token from path, generating and saving a
+// random one if the file does not exist yet. The file is only readable by
+// the current user.
+func LoadOrCreateToken(path string) (string, error) {
+	token, err := ReadToken(path)
+	if err == nil {
+		return token, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate api token: %w", err)
+	}
+	token = hex.EncodeToString(buf)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create token directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to save api token: %w", err)
+	}
+	return token, nil
+}
+
+// ReadToken reads an API token saved by LoadOrCreateToken
+func ReadToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("api token file %s is empty", path)
+	}
+	return token, nil
+}
+
+// DefaultTokenPath returns where a generated API token is stored
+func DefaultTokenPath() string {
+	return filepath.Join(config.Dir(), "api-token")
+}
@@ -0,0 +1,28 @@
+package api
+
+// AddMagnetRequest is the body of POST /api/torrents/magnet
+type AddMagnetRequest struct {
+	Magnet string `json:"magnet"`
+	// SavePath overrides the download directory for this torrent
+	SavePath string `json:"savePath,omitempty"`
+}
+
+// CreateTorrentRequest is the body of POST /api/torrents/create
+type CreateTorrentRequest struct {
+	Files []string `json:"files"`
+}
+
+// AddTorrentResponse is returned when a torrent has been added
+type AddTorrentResponse struct {
+	InfoHash string `json:"infoHash"`
+}
+
+// CreateTorrentResponse is returned when a torrent has been created
+type CreateTorrentResponse struct {
+	Magnet string `json:"magnet"`
+}
+
+// ErrorResponse is returned for every failed request
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
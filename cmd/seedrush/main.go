@@ -0,0 +1,355 @@
+// Command seedrush controls a running SeedRush instance through its remote
+// control API.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"torrentflow/api"
+	"torrentflow/engine"
+)
+
+const usage = `Usage: seedrush <command> [flags] [args]
+
+Commands:
+  add <magnet|file>           Add a torrent from a magnet link or .torrent file
+  create <paths...>           Create a torrent from local files and seed it
+  list                        List all torrents
+  info <hash>                 Show details for a torrent
+  pause <hash>                Pause a torrent
+  resume <hash>               Resume a torrent
+  rm [--delete-files|--trash] <hash>
+                              Remove a torrent, deleting or trashing its data
+  stats                       Show global statistics
+
+Common flags:
+  --addr <host:port>          API address (default %s, or $SEEDRUSH_API_ADDR)
+  --token <token>             API token (default $SEEDRUSH_API_TOKEN or the saved token)
+  --json                      Print JSON instead of a table
+
+A hash may be abbreviated to any unique prefix.
+`
+
+// options holds flags shared by every command
+type options struct {
+	addr        string
+	token       string
+	asJSON      bool
+	deleteFiles bool // rm only
+	trash       bool // rm only
+}
+
+type command func(client *api.Client, opts options, args []string) error
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "-h" || os.Args[1] == "--help" || os.Args[1] == "help" {
+		fmt.Fprintf(os.Stderr, usage, api.DefaultAddr)
+		os.Exit(2)
+	}
+
+	if err := run(os.Args[1], os.Args[2:]); err != nil {
+		fmt.Fprintf(os.Stderr, "seedrush: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(name string, args []string) error {
+	commands := map[string]command{
+		"add":    cmdAdd,
+		"create": cmdCreate,
+		"list":   cmdList,
+		"info":   cmdInfo,
+		"pause":  cmdPause,
+		"resume": cmdResume,
+		"rm":     cmdRemove,
+		"stats":  cmdStats,
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q (see seedrush --help)", name)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	var opts options
+	defaultAddr := os.Getenv("SEEDRUSH_API_ADDR")
+	if defaultAddr == "" {
+		defaultAddr = api.DefaultAddr
+	}
+	fs.StringVar(&opts.addr, "addr", defaultAddr, "API address")
+	fs.StringVar(&opts.token, "token", os.Getenv("SEEDRUSH_API_TOKEN"), "API token")
+	fs.BoolVar(&opts.asJSON, "json", false, "print JSON instead of a table")
+	if name == "rm" {
+		fs.BoolVar(&opts.deleteFiles, "delete-files", false, "also delete downloaded data")
+		fs.BoolVar(&opts.trash, "trash", false, "move downloaded data to the trash")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if opts.token == "" {
+		token, err := api.ReadToken(api.DefaultTokenPath())
+		if err != nil {
+			return fmt.Errorf("no api token given and none saved: %w", err)
+		}
+		opts.token = token
+	}
+
+	client := api.NewClient(opts.addr, opts.token)
+	return cmd(client, opts, fs.Args())
+}
+
+func cmdAdd(client *api.Client, opts options, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: seedrush add <magnet|file>")
+	}
+
+	var hash string
+	var err error
+	if strings.HasPrefix(args[0], "magnet:") {
+		hash, err = client.AddMagnet(args[0])
+	} else {
+		f, openErr := os.Open(args[0])
+		if openErr != nil {
+			return openErr
+		}
+		defer f.Close()
+		hash, err = client.AddTorrentFile(f)
+	}
+	if err != nil {
+		return err
+	}
+
+	if opts.asJSON {
+		return printJSON(api.AddTorrentResponse{InfoHash: hash})
+	}
+	fmt.Printf("Added %s\n", hash)
+	return nil
+}
+
+func cmdCreate(client *api.Client, opts options, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: seedrush create <paths...>")
+	}
+
+	// The instance may run in a different working directory
+	files := make([]string, len(args))
+	for i, path := range args {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return err
+		}
+		files[i] = abs
+	}
+
+	magnet, err := client.CreateTorrent(files)
+	if err != nil {
+		return err
+	}
+
+	if opts.asJSON {
+		return printJSON(api.CreateTorrentResponse{Magnet: magnet})
+	}
+	fmt.Println(magnet)
+	return nil
+}
+
+func cmdList(client *api.Client, opts options, args []string) error {
+	torrents, err := client.GetTorrents()
+	if err != nil {
+		return err
+	}
+	if torrents == nil {
+		torrents = []engine.TorrentInfo{}
+	}
+
+	if opts.asJSON {
+		return printJSON(torrents)
+	}
+
+	w := newTable()
+	fmt.Fprintln(w, "HASH\tNAME\tSIZE\tDONE\tSTATUS\tDOWN\tUP\tPEERS\tETA")
+	for _, t := range torrents {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.1f%%\t%s\t%s\t%s\t%d\t%s\n",
+			shortHash(t.InfoHash), t.Name, t.SizeStr, t.Progress, t.Status,
+			t.DownloadedStr, t.UploadedStr, t.Peers, t.ETA)
+	}
+	return w.Flush()
+}
+
+func cmdInfo(client *api.Client, opts options, args []string) error {
+	hash, err := resolveHash(client, args)
+	if err != nil {
+		return err
+	}
+
+	t, err := client.GetTorrent(hash)
+	if err != nil {
+		return err
+	}
+
+	if opts.asJSON {
+		return printJSON(t)
+	}
+
+	w := newTable()
+	fmt.Fprintf(w, "Name:\t%s\n", t.Name)
+	fmt.Fprintf(w, "Hash:\t%s\n", t.InfoHash)
+	fmt.Fprintf(w, "Status:\t%s\n", t.Status)
+	fmt.Fprintf(w, "Size:\t%s\n", t.SizeStr)
+	fmt.Fprintf(w, "Progress:\t%.1f%%\n", t.Progress)
+	fmt.Fprintf(w, "Speed:\t%s down, %s up\n", t.DownloadedStr, t.UploadedStr)
+	fmt.Fprintf(w, "Peers:\t%d (%d seeds)\n", t.Peers, t.Seeds)
+	fmt.Fprintf(w, "ETA:\t%s\n", t.ETA)
+	fmt.Fprintf(w, "Ratio:\t%.2f\n", t.Ratio)
+	fmt.Fprintf(w, "Added:\t%s\n", t.AddedAt.Local().Format("2006-01-02 15:04:05"))
+	if !t.CompletedAt.IsZero() {
+		fmt.Fprintf(w, "Completed:\t%s\n", t.CompletedAt.Local().Format("2006-01-02 15:04:05"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(t.Files) > 0 {
+		fmt.Println()
+		w = newTable()
+		fmt.Fprintln(w, "FILE\tSIZE\tDONE")
+		for _, f := range t.Files {
+			fmt.Fprintf(w, "%s\t%s\t%.1f%%\n", f.Path, f.SizeStr, f.Progress)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+func cmdPause(client *api.Client, opts options, args []string) error {
+	hash, err := resolveHash(client, args)
+	if err != nil {
+		return err
+	}
+	if err := client.PauseTorrent(hash); err != nil {
+		return err
+	}
+	return printDone(opts, "Paused", hash)
+}
+
+func cmdResume(client *api.Client, opts options, args []string) error {
+	hash, err := resolveHash(client, args)
+	if err != nil {
+		return err
+	}
+	if err := client.ResumeTorrent(hash); err != nil {
+		return err
+	}
+	return printDone(opts, "Resumed", hash)
+}
+
+func cmdRemove(client *api.Client, opts options, args []string) error {
+	hash, err := resolveHash(client, args)
+	if err != nil {
+		return err
+	}
+	report, err := client.RemoveTorrent(hash, engine.RemoveOptions{DeleteFiles: opts.deleteFiles, Trash: opts.trash})
+	if err != nil {
+		return err
+	}
+
+	if opts.asJSON {
+		return printJSON(report)
+	}
+	fmt.Printf("Removed %s\n", hash)
+	if opts.deleteFiles || opts.trash {
+		verb := "Deleted"
+		if report.Trashed {
+			verb = "Trashed"
+		}
+		fmt.Printf("%s %d files, %d missing\n", verb, len(report.Removed), len(report.Missing))
+		for _, path := range report.Refused {
+			fmt.Printf("Refused (outside %s): %s\n", report.StorageRoot, path)
+		}
+		for _, msg := range report.Errors {
+			fmt.Printf("Failed: %s\n", msg)
+		}
+	}
+	return nil
+}
+
+func cmdStats(client *api.Client, opts options, args []string) error {
+	stats, err := client.GetStats()
+	if err != nil {
+		return err
+	}
+
+	if opts.asJSON {
+		return printJSON(stats)
+	}
+
+	w := newTable()
+	fmt.Fprintf(w, "Download:\t%s\n", stats.TotalDownloadSpeed)
+	fmt.Fprintf(w, "Upload:\t%s\n", stats.TotalUploadSpeed)
+	fmt.Fprintf(w, "Active torrents:\t%d\n", stats.ActiveTorrents)
+	fmt.Fprintf(w, "Peers:\t%d\n", stats.TotalPeers)
+	return w.Flush()
+}
+
+// resolveHash expands a unique hash prefix to the full info hash
+func resolveHash(client *api.Client, args []string) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("expected exactly one torrent hash")
+	}
+	prefix := strings.ToLower(args[0])
+	if len(prefix) == 40 {
+		return prefix, nil
+	}
+
+	torrents, err := client.GetTorrents()
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, t := range torrents {
+		if strings.HasPrefix(t.InfoHash, prefix) {
+			matches = append(matches, t.InfoHash)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", engine.ErrTorrentNotFound
+	case 1:
+		return matches[0], nil
+	default:
+		return "", fmt.Errorf("hash prefix %q is ambiguous", args[0])
+	}
+}
+
+func printDone(opts options, action, hash string) error {
+	if opts.asJSON {
+		return printJSON(map[string]string{"infoHash": hash})
+	}
+	fmt.Printf("%s %s\n", action, hash)
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 8 {
+		return hash[:8]
+	}
+	return hash
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// ParseIPRange parses a banned address given as a single IP, a CIDR
+// prefix ("10.0.0.0/8") or an inclusive range ("10.0.0.1-10.0.0.9") and
+// returns the first and last address it covers
+func ParseIPRange(s string) (first, last netip.Addr, err error) {
+	s = strings.TrimSpace(s)
+	if from, to, ok := strings.Cut(s, "-"); ok {
+		first, err = netip.ParseAddr(strings.TrimSpace(from))
+		if err == nil {
+			last, err = netip.ParseAddr(strings.TrimSpace(to))
+		}
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("%q is not an IP range", s)
+		}
+		first, last = first.Unmap(), last.Unmap()
+		if first.Is4() != last.Is4() || last.Less(first) {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("%q is not an IP range", s)
+		}
+		return first, last, nil
+	}
+
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Addr{}, netip.Addr{}, fmt.Errorf("%q is not a CIDR prefix", s)
+		}
+		prefix = prefix.Masked()
+		first = prefix.Addr().Unmap()
+		last = first
+		for bits := prefix.Bits(); bits < first.BitLen(); bits++ {
+			last = setBit(last, bits)
+		}
+		return first, last, nil
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, fmt.Errorf("%q is not an IP address", s)
+	}
+	addr = addr.Unmap()
+	return addr, addr, nil
+}
+
+// setBit sets bit i of addr, counting from the most significant bit
+func setBit(addr netip.Addr, i int) netip.Addr {
+	b := addr.AsSlice()
+	b[i/8] |= 0x80 >> (i % 8)
+	addr, _ = netip.AddrFromSlice(b)
+	return addr
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Speed limit profiles a schedule rule can switch to
+const (
+	ProfileNormal      = "normal"      // Bandwidth.DownloadLimit / UploadLimit
+	ProfileAlternative = "alternative" // Bandwidth.AltDownloadLimit / AltUploadLimit
+	ProfileUnlimited   = "unlimited"   // No global limits
+)
+
+// ScheduleSettings switches speed profiles on a weekly timetable
+type ScheduleSettings struct {
+	Enabled bool `json:"enabled"`
+	// Rules are checked in order and the first one that matches wins.
+	// Outside every rule the manual alternative speed toggle applies.
+	Rules []ScheduleRule `json:"rules"`
+}
+
+// ScheduleRule applies a speed profile during a daily time window
+type ScheduleRule struct {
+	// Days the window starts on ("mon" … "sun"), empty for every day
+	Days []string `json:"days"`
+	// Start and End are "HH:MM" in local time. A window whose end is
+	// before its start runs past midnight; equal times cover the whole day.
+	Start   string `json:"start"`
+	End     string `json:"end"`
+	Profile string `json:"profile"`
+	// PauseAll pauses every running torrent for the length of the window
+	PauseAll bool `json:"pauseAll"`
+}
+
+var weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Active returns the first rule matching t, or nil outside every rule
+func (s ScheduleSettings) Active(t time.Time) *ScheduleRule {
+	if !s.Enabled {
+		return nil
+	}
+	for i := range s.Rules {
+		if s.Rules[i].Matches(t) {
+			return &s.Rules[i]
+		}
+	}
+	return nil
+}
+
+// Matches reports whether t falls inside the rule's window
+func (r ScheduleRule) Matches(t time.Time) bool {
+	start, err := parseClock(r.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(r.End)
+	if err != nil {
+		return false
+	}
+
+	now := t.Hour()*60 + t.Minute()
+	today := t.Weekday()
+	yesterday := (today + 6) % 7
+
+	switch {
+	case start == end:
+		return r.onDay(today)
+	case start < end:
+		return r.onDay(today) && now >= start && now < end
+	default:
+		// Window wraps past midnight into the next day
+		return (r.onDay(today) && now >= start) || (r.onDay(yesterday) && now < end)
+	}
+}
+
+func (r ScheduleRule) onDay(day time.Weekday) bool {
+	if len(r.Days) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(r.Days, func(d string) bool {
+		return strings.EqualFold(d, weekdays[day])
+	})
+}
+
+func (r ScheduleRule) validate() error {
+	for _, day := range r.Days {
+		if !slices.Contains(weekdays, strings.ToLower(day)) {
+			return fmt.Errorf("unknown day %q", day)
+		}
+	}
+	if _, err := parseClock(r.Start); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	if _, err := parseClock(r.End); err != nil {
+		return fmt.Errorf("end: %w", err)
+	}
+	switch r.Profile {
+	case ProfileNormal, ProfileAlternative, ProfileUnlimited:
+	default:
+		return fmt.Errorf("unknown profile %q", r.Profile)
+	}
+	return nil
+}
+
+func (s ScheduleSettings) clone() ScheduleSettings {
+	rules := make([]ScheduleRule, len(s.Rules))
+	for i, rule := range s.Rules {
+		rule.Days = append([]string(nil), rule.Days...)
+		rules[i] = rule
+	}
+	s.Rules = rules
+	return s
+}
+
+// parseClock parses "HH:MM" into minutes since midnight
+func parseClock(v string) (int, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a HH:MM time", v)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
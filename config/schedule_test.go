@@ -0,0 +1,69 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleRuleMatches(t *testing.T) {
+	// 2024-01-01 is a Monday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.Local)
+	}
+	night := ScheduleRule{Days: []string{"mon"}, Start: "22:00", End: "06:00"}
+
+	tests := []struct {
+		name string
+		rule ScheduleRule
+		t    time.Time
+		want bool
+	}{
+		{"overnight, before start", night, at(1, 21, 59), false},
+		{"overnight, at start", night, at(1, 22, 0), true},
+		{"overnight, before midnight", night, at(1, 23, 59), true},
+		{"overnight, next day after midnight", night, at(2, 0, 0), true},
+		{"overnight, next day before end", night, at(2, 5, 59), true},
+		{"overnight, next day at end", night, at(2, 6, 0), false},
+		{"overnight, next day at start", night, at(2, 22, 0), false},
+		{"overnight, morning of the start day", night, at(1, 3, 0), false},
+		{"overnight, Saturday into Sunday", ScheduleRule{Days: []string{"sat"}, Start: "22:00", End: "06:00"}, at(7, 1, 0), true},
+		{"overnight, every day", ScheduleRule{Start: "22:00", End: "06:00"}, at(3, 1, 0), true},
+
+		{"empty days, inside", ScheduleRule{Start: "09:00", End: "17:00"}, at(6, 9, 0), true},
+		{"empty days, at end", ScheduleRule{Start: "09:00", End: "17:00"}, at(6, 17, 0), false},
+		{"other day", ScheduleRule{Days: []string{"tue", "wed"}, Start: "09:00", End: "17:00"}, at(1, 12, 0), false},
+		{"day names ignore case", ScheduleRule{Days: []string{"Mon"}, Start: "09:00", End: "17:00"}, at(1, 12, 0), true},
+
+		{"start equals end, whole day", ScheduleRule{Days: []string{"mon"}, Start: "08:00", End: "08:00"}, at(1, 0, 0), true},
+		{"start equals end, late", ScheduleRule{Days: []string{"mon"}, Start: "08:00", End: "08:00"}, at(1, 23, 59), true},
+		{"start equals end, next day", ScheduleRule{Days: []string{"mon"}, Start: "08:00", End: "08:00"}, at(2, 7, 0), false},
+		{"start equals end, every day", ScheduleRule{Start: "00:00", End: "00:00"}, at(4, 12, 0), true},
+
+		{"bad start", ScheduleRule{Start: "25:00", End: "06:00"}, at(1, 1, 0), false},
+		{"bad end", ScheduleRule{Start: "22:00", End: "6"}, at(1, 23, 0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.Matches(tt.t); got != tt.want {
+			t.Errorf("%s: Matches(%s) = %v, want %v", tt.name, tt.t.Format("Mon 15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestScheduleActive(t *testing.T) {
+	rules := []ScheduleRule{
+		{Start: "09:00", End: "17:00", Profile: ProfileAlternative},
+		{Start: "00:00", End: "00:00", Profile: ProfileUnlimited},
+	}
+	noon := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+
+	if rule := (ScheduleSettings{Rules: rules}).Active(noon); rule != nil {
+		t.Errorf("disabled schedule is active with %+v", rule)
+	}
+	s := ScheduleSettings{Enabled: true, Rules: rules}
+	if rule := s.Active(noon); rule == nil || rule.Profile != ProfileAlternative {
+		t.Errorf("Active at noon = %+v, want the first rule", rule)
+	}
+	if rule := s.Active(noon.Add(6 * time.Hour)); rule == nil || rule.Profile != ProfileUnlimited {
+		t.Errorf("Active in the evening = %+v, want the second rule", rule)
+	}
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Piece length limits for created torrents
+const (
+	minPieceLength = 16 * 1024
+	maxPieceLength = 16 * 1024 * 1024
+)
+
+// Validate checks that the settings can be used to start the engine
+func (s Settings) Validate() error {
+	var errs []error
+
+	if s.DownloadDir == "" || !filepath.IsAbs(s.DownloadDir) {
+		errs = append(errs, fmt.Errorf("downloadDir must be an absolute path"))
+	}
+	if s.StateFile == "" || !filepath.IsAbs(s.StateFile) {
+		errs = append(errs, fmt.Errorf("stateFile must be an absolute path"))
+	}
+
+	if s.ListenPort < 0 || s.ListenPort > 65535 {
+		errs = append(errs, fmt.Errorf("listenPort %d is out of range", s.ListenPort))
+	}
+
+	if !s.EnableTCP && !s.EnableUTP {
+		errs = append(errs, fmt.Errorf("at least one of enableTcp and enableUtp must be set"))
+	}
+	switch s.Encryption {
+	case EncryptionRequire, EncryptionPrefer, EncryptionDisable:
+	default:
+		errs = append(errs, fmt.Errorf("unknown encryption policy %q", s.Encryption))
+	}
+
+	if strings.TrimSpace(s.BindInterface) != s.BindInterface {
+		errs = append(errs, fmt.Errorf("bindInterface %q must not have surrounding spaces", s.BindInterface))
+	}
+
+	if s.PieceLength < minPieceLength || s.PieceLength > maxPieceLength || s.PieceLength&(s.PieceLength-1) != 0 {
+		errs = append(errs, fmt.Errorf("pieceLength must be a power of two between %d and %d bytes", minPieceLength, maxPieceLength))
+	}
+
+	for _, tracker := range s.Trackers {
+		if err := validateTracker(tracker); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	for _, ban := range s.BannedPeers {
+		if _, _, err := ParseIPRange(ban); err != nil {
+			errs = append(errs, fmt.Errorf("bannedPeers: %w", err))
+		}
+	}
+
+	for _, path := range s.Blocklists {
+		if !filepath.IsAbs(path) {
+			errs = append(errs, fmt.Errorf("blocklist %q must be an absolute path", path))
+		}
+	}
+
+	for name, limit := range map[string]int64{
+		"bandwidth.downloadLimit":    s.Bandwidth.DownloadLimit,
+		"bandwidth.uploadLimit":      s.Bandwidth.UploadLimit,
+		"bandwidth.altDownloadLimit": s.Bandwidth.AltDownloadLimit,
+		"bandwidth.altUploadLimit":   s.Bandwidth.AltUploadLimit,
+	} {
+		if limit < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative", name))
+		}
+	}
+
+	if s.Queue.MaxActiveDownloads < 0 {
+		errs = append(errs, fmt.Errorf("queue.maxActiveDownloads must not be negative"))
+	}
+	if s.Queue.MaxActiveSeeds < 0 {
+		errs = append(errs, fmt.Errorf("queue.maxActiveSeeds must not be negative"))
+	}
+
+	if err := s.Seeding.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("seeding: %w", err))
+	}
+
+	for i, rule := range s.Schedule.Rules {
+		if err := rule.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("schedule rule %d: %w", i+1, err))
+		}
+	}
+
+	if err := s.Proxy.Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("proxy: %w", err))
+	}
+
+	if s.API.Addr != "" {
+		if _, _, err := net.SplitHostPort(s.API.Addr); err != nil {
+			errs = append(errs, fmt.Errorf("api.addr %q is not a host:port address", s.API.Addr))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate checks that goals are not negative and the action is known
+func (g SeedGoals) Validate() error {
+	var errs []error
+	if g.Ratio < 0 {
+		errs = append(errs, fmt.Errorf("ratio must not be negative"))
+	}
+	if g.MinSeedMinutes < 0 || g.MaxSeedMinutes < 0 || g.IdleMinutes < 0 {
+		errs = append(errs, fmt.Errorf("seed times must not be negative"))
+	}
+	if g.MaxSeedMinutes > 0 && g.MaxSeedMinutes < g.MinSeedMinutes {
+		errs = append(errs, fmt.Errorf("maxSeedMinutes must not be below minSeedMinutes"))
+	}
+	switch g.Action {
+	case SeedActionPause, SeedActionRemove, SeedActionRemoveData:
+	default:
+		errs = append(errs, fmt.Errorf("unknown action %q", g.Action))
+	}
+	return errors.Join(errs...)
+}
+
+// Validate checks that an enabled proxy has a known type and an address
+func (p ProxySettings) Validate() error {
+	switch p.Type {
+	case "", ProxyNone:
+		return nil
+	case ProxySOCKS5, ProxyHTTP:
+	default:
+		return fmt.Errorf("unknown type %q", p.Type)
+	}
+
+	var errs []error
+	if p.Host == "" {
+		errs = append(errs, fmt.Errorf("host must be set"))
+	}
+	if p.Port < 1 || p.Port > 65535 {
+		errs = append(errs, fmt.Errorf("port %d is out of range", p.Port))
+	}
+	if p.Password != "" && p.Username == "" {
+		errs = append(errs, fmt.Errorf("a password needs a username"))
+	}
+	return errors.Join(errs...)
+}
+
+func validateTracker(tracker string) error {
+	u, err := url.Parse(tracker)
+	if err != nil {
+		return fmt.Errorf("tracker %q is not a valid URL", tracker)
+	}
+	switch u.Scheme {
+	case "udp", "http", "https", "ws", "wss":
+	default:
+		return fmt.Errorf("tracker %q has unsupported scheme %q", tracker, u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("tracker %q has no host", tracker)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package engine
+
+import (
+	"log"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"golang.org/x/time/rate"
+)
+
+// minLimiterBurst is the smallest burst given to the global limiters. The
+// client rejects peer requests larger than the upload burst and reads at
+// most a burst at a time, so it has to stay well above the 16 KiB chunk
+// size.
+const minLimiterBurst = 256 << 10
+
+// throttleInterval is how often per-torrent limits are enforced
+const throttleInterval = 200 * time.Millisecond
+
+// torrentThrottle enforces a per-torrent limit as a token bucket. The
+// client has no per-torrent limiter, so a torrent that used up its bucket
+// has data transfer disallowed until the bucket refills.
+type torrentThrottle struct {
+	downTokens  float64
+	upTokens    float64
+	lastRead    int64
+	lastWritten int64
+	downBlocked bool
+	upBlocked   bool
+}
+
+// newRateLimiter returns an unlimited limiter for the client config
+func newRateLimiter() *rate.Limiter {
+	return rate.NewLimiter(rate.Inf, minLimiterBurst)
+}
+
+// setRateLimit changes a limiter to bytesPerSec, 0 meaning unlimited
+func setRateLimit(l *rate.Limiter, bytesPerSec int64) {
+	if bytesPerSec <= 0 {
+		l.SetLimit(rate.Inf)
+		return
+	}
+	burst := int(bytesPerSec)
+	if burst < minLimiterBurst {
+		burst = minLimiterBurst
+	}
+	l.SetBurst(burst)
+	l.SetLimit(rate.Limit(bytesPerSec))
+}
+
+// applyGlobalLimits updates the client's limiters to the active profile
+func (e *Engine) applyGlobalLimits() {
+	profile := e.speedProfile()
+	down, up := e.Settings().Bandwidth.Limits(profile)
+	setRateLimit(e.downloadLimiter, down)
+	setRateLimit(e.uploadLimiter, up)
+
+	log.Printf("✓ Using %s speed limits: %s down, %s up", profile, formatLimit(down), formatLimit(up))
+}
+
+// SetTorrentLimits sets the download and upload limits of a single torrent
+// in bytes per second, 0 meaning no limit beyond the global one
+func (e *Engine) SetTorrentLimits(infoHash string, downloadLimit, uploadLimit int64) error {
+	e.torrentsMutex.RLock()
+	t, exists := e.torrents[infoHash]
+	e.torrentsMutex.RUnlock()
+
+	if !exists {
+		return ErrTorrentNotFound
+	}
+
+	if downloadLimit < 0 {
+		downloadLimit = 0
+	}
+	if uploadLimit < 0 {
+		uploadLimit = 0
+	}
+
+	e.sessionsMutex.Lock()
+	if session, ok := e.sessions[infoHash]; ok {
+		session.downloadLimit = downloadLimit
+		session.uploadLimit = uploadLimit
+	}
+	e.sessionsMutex.Unlock()
+
+	e.saveTorrentStates()
+
+	log.Printf("✓ Speed limits for %s: %s down, %s up", t.Name(), formatLimit(downloadLimit), formatLimit(uploadLimit))
+	e.emit("torrent-updated", infoHash)
+	return nil
+}
+
+// throttleLoop enforces per-torrent limits until the engine is closed
+func (e *Engine) throttleLoop() {
+	ticker := time.NewTicker(throttleInterval)
+	defer ticker.Stop()
+
+	throttles := make(map[string]*torrentThrottle)
+	lastTick := time.Now()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		elapsed := now.Sub(lastTick).Seconds()
+		lastTick = now
+
+		e.torrentsMutex.RLock()
+		for hash := range throttles {
+			if _, ok := e.torrents[hash]; !ok {
+				delete(throttles, hash)
+			}
+		}
+		for hash, t := range e.torrents {
+			session := e.getSession(hash)
+			if session.busy() || e.isPaused(hash) {
+				// The torrent may be re-added and count from zero after a
+				// move or recheck, or once resumed
+				delete(throttles, hash)
+				continue
+			}
+			th, ok := throttles[hash]
+			if session.downloadLimit == 0 && session.uploadLimit == 0 {
+				if ok {
+					e.releaseThrottle(hash, t, th)
+					delete(throttles, hash)
+				}
+				continue
+			}
+
+			stats := t.Stats()
+			read, written := stats.BytesReadData.Int64(), stats.BytesWrittenData.Int64()
+			if !ok {
+				th = &torrentThrottle{lastRead: read, lastWritten: written}
+				throttles[hash] = th
+			}
+
+			th.downTokens = refill(th.downTokens, session.downloadLimit, elapsed) - float64(read-th.lastRead)
+			th.upTokens = refill(th.upTokens, session.uploadLimit, elapsed) - float64(written-th.lastWritten)
+			th.lastRead, th.lastWritten = read, written
+
+			e.setThrottled(hash, t, th, session.downloadLimit > 0 && th.downTokens < 0, session.uploadLimit > 0 && th.upTokens < 0)
+		}
+		e.torrentsMutex.RUnlock()
+	}
+}
+
+// refill adds tokens for elapsed seconds, holding at most one second of
+// transfer so a torrent cannot save up for a burst
+func refill(tokens float64, limit int64, elapsed float64) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	tokens += float64(limit) * elapsed
+	if tokens > float64(limit) {
+		tokens = float64(limit)
+	}
+	return tokens
+}
+
+// setThrottled blocks or unblocks data transfer for a throttled torrent.
+// Paused and queued torrents are left alone so the throttle never starts
+// them.
+func (e *Engine) setThrottled(hash string, t *torrent.Torrent, th *torrentThrottle, blockDown, blockUp bool) {
+	isStopped := e.isStopped(hash)
+
+	if blockDown != th.downBlocked {
+		th.downBlocked = blockDown
+		if blockDown {
+			t.DisallowDataDownload()
+		} else if !isStopped {
+			t.AllowDataDownload()
+		}
+	}
+	if blockUp != th.upBlocked {
+		th.upBlocked = blockUp
+		if blockUp {
+			t.DisallowDataUpload()
+		} else if !isStopped {
+			t.AllowDataUpload()
+		}
+	}
+}
+
+// releaseThrottle lifts any block left behind when a torrent's limits are
+// removed
+func (e *Engine) releaseThrottle(hash string, t *torrent.Torrent, th *torrentThrottle) {
+	e.setThrottled(hash, t, th, false, false)
+}
+
+// formatLimit formats a limit in bytes per second, 0 meaning unlimited
+func formatLimit(bytesPerSec int64) string {
+	if bytesPerSec <= 0 {
+		return "Unlimited"
+	}
+	return formatSpeed(bytesPerSec)
+}
@@ -0,0 +1,362 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"torrentflow/config"
+
+	"github.com/anacrolix/torrent"
+)
+
+// networkCheckInterval is how often the bound interface is checked
+const networkCheckInterval = 2 * time.Second
+
+// NetworkState describes the interface torrent traffic is bound to
+type NetworkState struct {
+	// Bound is set when traffic is pinned to an interface or address
+	Bound bool `json:"bound"`
+	// Interface is the configured interface name or address
+	Interface string `json:"interface"`
+	// Up is set while the interface has an address to bind to. While it is
+	// down the kill-switch keeps every torrent paused.
+	Up bool `json:"up"`
+	// Addresses are the local addresses the client is bound to
+	Addresses []string `json:"addresses"`
+	// Paused is the number of torrents the kill-switch paused
+	Paused    int       `json:"paused"`
+	ChangedAt time.Time `json:"changedAt"`
+}
+
+// NetworkInterface is an interface torrent traffic can be bound to
+type NetworkInterface struct {
+	Name      string   `json:"name"`
+	Up        bool     `json:"up"`
+	Addresses []string `json:"addresses"`
+}
+
+// NetworkInterfaces lists the network interfaces of the machine other than
+// loopback
+func NetworkInterfaces() ([]NetworkInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var list []NetworkInterface
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		ni := NetworkInterface{
+			Name:      iface.Name,
+			Up:        iface.Flags&net.FlagUp != 0,
+			Addresses: []string{},
+		}
+		addrs, _ := iface.Addrs()
+		for _, a := range addrs {
+			if prefix, err := netip.ParsePrefix(a.String()); err == nil {
+				ni.Addresses = append(ni.Addresses, prefix.Addr().String())
+			}
+		}
+		list = append(list, ni)
+	}
+	return list, nil
+}
+
+// binding holds the local addresses torrent traffic is bound to, at most
+// one per address family
+type binding struct {
+	v4, v6 netip.Addr
+}
+
+// loopbackBinding is used while the bound interface is down so that
+// nothing reaches the network
+var loopbackBinding = &binding{v4: netip.AddrFrom4([4]byte{127, 0, 0, 1})}
+
+// resolveBinding finds the addresses to bind to. It returns nil when
+// traffic is not bound, and reports whether the interface is up. An IP
+// address is up while some interface holds it.
+func resolveBinding(settings config.Settings) (b *binding, up bool) {
+	name := settings.BindInterface
+	if name == "" {
+		return nil, true
+	}
+
+	var addrs []net.Addr
+	var err error
+	if ip, perr := netip.ParseAddr(name); perr == nil {
+		addrs, err = net.InterfaceAddrs()
+		addrs = slices.DeleteFunc(addrs, func(a net.Addr) bool {
+			prefix, err := netip.ParsePrefix(a.String())
+			return err != nil || prefix.Addr().Unmap() != ip.Unmap()
+		})
+	} else {
+		var iface *net.Interface
+		iface, err = net.InterfaceByName(name)
+		if err == nil && iface.Flags&net.FlagUp != 0 {
+			addrs, err = iface.Addrs()
+		}
+	}
+	if err != nil {
+		return loopbackBinding, false
+	}
+
+	b = &binding{}
+	for _, a := range addrs {
+		prefix, err := netip.ParsePrefix(a.String())
+		if err != nil {
+			continue
+		}
+		addr := prefix.Addr().Unmap()
+		switch {
+		case addr.Is4() && !b.v4.IsValid():
+			b.v4 = addr
+		case addr.Is6() && !addr.IsLinkLocalUnicast() && !b.v6.IsValid() && settings.EnableIPv6:
+			b.v6 = addr
+		}
+	}
+	if !b.v4.IsValid() && !b.v6.IsValid() {
+		return loopbackBinding, false
+	}
+	return b, true
+}
+
+// addrs returns the bound addresses, IPv4 first
+func (b *binding) addrs() []netip.Addr {
+	var addrs []netip.Addr
+	for _, addr := range []netip.Addr{b.v4, b.v6} {
+		if addr.IsValid() {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// strings returns the bound addresses as strings
+func (b *binding) strings() []string {
+	var addrs []string
+	for _, addr := range b.addrs() {
+		addrs = append(addrs, addr.String())
+	}
+	return addrs
+}
+
+// equal reports whether two bindings use the same addresses. Nil is the
+// unbound client.
+func (b *binding) equal(other *binding) bool {
+	if b == nil || other == nil {
+		return b == other
+	}
+	return *b == *other
+}
+
+// localAddr returns the address to use for a network such as "tcp6" or
+// "udp", preferring IPv4 when the network allows either
+func (b *binding) localAddr(network string) netip.Addr {
+	switch {
+	case strings.HasSuffix(network, "6"):
+		return b.v6
+	case strings.HasSuffix(network, "4"), b.v4.IsValid():
+		return b.v4
+	default:
+		return b.v6
+	}
+}
+
+// DialContext opens connections from the bound address
+func (b *binding) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	local := b.localAddr(network)
+	if !local.IsValid() {
+		return nil, fmt.Errorf("no bound address for %s", network)
+	}
+	d := net.Dialer{LocalAddr: net.TCPAddrFromAddrPort(netip.AddrPortFrom(local, 0))}
+	return d.DialContext(ctx, network, addr)
+}
+
+// dialer returns a dialer for TCP connections from the bound address
+func (b *binding) dialer() *net.Dialer {
+	return &net.Dialer{LocalAddr: net.TCPAddrFromAddrPort(netip.AddrPortFrom(b.localAddr("tcp"), 0))}
+}
+
+// listenPacket opens UDP sockets on the bound address, for UDP trackers
+func (b *binding) listenPacket(network, addr string) (net.PacketConn, error) {
+	local := b.localAddr(network)
+	if !local.IsValid() {
+		return nil, fmt.Errorf("no bound address for %s", network)
+	}
+	return net.ListenPacket(network, netip.AddrPortFrom(local, 0).String())
+}
+
+// configureBinding pins tracker announces and web seeds to the bound
+// addresses. Peer sockets are opened on them by peerSockets.
+func configureBinding(cfg *torrent.ClientConfig, b *binding) {
+	if b == nil {
+		return
+	}
+
+	cfg.DisableIPv4 = !b.v4.IsValid()
+	cfg.DisableIPv6 = cfg.DisableIPv6 || !b.v6.IsValid()
+
+	cfg.TrackerDialContext = b.DialContext
+	cfg.TrackerListenPacket = b.listenPacket
+	cfg.HTTPDialContext = b.DialContext
+}
+
+// GetNetworkState returns the state of the bound interface
+func (e *Engine) GetNetworkState() NetworkState {
+	e.networkMutex.Lock()
+	state := e.network
+	state.Addresses = slices.Clone(state.Addresses)
+	e.networkMutex.Unlock()
+
+	e.sessionsMutex.RLock()
+	for _, session := range e.sessions {
+		if session.networkPause {
+			state.Paused++
+		}
+	}
+	e.sessionsMutex.RUnlock()
+	return state
+}
+
+// networkLoop watches the bound interface, and checks it at once when the
+// setting changes
+func (e *Engine) networkLoop() {
+	ticker := time.NewTicker(networkCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		case <-e.networkChanged:
+		}
+		e.checkNetwork()
+	}
+}
+
+// checkNetwork acts on changes to the bound interface. When it goes down
+// every torrent is paused; when it comes back the client is bound to its
+// addresses again and the torrents resume. The client is also rebuilt when
+// the setting or the interface's address changes.
+func (e *Engine) checkNetwork() {
+	settings := e.Settings()
+	b, up := resolveBinding(settings)
+
+	e.networkMutex.Lock()
+	old, bound := e.network, e.binding
+	e.networkMutex.Unlock()
+
+	moved := settings.BindInterface != old.Interface
+	if !moved && !up && !old.Up {
+		// Still down; torrents started since the last check are paused too
+		e.setNetworkPause(true)
+		return
+	}
+	if !moved && up == old.Up && b.equal(bound) {
+		return
+	}
+
+	if !up {
+		e.setNetworkPause(true)
+	}
+	// The sockets of an interface that went down are kept for when it
+	// comes back with the same address
+	if !b.equal(bound) && (up || moved) {
+		if err := e.rebuildClient(); err != nil {
+			// Tried again on the next check
+			log.Printf("⚠ Failed to bind to %q: %v", settings.BindInterface, err)
+			return
+		}
+	}
+	if up {
+		e.setNetworkPause(false)
+	}
+
+	e.networkMutex.Lock()
+	e.network.Bound = settings.BindInterface != ""
+	e.network.Interface = settings.BindInterface
+	e.network.Up = up
+	e.network.ChangedAt = time.Now()
+	e.networkMutex.Unlock()
+
+	state := e.GetNetworkState()
+	switch {
+	case !state.Bound:
+		log.Printf("🌐 Torrent traffic is no longer bound to an interface")
+	case up:
+		log.Printf("🌐 %s is up, bound to %v", state.Interface, state.Addresses)
+	default:
+		log.Printf("🛑 %s is down, %d torrent(s) paused", state.Interface, state.Paused)
+	}
+	e.emit("network-state", state)
+}
+
+// setBinding records the addresses a new client is bound to
+func (e *Engine) setBinding(name string, b *binding, up bool) {
+	e.networkMutex.Lock()
+	defer e.networkMutex.Unlock()
+
+	e.binding = b
+	e.network.Bound = name != ""
+	e.network.Interface = name
+	e.network.Up = up
+	e.network.Addresses = nil
+	if b != nil {
+		e.network.Addresses = b.strings()
+	}
+	e.network.ChangedAt = time.Now()
+}
+
+// setNetworkPause pauses every running torrent while the bound interface
+// is down, or resumes the ones the kill-switch paused unless the schedule
+// still holds them. Torrents paused by the user stay paused either way.
+func (e *Engine) setNetworkPause(pause bool) {
+	e.torrentsMutex.RLock()
+	torrents := make(map[string]*torrent.Torrent, len(e.torrents))
+	for hash, t := range e.torrents {
+		torrents[hash] = t
+	}
+	e.torrentsMutex.RUnlock()
+
+	changed := 0
+	for hash, t := range torrents {
+		if e.isBusy(hash) {
+			continue
+		}
+
+		if pause {
+			if e.pauseFor(hash, t, pausedByNetwork) {
+				changed++
+			}
+			continue
+		}
+		resumed, err := e.releasePause(hash, t, pausedByNetwork)
+		if err != nil {
+			log.Printf("⚠ Failed to resume %s after the network returned: %v", t.Name(), err)
+			continue
+		}
+		if resumed {
+			changed++
+		}
+	}
+
+	if changed == 0 {
+		return
+	}
+	if pause {
+		log.Printf("⏸ Kill-switch paused %d torrent(s)", changed)
+	} else {
+		log.Printf("▶ Kill-switch resumed %d torrent(s)", changed)
+	}
+	e.updateQueue()
+	e.saveTorrentStates()
+}
@@ -0,0 +1,253 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/netip"
+	"slices"
+	"sync"
+
+	"torrentflow/config"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/iplist"
+)
+
+// ipRange is an inclusive range of addresses
+type ipRange struct {
+	first, last netip.Addr
+}
+
+// ipSet is a sorted list of ranges that do not overlap
+type ipSet []ipRange
+
+// newIPSet sorts ranges and merges those that overlap or touch
+func newIPSet(ranges []ipRange) ipSet {
+	ranges = slices.Clone(ranges)
+	slices.SortFunc(ranges, func(a, b ipRange) int {
+		return a.first.Compare(b.first)
+	})
+
+	var set ipSet
+	for _, r := range ranges {
+		if n := len(set); n > 0 {
+			prev := &set[n-1]
+			next := prev.last.Next()
+			if prev.first.Is4() == r.first.Is4() && (!next.IsValid() || r.first.Compare(next) <= 0) {
+				if prev.last.Less(r.last) {
+					prev.last = r.last
+				}
+				continue
+			}
+		}
+		set = append(set, r)
+	}
+	return set
+}
+
+// lookup returns the range holding addr
+func (s ipSet) lookup(addr netip.Addr) (ipRange, bool) {
+	// Index of the first range starting after addr
+	i, _ := slices.BinarySearchFunc(s, addr, func(r ipRange, addr netip.Addr) int {
+		if r.first.Compare(addr) <= 0 {
+			return -1
+		}
+		return 1
+	})
+	if i == 0 || s[i-1].last.Less(addr) {
+		return ipRange{}, false
+	}
+	return s[i-1], true
+}
+
+// blocklist is handed to the torrent client as its IP blocklist. The
+// client asks it about every peer before connecting and about every
+// incoming connection. It holds the user's bans and the IP filter files,
+// and its contents can change while the client runs.
+type blocklist struct {
+	mu    sync.RWMutex
+	bans  ipSet
+	lists []*ipList
+}
+
+// Lookup implements iplist.Ranger. The client asks about addresses it
+// only hears of too, so nothing is counted here.
+func (b *blocklist) Lookup(ip net.IP) (iplist.Range, bool) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return iplist.Range{Description: "bad IP"}, true
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	r, l, ok := b.lookupLocked(addr.Unmap())
+	if !ok {
+		return iplist.Range{}, false
+	}
+	description := "banned"
+	if l != nil {
+		description = l.path
+	}
+	return iplist.Range{First: r.first.AsSlice(), Last: r.last.AsSlice(), Description: description}, true
+}
+
+// lookupLocked returns the range holding addr and the list it is from,
+// nil for the user's bans. mu must be held.
+func (b *blocklist) lookupLocked(addr netip.Addr) (ipRange, *ipList, bool) {
+	if r, ok := b.bans.lookup(addr); ok {
+		return r, nil, true
+	}
+	for _, l := range b.lists {
+		if r, ok := l.ranges.lookup(addr); ok {
+			return r, l, true
+		}
+	}
+	return ipRange{}, nil, false
+}
+
+// NumRanges implements iplist.Ranger
+func (b *blocklist) NumRanges() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	n := len(b.bans)
+	for _, l := range b.lists {
+		n += len(l.ranges)
+	}
+	return n
+}
+
+// refuse reports whether connections with addr are refused, and counts
+// the refusal against the list that blocks it. It is called where a
+// connection is turned away.
+func (b *blocklist) refuse(addr netip.Addr) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	_, l, blocked := b.lookupLocked(addr.Unmap())
+	if l != nil {
+		l.blocked.Add(1)
+	}
+	return blocked
+}
+
+// refusingListener closes incoming connections from blocked addresses
+// before the client sees them, see peerSockets
+type refusingListener struct {
+	net.Listener
+	blocklist *blocklist
+}
+
+// Accept implements net.Listener
+func (l refusingListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		addr, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+		if err == nil && l.blocklist.refuse(addr.Addr()) {
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
+
+// refusingDialer fails dials to blocked addresses. The client checks peers
+// when it hears of them, but a list loaded since may cover them by the
+// time they are dialled.
+type refusingDialer struct {
+	torrent.Dialer
+	blocklist *blocklist
+}
+
+// Dial implements torrent.Dialer
+func (d refusingDialer) Dial(ctx context.Context, addr string) (net.Conn, error) {
+	if addrPort, err := netip.ParseAddrPort(addr); err == nil && d.blocklist.refuse(addrPort.Addr()) {
+		return nil, fmt.Errorf("%s is blocked", addrPort.Addr())
+	}
+	return d.Dialer.Dial(ctx, addr)
+}
+
+// setBans replaces the banned ranges. Settings are validated before they
+// get here, so entries that don't parse are only logged.
+func (b *blocklist) setBans(bans []string) {
+	var ranges []ipRange
+	for _, ban := range bans {
+		first, last, err := config.ParseIPRange(ban)
+		if err != nil {
+			log.Printf("⚠ Ignoring ban: %v", err)
+			continue
+		}
+		ranges = append(ranges, ipRange{first: first, last: last})
+	}
+
+	set := newIPSet(ranges)
+	b.mu.Lock()
+	b.bans = set
+	b.mu.Unlock()
+}
+
+// setLists switches to the given filter files, loading those that are new
+// or changed on disk. It reports whether anything changed.
+func (b *blocklist) setLists(paths []string) bool {
+	b.mu.RLock()
+	current := b.lists
+	b.mu.RUnlock()
+
+	loaded := make(map[string]*ipList, len(current))
+	for _, l := range current {
+		loaded[l.path] = l
+	}
+
+	changed := len(paths) != len(current)
+	lists := make([]*ipList, 0, len(paths))
+	for i, path := range paths {
+		l, ok := loaded[path]
+		if !ok || l.stale() {
+			l = loadIPList(path, l)
+			changed = true
+		} else if i >= len(current) || current[i] != l {
+			changed = true
+		}
+		lists = append(lists, l)
+	}
+	if !changed {
+		return false
+	}
+
+	b.mu.Lock()
+	b.lists = lists
+	b.mu.Unlock()
+	return true
+}
+
+// applyBans puts new bans into force and disconnects peers they cover
+func (e *Engine) applyBans(bans []string) {
+	e.blocklist.setBans(bans)
+	e.disconnectBlocked()
+}
+
+// disconnectBlocked closes connections to peers the blocklist now refuses
+func (e *Engine) disconnectBlocked() {
+	e.torrentsMutex.RLock()
+	defer e.torrentsMutex.RUnlock()
+
+	kicked := 0
+	for _, t := range e.torrents {
+		for _, pc := range t.PeerConns() {
+			addr, err := netip.ParseAddrPort(pc.RemoteAddr.String())
+			if err == nil && e.blocklist.refuse(addr.Addr()) {
+				pc.Close()
+				kicked++
+			}
+		}
+	}
+	if kicked > 0 {
+		log.Printf("🚫 Disconnected %d blocked peer(s)", kicked)
+	}
+}
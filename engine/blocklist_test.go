@@ -0,0 +1,167 @@
+package engine
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"torrentflow/config"
+
+	"github.com/anacrolix/torrent"
+)
+
+func TestRefusedConnectionsCounted(t *testing.T) {
+	list := filepath.Join(t.TempDir(), "loopback.txt")
+	if err := os.WriteFile(list, []byte("127.0.0.0/8\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	settings := config.Default()
+	settings.DownloadDir = t.TempDir()
+	settings.StateFile = filepath.Join(t.TempDir(), "torrents.json")
+	settings.ListenPort = 0
+	settings.PortForwarding = false
+	settings.EnableDHT = false
+	settings.Blocklists = []string{list}
+
+	e := New(settings)
+	client, sockets, err := e.newClient(settings, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeSockets(sockets)
+	defer client.Close()
+
+	blocked := func() int64 {
+		return e.GetBlocklists()[0].Blocked
+	}
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(client.LocalPort()))
+
+	// Incoming on the client's own listener
+	conn, err := net.Dial("tcp4", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Error("blocked connection was accepted")
+	}
+	conn.Close()
+	if got := blocked(); got != 1 {
+		t.Errorf("blocked after an incoming connection = %d, want 1", got)
+	}
+
+	// Dialled by the client
+	d := refusingDialer{
+		Dialer:    torrent.NetworkDialer{Network: "tcp4", Dialer: &net.Dialer{}},
+		blocklist: e.blocklist,
+	}
+	if conn, err := d.Dial(context.Background(), addr); err == nil {
+		conn.Close()
+		t.Error("blocked address was dialled")
+	}
+	if got := blocked(); got != 2 {
+		t.Errorf("blocked after a dial = %d, want 2", got)
+	}
+
+	// Only heard of
+	if _, ok := e.blocklist.Lookup(net.IPv4(127, 0, 0, 1)); !ok {
+		t.Error("Lookup doesn't block a listed address")
+	}
+	if got := blocked(); got != 2 {
+		t.Errorf("blocked after a lookup = %d, want 2", got)
+	}
+}
+
+func TestNewIPSet(t *testing.T) {
+	tests := []struct {
+		name   string
+		ranges []ipRange
+		want   []ipRange
+	}{
+		{
+			name:   "empty",
+			ranges: nil,
+			want:   nil,
+		},
+		{
+			name:   "sorted",
+			ranges: []ipRange{testRange("5.0.0.0", "5.0.0.9"), testRange("1.0.0.0", "1.0.0.9")},
+			want:   []ipRange{testRange("1.0.0.0", "1.0.0.9"), testRange("5.0.0.0", "5.0.0.9")},
+		},
+		{
+			name:   "adjacent",
+			ranges: []ipRange{testRange("1.0.0.10", "1.0.0.19"), testRange("1.0.0.0", "1.0.0.9")},
+			want:   []ipRange{testRange("1.0.0.0", "1.0.0.19")},
+		},
+		{
+			name:   "one address apart",
+			ranges: []ipRange{testRange("1.0.0.0", "1.0.0.9"), testRange("1.0.0.11", "1.0.0.19")},
+			want:   []ipRange{testRange("1.0.0.0", "1.0.0.9"), testRange("1.0.0.11", "1.0.0.19")},
+		},
+		{
+			name:   "overlapping",
+			ranges: []ipRange{testRange("1.0.0.0", "1.0.0.15"), testRange("1.0.0.10", "1.0.0.19")},
+			want:   []ipRange{testRange("1.0.0.0", "1.0.0.19")},
+		},
+		{
+			name:   "contained",
+			ranges: []ipRange{testRange("1.0.0.0", "1.0.0.255"), testRange("1.0.0.10", "1.0.0.19"), testRange("1.0.0.255", "1.0.0.255")},
+			want:   []ipRange{testRange("1.0.0.0", "1.0.0.255")},
+		},
+		{
+			name:   "chain",
+			ranges: []ipRange{testRange("1.0.0.20", "1.0.0.29"), testRange("1.0.0.0", "1.0.0.9"), testRange("1.0.0.5", "1.0.0.19")},
+			want:   []ipRange{testRange("1.0.0.0", "1.0.0.29")},
+		},
+		{
+			name:   "IPv4 and IPv6 kept apart",
+			ranges: []ipRange{testRange("::", "::ffff"), testRange("0.0.0.0", "0.0.0.255"), testRange("2001:db8::", "2001:db8::ff")},
+			want:   []ipRange{testRange("0.0.0.0", "0.0.0.255"), testRange("::", "::ffff"), testRange("2001:db8::", "2001:db8::ff")},
+		},
+		{
+			name:   "last IPv4 address before IPv6",
+			ranges: []ipRange{testRange("::", "::1"), testRange("255.255.255.0", "255.255.255.255"), testRange("255.255.255.255", "255.255.255.255")},
+			want:   []ipRange{testRange("255.255.255.0", "255.255.255.255"), testRange("::", "::1")},
+		},
+		{
+			name:   "last IPv6 address",
+			ranges: []ipRange{testRange("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff"), testRange("ffff:ffff:ffff:ffff:ffff:ffff:ffff:fff0", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")},
+			want:   []ipRange{testRange("ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := newIPSet(tt.ranges)
+			if !slices.Equal(set, tt.want) {
+				t.Errorf("newIPSet = %v, want %v", set, tt.want)
+			}
+
+			// Both ends of every range given are found
+			for _, r := range tt.ranges {
+				for _, addr := range []netip.Addr{r.first, r.last} {
+					if _, ok := set.lookup(addr); !ok {
+						t.Errorf("lookup(%s) missed", addr)
+					}
+				}
+			}
+			// Merged ranges leave a gap before and after each other
+			for _, r := range set {
+				for _, addr := range []netip.Addr{r.first.Prev(), r.last.Next()} {
+					if !addr.IsValid() || addr.Is4() != r.first.Is4() {
+						continue
+					}
+					if found, ok := set.lookup(addr); ok {
+						t.Errorf("lookup(%s) found %s-%s next to %s-%s", addr, found.first, found.last, r.first, r.last)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+
+	"torrentflow/config"
+
+	"github.com/anacrolix/torrent"
+)
+
+// torrentClient returns the torrent client, which is replaced when it is
+// rebuilt
+func (e *Engine) torrentClient() *torrent.Client {
+	e.clientMutex.RLock()
+	defer e.clientMutex.RUnlock()
+	return e.client
+}
+
+// newClient builds a torrent client from the settings, bound to b unless
+// it is nil. When the configured listen port is taken a random one is used
+// for this client. The sockets opened for the client are returned to be
+// closed with it.
+func (e *Engine) newClient(settings config.Settings, b *binding) (*torrent.Client, []io.Closer, error) {
+	cfg := torrent.NewDefaultClientConfig()
+	cfg.DataDir = e.downloadDir
+	cfg.Seed = settings.Seed
+	cfg.Debug = false
+	cfg.DisableIPv6 = !settings.EnableIPv6
+	configurePeerProtocol(cfg, settings)
+	cfg.DownloadRateLimiter = e.downloadLimiter
+	cfg.UploadRateLimiter = e.uploadLimiter
+	e.blocklist.setLists(settings.Blocklists)
+	cfg.IPBlocklist = e.blocklist
+	e.trackPeers(&cfg.Callbacks)
+	configureBinding(cfg, b)
+	// The port mapper forwards the port and reports how it went
+	cfg.NoDefaultPortForwarding = true
+
+	forward := &net.Dialer{}
+	if b != nil {
+		forward = b.dialer()
+	}
+	proxyDialer, err := configureProxy(cfg, settings.Proxy, forward)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to configure proxy: %w", err)
+	}
+	// The engine opens the client's sockets, see peerSockets
+	cfg.DisableTCP = true
+	cfg.DisableUTP = true
+	cfg.NoDHT = true
+
+	client, err := torrent.NewClient(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if proxyDialer != nil {
+		client.AddDialer(refusingDialer{Dialer: proxyDialer, blocklist: e.blocklist})
+		log.Printf("✓ Connecting to peers through %s proxy %s:%d", settings.Proxy.Type, settings.Proxy.Host, settings.Proxy.Port)
+		return client, nil, nil
+	}
+
+	ports := []int{settings.ListenPort} // 0 means random port
+	if settings.ListenPort != 0 {
+		ports = append(ports, 0)
+	}
+
+	var lastErr error
+	for _, port := range ports {
+		sockets, err := e.peerSockets(client, settings, b, port)
+		if err != nil {
+			log.Printf("⚠ Port %d unavailable: %v", port, err)
+			lastErr = err
+			continue
+		}
+		log.Printf("✓ Torrent client listening on port: %d", client.LocalPort())
+		return client, sockets, nil
+	}
+	client.Close()
+	return nil, nil, lastErr
+}
+
+// rebuildClient replaces the torrent client with one built from the
+// current settings, so that settings fixed when a client is built can
+// change while the engine runs. Torrents that aren't paused are moved to
+// the new client with their progress; paused ones are already out of the
+// client and pick it up when resumed. Magnet previews keep fetching
+// metadata.
+//
+// The old client has to go first to free its port. If the new one can't
+// be built, a client with the old addresses and port takes the torrents
+// instead and the error is returned so the caller tries again. Should that
+// fail too, the engine is left without a client: the torrents wait out of
+// it for the next rebuild and are saved as running for the next start.
+func (e *Engine) rebuildClient() error {
+	e.rebuildMutex.Lock()
+	defer e.rebuildMutex.Unlock()
+
+	settings := e.Settings()
+	b, up := resolveBinding(settings)
+
+	// Includes torrents left out of the client by a rebuild that failed
+	e.torrentsMutex.RLock()
+	moving := make(map[string]*torrent.Torrent, len(e.torrents))
+	for hash, t := range e.torrents {
+		if !e.isPaused(hash) {
+			moving[hash] = t
+		}
+	}
+	e.torrentsMutex.RUnlock()
+
+	// A torrent that is being moved or checked can't leave its client
+	for hash := range moving {
+		if e.isBusy(hash) {
+			return ErrTorrentBusy
+		}
+	}
+
+	// Whatever happens to the client, the torrents come back at the next
+	// start
+	e.saveTorrentStates()
+
+	states := make(map[string]TorrentState, len(moving))
+	for hash, t := range moving {
+		states[hash] = e.buildTorrentState(hash, t)
+		e.detachTorrent(t)
+	}
+
+	e.networkMutex.Lock()
+	oldBinding := e.binding
+	e.networkMutex.Unlock()
+	oldPort := e.GetPortStatus()
+
+	// Metadata fetches wait for the new client
+	e.previewsMutex.Lock()
+
+	e.clientMutex.Lock()
+	if e.client != nil {
+		e.client.Close()
+	}
+	closeSockets(e.sockets)
+	client, sockets, err := e.newClient(settings, b)
+	var rebuildErr error
+	if err != nil {
+		rebuildErr = fmt.Errorf("failed to create torrent client: %w", err)
+		log.Printf("⚠ %v; going back to the previous network settings", rebuildErr)
+
+		previous := settings
+		previous.ListenPort = oldPort.Port
+		client, sockets, err = e.newClient(previous, oldBinding)
+		if err != nil {
+			e.client = nil
+			e.sockets = nil
+			e.clientMutex.Unlock()
+			e.previewsMutex.Unlock()
+			log.Printf("❌ No torrent client: %v", err)
+			return rebuildErr
+		}
+	}
+	e.client = client
+	e.sockets = sockets
+	e.clientMutex.Unlock()
+	if rebuildErr == nil {
+		e.setBinding(settings.BindInterface, b, up)
+		e.setListenPort(settings.ListenPort, client.LocalPort())
+	} else {
+		// The port loop keeps trying the configured port
+		e.setListenPort(oldPort.Configured, client.LocalPort())
+	}
+
+	for _, p := range e.previews {
+		if p.magnet == nil {
+			continue
+		}
+		t, _, err := client.AddTorrentSpec(&torrent.TorrentSpec{
+			InfoHash:    p.magnet.InfoHash(),
+			Trackers:    p.trackers,
+			DisplayName: p.preview.Name,
+		})
+		if err != nil {
+			log.Printf("⚠ Failed to move preview %s: %v", p.preview.ID, err)
+			continue
+		}
+		p.magnet = t
+	}
+	e.previewsMutex.Unlock()
+
+	for hash, state := range states {
+		t, err := e.reattachTorrent(hash, state)
+		if err != nil {
+			log.Printf("⚠ Failed to move %s to the new client: %v", hash, err)
+			e.pausedMutex.Lock()
+			e.pausedTorrents[hash] = true
+			e.pausedMutex.Unlock()
+			continue
+		}
+		go e.startWhenReady(hash, t)
+	}
+
+	e.updateQueue()
+	e.saveTorrentStates()
+
+	if rebuildErr != nil {
+		return rebuildErr
+	}
+	log.Printf("✓ Torrent client rebuilt with %d running torrent(s)", len(states))
+	return nil
+}
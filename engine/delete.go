@@ -0,0 +1,174 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errOutsideRoot is reported for files that would resolve to a location
+// outside the torrent's storage root
+var errOutsideRoot = errors.New("outside the storage root")
+
+// RemoveOptions decide what happens to a removed torrent's data
+type RemoveOptions struct {
+	DeleteFiles bool `json:"deleteFiles"`
+	// Trash moves the files to the trash instead of deleting them
+	Trash bool `json:"trash"`
+}
+
+// RemoveReport lists what happened to a removed torrent's data
+type RemoveReport struct {
+	InfoHash    string `json:"infoHash"`
+	Name        string `json:"name"`
+	StorageRoot string `json:"storageRoot"`
+	Trashed     bool   `json:"trashed"`
+	// Removed are the files deleted or moved to the trash
+	Removed     []string `json:"removed"`
+	RemovedDirs []string `json:"removedDirs"`
+	// Missing files were never downloaded or already gone
+	Missing []string `json:"missing"`
+	// Refused files were left alone because they resolve to a location
+	// outside the storage root
+	Refused []string `json:"refused"`
+	Errors  []string `json:"errors"`
+}
+
+// deletion removes a torrent's files below its storage root
+type deletion struct {
+	root     string // Directory, or the file itself for flat single file torrents
+	realRoot string // root with symlinks resolved
+	trash    bool
+	report   *RemoveReport
+}
+
+// deleteTorrentFiles deletes or trashes the given files of a torrent
+// stored below root, then prunes directories left empty up to root.
+// Nothing outside root is touched, even through symlinked directories.
+func deleteTorrentFiles(root string, paths []string, trash bool, report *RemoveReport) {
+	report.StorageRoot = root
+	report.Trashed = trash
+
+	root = filepath.Clean(root)
+	if !filepath.IsAbs(root) || filepath.Dir(root) == root {
+		report.Errors = append(report.Errors, fmt.Sprintf("refusing to delete below storage root %q", root))
+		report.Refused = append(report.Refused, paths...)
+		return
+	}
+
+	realRoot := resolveParent(root)
+	if stat, err := os.Stat(root); err == nil && stat.IsDir() {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			realRoot = resolved
+		}
+	}
+
+	d := &deletion{root: root, realRoot: realRoot, trash: trash, report: report}
+	for _, path := range paths {
+		d.remove(path)
+	}
+
+	for _, path := range paths {
+		for _, dir := range pruneEmptyDirs(filepath.Dir(path), root) {
+			report.RemovedDirs = append(report.RemovedDirs, dir)
+			log.Printf("✓ Removed empty directory: %s", dir)
+		}
+	}
+}
+
+// remove deletes or trashes a single file
+func (d *deletion) remove(path string) {
+	path = filepath.Clean(path)
+	if err := d.check(path); err != nil {
+		switch {
+		case os.IsNotExist(err):
+			d.report.Missing = append(d.report.Missing, path)
+		case errors.Is(err, errOutsideRoot):
+			log.Printf("⚠️ Not deleting %s: %v", path, err)
+			d.report.Refused = append(d.report.Refused, path)
+		default:
+			d.fail(path, err)
+		}
+		return
+	}
+
+	if d.trash {
+		trashed, err := moveToTrash(path)
+		if err != nil {
+			d.fail(path, err)
+			return
+		}
+		log.Printf("✓ Moved to trash: %s -> %s", path, trashed)
+	} else {
+		if err := os.Remove(path); err != nil {
+			d.fail(path, err)
+			return
+		}
+		log.Printf("✓ Deleted file: %s", path)
+	}
+	d.report.Removed = append(d.report.Removed, path)
+}
+
+// check makes sure path exists, is not a directory and stays below the
+// storage root once symlinked parent directories are resolved
+func (d *deletion) check(path string) error {
+	if !isWithin(d.root, path) {
+		return errOutsideRoot
+	}
+	stat, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return fmt.Errorf("%s is a directory", path)
+	}
+	if !isWithin(d.realRoot, resolveParent(path)) {
+		return errOutsideRoot
+	}
+	return nil
+}
+
+func (d *deletion) fail(path string, err error) {
+	log.Printf("⚠️ Warning: failed to delete file %s: %v", path, err)
+	d.report.Errors = append(d.report.Errors, fmt.Sprintf("%s: %v", path, err))
+}
+
+// resolveParent resolves symlinks in the directories above path but not
+// in path itself, so a symlinked file is removed rather than its target
+func resolveParent(path string) string {
+	dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+	if err != nil {
+		return path
+	}
+	return filepath.Join(dir, filepath.Base(path))
+}
+
+// pruneEmptyDirs removes dir and its parents while they are empty,
+// stopping before root, and returns the directories removed. Directories
+// outside root and symlinks are never touched.
+func pruneEmptyDirs(dir, root string) []string {
+	var removed []string
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); isWithin(root, dir) && dir != root; dir = filepath.Dir(dir) {
+		if stat, err := os.Lstat(dir); err != nil || !stat.IsDir() {
+			break
+		}
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+		removed = append(removed, dir)
+	}
+	return removed
+}
+
+// isWithin reports whether path is root or lies below it
+func isWithin(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,320 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDeleteTorrentFiles(t *testing.T) {
+	// Paths are relative to a temp dir
+	tests := []struct {
+		name    string
+		files   []string
+		links   map[string]string // Symlink to target
+		root    string
+		paths   []string
+		removed []string
+		dirs    []string // Directories pruned
+		missing []string
+		refused []string
+		kept    []string
+	}{
+		{
+			name:    "nested files",
+			files:   []string{"root/a/b/1.bin", "root/a/2.bin", "root/3.bin"},
+			root:    "root",
+			paths:   []string{"root/a/b/1.bin", "root/a/2.bin", "root/3.bin"},
+			removed: []string{"root/a/b/1.bin", "root/a/2.bin", "root/3.bin"},
+			dirs:    []string{"root/a/b", "root/a"},
+			kept:    []string{"root"},
+		},
+		{
+			name:    "pruning stops at a directory still in use",
+			files:   []string{"root/a/b/1.bin", "root/a/other.bin"},
+			root:    "root",
+			paths:   []string{"root/a/b/1.bin"},
+			removed: []string{"root/a/b/1.bin"},
+			dirs:    []string{"root/a/b"},
+			kept:    []string{"root/a/other.bin"},
+		},
+		{
+			name:    "escape through ..",
+			files:   []string{"outside.bin", "root/a/1.bin"},
+			root:    "root",
+			paths:   []string{"root/../outside.bin", "root/a/../../outside.bin", "root/a/1.bin"},
+			removed: []string{"root/a/1.bin"},
+			dirs:    []string{"root/a"},
+			refused: []string{"outside.bin", "outside.bin"},
+			kept:    []string{"outside.bin", "root"},
+		},
+		{
+			name:    "symlinked parent directory",
+			files:   []string{"elsewhere/1.bin"},
+			links:   map[string]string{"root/linked": "elsewhere"},
+			root:    "root",
+			paths:   []string{"root/linked/1.bin"},
+			refused: []string{"root/linked/1.bin"},
+			kept:    []string{"elsewhere/1.bin", "root/linked"},
+		},
+		{
+			name:    "symlinked file",
+			files:   []string{"elsewhere/1.bin"},
+			links:   map[string]string{"root/1.bin": "elsewhere/1.bin"},
+			root:    "root",
+			paths:   []string{"root/1.bin"},
+			removed: []string{"root/1.bin"},
+			kept:    []string{"elsewhere/1.bin"},
+		},
+		{
+			name:    "symlinked storage root",
+			files:   []string{"real/a/1.bin"},
+			links:   map[string]string{"root": "real"},
+			root:    "root",
+			paths:   []string{"root/a/1.bin"},
+			removed: []string{"root/a/1.bin"},
+			dirs:    []string{"root/a"},
+			kept:    []string{"real"},
+		},
+		{
+			name:    "flat single file",
+			files:   []string{"single.bin", "other.bin"},
+			root:    "single.bin",
+			paths:   []string{"single.bin"},
+			removed: []string{"single.bin"},
+			kept:    []string{"other.bin"},
+		},
+		{
+			name:    "flat single file, path beside it",
+			files:   []string{"single.bin", "other.bin"},
+			root:    "single.bin",
+			paths:   []string{"other.bin"},
+			refused: []string{"other.bin"},
+			kept:    []string{"single.bin", "other.bin"},
+		},
+		{
+			name:    "missing file",
+			files:   []string{"root/1.bin"},
+			root:    "root",
+			paths:   []string{"root/a/gone.bin"},
+			missing: []string{"root/a/gone.bin"},
+			kept:    []string{"root/1.bin"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := t.TempDir()
+			abs := func(paths []string) []string {
+				var out []string
+				for _, path := range paths {
+					out = append(out, filepath.Join(base, path))
+				}
+				return out
+			}
+			for _, path := range abs(tt.files) {
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for link, target := range tt.links {
+				link = filepath.Join(base, link)
+				if err := os.MkdirAll(filepath.Dir(link), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Symlink(filepath.Join(base, target), link); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var paths []string
+			for _, path := range tt.paths {
+				// Joined by hand, filepath.Join would clean away the ..
+				paths = append(paths, base+string(filepath.Separator)+filepath.FromSlash(path))
+			}
+			var report RemoveReport
+			deleteTorrentFiles(filepath.Join(base, tt.root), paths, false, &report)
+
+			check := func(what string, got, want []string) {
+				if !slices.Equal(got, abs(want)) {
+					t.Errorf("%s = %v, want %v", what, got, abs(want))
+				}
+			}
+			check("Removed", report.Removed, tt.removed)
+			check("RemovedDirs", report.RemovedDirs, tt.dirs)
+			check("Missing", report.Missing, tt.missing)
+			check("Refused", report.Refused, tt.refused)
+			if len(report.Errors) > 0 {
+				t.Errorf("Errors = %v", report.Errors)
+			}
+			for _, path := range abs(tt.removed) {
+				if _, err := os.Lstat(path); !os.IsNotExist(err) {
+					t.Errorf("%s was not removed", path)
+				}
+			}
+			for _, path := range abs(tt.kept) {
+				if _, err := os.Lstat(path); err != nil {
+					t.Errorf("%s was removed", path)
+				}
+			}
+		})
+	}
+}
+
+func TestDeleteTorrentFilesRefusesRoot(t *testing.T) {
+	for _, root := range []string{string(filepath.Separator), "downloads", ""} {
+		path := filepath.Join(t.TempDir(), "1.bin")
+		if err := os.WriteFile(path, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var report RemoveReport
+		deleteTorrentFiles(root, []string{path}, false, &report)
+		if !slices.Equal(report.Refused, []string{path}) || len(report.Removed) > 0 {
+			t.Errorf("deleting below %q: Removed = %v, Refused = %v", root, report.Removed, report.Refused)
+		}
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("deleting below %q removed the file", root)
+		}
+	}
+}
+
+func TestPruneEmptyDirs(t *testing.T) {
+	base := t.TempDir()
+	root := filepath.Join(base, "root")
+	for _, dir := range []string{"root/a/b/c", "root/x/y", "outside/z"} {
+		if err := os.MkdirAll(filepath.Join(base, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(base, "outside"), filepath.Join(root, "x", "y", "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		dir  string
+		want []string
+	}{
+		{"root/a/b/c", []string{"root/a/b/c", "root/a/b", "root/a"}},
+		{"root", nil},
+		{"outside/z", nil},
+		{"root/x/y/link", nil},
+		{"root/../outside/z", nil},
+	}
+	for _, tt := range tests {
+		var want []string
+		for _, dir := range tt.want {
+			want = append(want, filepath.Join(base, dir))
+		}
+		got := pruneEmptyDirs(base+string(filepath.Separator)+filepath.FromSlash(tt.dir), root)
+		if !slices.Equal(got, want) {
+			t.Errorf("pruneEmptyDirs(%s) = %v, want %v", tt.dir, got, want)
+		}
+	}
+	for _, dir := range []string{"root", "root/x/y/link", "outside/z"} {
+		if _, err := os.Lstat(filepath.Join(base, dir)); err != nil {
+			t.Errorf("%s was removed", dir)
+		}
+	}
+}
+
+func TestIsWithin(t *testing.T) {
+	root := filepath.FromSlash("/data/torrents")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/data/torrents", true},
+		{"/data/torrents/a/b.bin", true},
+		{"/data/torrents/..b.bin", true},
+		{"/data/torrents/a/../b.bin", true},
+		{"/data/torrents/..", false},
+		{"/data/torrents/../other/b.bin", false},
+		{"/data/torrents-old/b.bin", false},
+		{"/data", false},
+		{"relative/b.bin", false},
+	}
+	for _, tt := range tests {
+		if got := isWithin(root, filepath.FromSlash(tt.path)); got != tt.want {
+			t.Errorf("isWithin(%s, %s) = %v, want %v", root, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestMoveToTrashNameCollisions(t *testing.T) {
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		t.Skip("freedesktop.org trash only")
+	}
+	dataHome := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dataHome)
+	t.Setenv("HOME", t.TempDir())
+	filesDir := filepath.Join(dataHome, "Trash", "files")
+	infoDir := filepath.Join(dataHome, "Trash", "info")
+
+	// "1.bin" is taken by a file, "1 2.bin" reserved by another deletion's
+	// info file
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(filesDir, "1.bin"), []byte("earlier"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(infoDir, "1 2.bin.trashinfo"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	root := filepath.Join(t.TempDir(), "root")
+	paths := []string{filepath.Join(root, "1.bin"), filepath.Join(root, "a", "1.bin")}
+	for _, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var report RemoveReport
+	deleteTorrentFiles(root, paths, true, &report)
+	if !slices.Equal(report.Removed, paths) || len(report.Errors) > 0 {
+		t.Fatalf("Removed = %v, Errors = %v", report.Removed, report.Errors)
+	}
+
+	for i, name := range []string{"1 3.bin", "1 4.bin"} {
+		data, err := os.ReadFile(filepath.Join(filesDir, name))
+		if err != nil || string(data) != paths[i] {
+			t.Errorf("trash holds %q as %s, want %s", data, name, paths[i])
+		}
+		info, err := os.ReadFile(filepath.Join(infoDir, name+".trashinfo"))
+		if err != nil || !strings.Contains(string(info), "Path="+paths[i]+"\n") {
+			t.Errorf("%s.trashinfo = %q, want the path of %s", name, info, paths[i])
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(filesDir, "1.bin")); string(data) != "earlier" {
+		t.Errorf("file already in the trash was replaced with %q", data)
+	}
+}
+
+func TestTrashName(t *testing.T) {
+	tests := []struct {
+		name string
+		i    int
+		want string
+	}{
+		{"1.bin", 1, "1.bin"},
+		{"1.bin", 2, "1 2.bin"},
+		{"archive.tar.gz", 3, "archive.tar 3.gz"},
+		{"README", 2, "README 2"},
+	}
+	for _, tt := range tests {
+		if got := trashName(tt.name, tt.i); got != tt.want {
+			t.Errorf("trashName(%q, %d) = %q, want %q", tt.name, tt.i, got, tt.want)
+		}
+	}
+}
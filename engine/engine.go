@@ -0,0 +1,400 @@
+// Package engine contains the torrent client and everything around it:
+// the torrents map, speed tracking and state persistence. It has no
+// dependency on the GUI so it can run headless.
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"torrentflow/config"
+
+	"github.com/anacrolix/torrent"
+	"golang.org/x/time/rate"
+)
+
+// ErrTorrentNotFound is returned when no torrent has the given info hash
+var ErrTorrentNotFound = errors.New("torrent not found")
+
+// EventHandler receives events emitted by the engine. Handlers are called
+// from engine goroutines and must not block.
+type EventHandler func(name string, data ...interface{})
+
+// Engine runs the torrent client and tracks all torrents added to it
+type Engine struct {
+	settings       config.Settings
+	settingsMutex  sync.RWMutex
+	client         *torrent.Client
+	sockets        []io.Closer // Opened for the client, closed with it
+	clientMutex    sync.RWMutex
+	rebuildMutex   sync.Mutex
+	detachLocks    sync.Map // *torrent.Torrent -> *sync.Mutex while detaching
+	torrents       map[string]*torrent.Torrent
+	torrentsMutex  sync.RWMutex
+	downloadDir    string
+	stateStore     *stateStore
+	downloadSpeeds map[string]*speedTracker
+	uploadSpeeds   map[string]*speedTracker
+	speedsMutex    sync.RWMutex
+	pausedTorrents map[string]bool
+	pausedMutex    sync.RWMutex
+	sessions       map[string]*torrentSession
+	sessionsMutex  sync.RWMutex
+	peers          map[*torrent.Peer]*peerStats
+	peersMutex     sync.Mutex
+	blocklist      *blocklist // Handed to the client as its IP blocklist
+	network        NetworkState
+	binding        *binding // Addresses the client is bound to, nil if unbound
+	networkMutex   sync.Mutex
+	networkChanged chan struct{} // Asks the network loop to check at once
+	port           PortStatus
+	portMutex      sync.Mutex
+	portMapper     portMapper    // Used by the port loop only
+	portChanged    chan struct{} // Asks the port loop to check at once
+	portLoopDone   chan struct{} // Closed once the mappings are removed
+	// Shared with the client config and adjusted when limits change
+	downloadLimiter *rate.Limiter
+	uploadLimiter   *rate.Limiter
+	schedule        ScheduleState
+	scheduleMutex   sync.Mutex
+	queue           []string        // Info hashes in queue order
+	queuedTorrents  map[string]bool // Held back by the queue
+	queueMutex      sync.Mutex
+	previews        map[string]*pendingPreview // Keyed by info hash
+	previewsMutex   sync.Mutex
+	handlers        map[int]EventHandler
+	nextHandlerID   int
+	handlersMutex   sync.RWMutex
+	lastUpdateHash  string
+	lastUpdateTime  time.Time
+	updateMutex     sync.Mutex
+	done            chan struct{}
+	closeOnce       sync.Once
+}
+
+// New creates an engine. Call Start to bring up the torrent client.
+func New(settings config.Settings) *Engine {
+	e := &Engine{
+		settings:        settings,
+		torrents:        make(map[string]*torrent.Torrent),
+		downloadDir:     settings.DownloadDir,
+		stateStore:      newStateStore(settings.StateFile),
+		downloadSpeeds:  make(map[string]*speedTracker),
+		uploadSpeeds:    make(map[string]*speedTracker),
+		pausedTorrents:  make(map[string]bool),
+		sessions:        make(map[string]*torrentSession),
+		peers:           make(map[*torrent.Peer]*peerStats),
+		blocklist:       &blocklist{},
+		downloadLimiter: newRateLimiter(),
+		uploadLimiter:   newRateLimiter(),
+		queuedTorrents:  make(map[string]bool),
+		previews:        make(map[string]*pendingPreview),
+		handlers:        make(map[int]EventHandler),
+		networkChanged:  make(chan struct{}, 1),
+		portChanged:     make(chan struct{}, 1),
+		portLoopDone:    make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+	e.blocklist.setBans(settings.BannedPeers)
+	return e
+}
+
+// Start creates the torrent client, restores saved torrents and starts
+// the stats loop
+func (e *Engine) Start() error {
+	// Create directory if it doesn't exist
+	if err := os.MkdirAll(e.downloadDir, 0755); err != nil {
+		log.Printf("Error creating download directory: %v", err)
+		return fmt.Errorf("failed to create download directory: %w", err)
+	}
+
+	settings := e.Settings()
+
+	b, up := resolveBinding(settings)
+	client, sockets, err := e.newClient(settings, b)
+	if err != nil {
+		log.Printf("❌ Error creating torrent client after trying all ports: %v", err)
+		return fmt.Errorf("failed to create torrent client: %w", err)
+	}
+
+	e.clientMutex.Lock()
+	e.client = client
+	e.sockets = sockets
+	e.clientMutex.Unlock()
+	e.setBinding(settings.BindInterface, b, up)
+	e.setListenPort(settings.ListenPort, client.LocalPort())
+
+	// Load saved torrents
+	e.loadSavedTorrents()
+
+	// Pick the speed profile before anything starts transferring
+	e.applySchedule(time.Now())
+
+	// Hold torrents back while the bound interface is down, and resume
+	// those the kill-switch paused in the last run otherwise
+	e.setNetworkPause(!up)
+
+	// Start stats update loop
+	go e.updateStatsLoop()
+	go e.throttleLoop()
+	go e.scheduleLoop()
+	go e.previewLoop()
+	go e.blocklistLoop()
+	go e.networkLoop()
+	go e.portLoop()
+
+	log.Printf("✓ Torrent client initialized successfully")
+	log.Printf("✓ Download folder: %s", e.downloadDir)
+	return nil
+}
+
+// Close saves torrent state and shuts down the torrent client
+func (e *Engine) Close() {
+	e.closeOnce.Do(func() {
+		close(e.done)
+
+		// Save torrent states before closing
+		e.saveTorrentStates()
+
+		// Give the port loop a moment to remove the router's mappings;
+		// they run out on their own otherwise
+		if e.torrentClient() != nil {
+			select {
+			case <-e.portLoopDone:
+			case <-time.After(portUnmapTimeout):
+			}
+		}
+
+		e.clientMutex.Lock()
+		if e.client != nil {
+			log.Println("Closing torrent client...")
+			e.client.Close()
+			closeSockets(e.sockets)
+			log.Println("✓ Torrent client closed")
+		}
+		e.clientMutex.Unlock()
+	})
+}
+
+// Settings returns the settings the engine is running with
+func (e *Engine) Settings() config.Settings {
+	e.settingsMutex.RLock()
+	defer e.settingsMutex.RUnlock()
+	return e.settings
+}
+
+// ApplySettings switches the engine to new settings. Settings that are
+// read when needed take effect immediately; the names of settings that
+// are fixed once the torrent client is built are returned and apply after
+// a restart.
+func (e *Engine) ApplySettings(settings config.Settings) (restartRequired []string) {
+	e.settingsMutex.Lock()
+
+	old := e.settings
+	if settings.DownloadDir != old.DownloadDir {
+		restartRequired = append(restartRequired, "downloadDir")
+	}
+	if settings.StateFile != old.StateFile {
+		restartRequired = append(restartRequired, "stateFile")
+	}
+	if settings.Seed != old.Seed {
+		restartRequired = append(restartRequired, "seed")
+	}
+	if settings.EnableDHT != old.EnableDHT {
+		restartRequired = append(restartRequired, "enableDht")
+	}
+	if settings.EnableIPv6 != old.EnableIPv6 {
+		restartRequired = append(restartRequired, "enableIpv6")
+	}
+	if settings.EnableTCP != old.EnableTCP {
+		restartRequired = append(restartRequired, "enableTcp")
+	}
+	if settings.EnableUTP != old.EnableUTP {
+		restartRequired = append(restartRequired, "enableUtp")
+	}
+	if settings.Encryption != old.Encryption {
+		restartRequired = append(restartRequired, "encryption")
+	}
+	if settings.Proxy != old.Proxy {
+		restartRequired = append(restartRequired, "proxy")
+	}
+
+	// Directories and client options stay as they are until restart
+	settings.DownloadDir = old.DownloadDir
+	settings.StateFile = old.StateFile
+	settings.Seed = old.Seed
+	settings.EnableDHT = old.EnableDHT
+	settings.EnableIPv6 = old.EnableIPv6
+	settings.EnableTCP = old.EnableTCP
+	settings.EnableUTP = old.EnableUTP
+	settings.Encryption = old.Encryption
+	settings.Proxy = old.Proxy
+	e.settings = settings
+	e.settingsMutex.Unlock()
+
+	if settings.BindInterface != old.BindInterface {
+		select {
+		case e.networkChanged <- struct{}{}:
+		default:
+		}
+	}
+	// The client is rebuilt on the new port without losing torrents
+	if settings.ListenPort != old.ListenPort || settings.PortForwarding != old.PortForwarding {
+		e.signalPortChange()
+	}
+
+	// Limits may have changed without the schedule switching profiles
+	if !e.applySchedule(time.Now()) && settings.Bandwidth != old.Bandwidth {
+		e.applyGlobalLimits()
+	}
+	if settings.Queue != old.Queue {
+		e.updateQueue()
+	}
+	if !slices.Equal(settings.BannedPeers, old.BannedPeers) {
+		e.applyBans(settings.BannedPeers)
+	}
+	if !slices.Equal(settings.Blocklists, old.Blocklists) {
+		e.reloadBlocklists(settings.Blocklists)
+	}
+
+	return restartRequired
+}
+
+// DownloadDir returns the default download directory
+func (e *Engine) DownloadDir() string {
+	return e.downloadDir
+}
+
+// Subscribe registers a handler for engine events and returns a function
+// that removes it again
+func (e *Engine) Subscribe(handler EventHandler) (unsubscribe func()) {
+	e.handlersMutex.Lock()
+	id := e.nextHandlerID
+	e.nextHandlerID++
+	e.handlers[id] = handler
+	e.handlersMutex.Unlock()
+
+	return func() {
+		e.handlersMutex.Lock()
+		delete(e.handlers, id)
+		e.handlersMutex.Unlock()
+	}
+}
+
+// emit sends an event to every subscribed handler
+func (e *Engine) emit(name string, data ...interface{}) {
+	e.handlersMutex.RLock()
+	handlers := make([]EventHandler, 0, len(e.handlers))
+	for _, handler := range e.handlers {
+		handlers = append(handlers, handler)
+	}
+	e.handlersMutex.RUnlock()
+
+	for _, handler := range handlers {
+		handler(name, data...)
+	}
+}
+
+// saveTorrentStates saves current torrent states to disk
+func (e *Engine) saveTorrentStates() {
+	// Never overwrite saved state if the client failed to start
+	if e.torrentClient() == nil {
+		return
+	}
+
+	e.torrentsMutex.RLock()
+	defer e.torrentsMutex.RUnlock()
+
+	var states []TorrentState
+	for hash, t := range e.torrents {
+		states = append(states, e.buildTorrentState(hash, t))
+	}
+
+	if err := e.stateStore.Save(states); err != nil {
+		log.Printf("Error saving torrent states: %v", err)
+		return
+	}
+
+	log.Printf("✓ Saved %d torrent states", len(states))
+}
+
+// loadSavedTorrents loads previously saved torrents
+func (e *Engine) loadSavedTorrents() {
+	states, err := e.stateStore.Load()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading torrent states: %v", err)
+		}
+		return
+	}
+
+	// Restore the queue order; torrents saved without a position go last
+	slices.SortStableFunc(states, func(a, b TorrentState) int {
+		if a.QueuePosition == 0 || b.QueuePosition == 0 {
+			return b.QueuePosition - a.QueuePosition
+		}
+		return a.QueuePosition - b.QueuePosition
+	})
+
+	log.Printf("Loading %d saved torrents...", len(states))
+	for _, state := range states {
+		t, err := e.restoreTorrent(state)
+		if err != nil {
+			log.Printf("Error re-adding torrent %s: %v", state.InfoHash, err)
+			continue
+		}
+
+		hash := t.InfoHash().String()
+
+		// Initialize trackers
+		e.speedsMutex.Lock()
+		e.downloadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
+		e.uploadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
+		e.speedsMutex.Unlock()
+
+		e.sessionsMutex.Lock()
+		e.sessions[hash] = state.session()
+		e.sessionsMutex.Unlock()
+
+		e.torrentsMutex.Lock()
+		e.torrents[hash] = t
+		e.torrentsMutex.Unlock()
+
+		e.enqueue(hash)
+
+		// Restore paused state
+		if state.IsPaused {
+			e.pausedMutex.Lock()
+			e.pausedTorrents[hash] = true
+			e.pausedMutex.Unlock()
+		}
+
+		// Wait for info, then start downloading the chosen files
+		go e.startWhenReady(hash, t)
+
+		log.Printf("✓ Restored torrent: %s (paused: %v, metadata: %v)", hash, state.IsPaused, len(state.InfoBytes) > 0)
+	}
+
+	e.updateQueue()
+}
+
+// registerSession records where a torrent stores its data
+func (e *Engine) registerSession(hash, storageRoot, pathMode string) {
+	e.sessionsMutex.Lock()
+	defer e.sessionsMutex.Unlock()
+
+	if _, exists := e.sessions[hash]; exists {
+		return
+	}
+	e.sessions[hash] = &torrentSession{
+		storageRoot: storageRoot,
+		pathMode:    pathMode,
+		addedAt:     time.Now(),
+	}
+}
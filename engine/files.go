@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/anacrolix/torrent"
+)
+
+// File priorities accepted by SetFilePriority. Skipped files are not
+// downloaded; the others are fetched in priority order.
+const (
+	FilePrioritySkip   = "skip"
+	FilePriorityLow    = "low"
+	FilePriorityNormal = "normal"
+	FilePriorityHigh   = "high"
+)
+
+// ErrInvalidFilePriority is returned for an unknown file priority
+var ErrInvalidFilePriority = errors.New("file priority must be skip, low, normal or high")
+
+// piecePriorities maps file priorities onto the client's piece priorities.
+// The levels above high are left to readers streaming a file.
+var piecePriorities = map[string]torrent.PiecePriority{
+	FilePrioritySkip:   torrent.PiecePriorityNone,
+	FilePriorityLow:    torrent.PiecePriorityNormal,
+	FilePriorityNormal: torrent.PiecePriorityHigh,
+	FilePriorityHigh:   torrent.PiecePriorityReadahead,
+}
+
+// filePriority returns the priority of file i. Files without a saved
+// priority are downloaded normally.
+func (s *torrentSession) filePriority(i int) string {
+	if i < len(s.filePriorities) {
+		if _, ok := piecePriorities[s.filePriorities[i]]; ok {
+			return s.filePriorities[i]
+		}
+	}
+	return FilePriorityNormal
+}
+
+// wantedBytes returns how much of the files not skipped is complete and
+// how large they are together
+func (s *torrentSession) wantedBytes(t *torrent.Torrent) (completed, wanted int64) {
+	if t.Info() == nil {
+		return 0, 0
+	}
+	if len(s.filePriorities) == 0 {
+		return t.BytesCompleted(), t.Length()
+	}
+	for i, file := range t.Files() {
+		if s.filePriority(i) == FilePrioritySkip {
+			continue
+		}
+		completed += file.BytesCompleted()
+		wanted += file.Length()
+	}
+	return completed, wanted
+}
+
+// downloadDone reports whether every file that is not skipped has been
+// downloaded
+func (s *torrentSession) downloadDone(t *torrent.Torrent) bool {
+	completed, wanted := s.wantedBytes(t)
+	return t.Info() != nil && t.Length() > 0 && completed >= wanted
+}
+
+// startDownload requests the torrent's files at their chosen priorities.
+// It replaces DownloadAll, which would also fetch skipped files. Torrents
+// dropped from the client are left alone.
+func (e *Engine) startDownload(hash string, t *torrent.Torrent) {
+	if t.Info() == nil || isDetached(t) {
+		return
+	}
+	session := e.getSession(hash)
+	for i, file := range t.Files() {
+		file.SetPriority(piecePriorities[session.filePriority(i)])
+	}
+}
+
+// stopDownload drops every file and piece request so the torrent stops
+// downloading. The chosen priorities stay in the session for startDownload.
+func stopDownload(t *torrent.Torrent) {
+	if t.Info() == nil || isDetached(t) {
+		return
+	}
+	for _, file := range t.Files() {
+		file.SetPriority(torrent.PiecePriorityNone)
+	}
+	t.CancelPieces(0, t.NumPieces())
+}
+
+// SetFilePriority sets the priority of the file at index within a
+// torrent. Skipping a file stops it from being downloaded.
+func (e *Engine) SetFilePriority(infoHash string, index int, priority string) error {
+	if _, ok := piecePriorities[priority]; !ok {
+		return ErrInvalidFilePriority
+	}
+
+	return e.updateFilePriorities(infoHash, func(priorities []string) error {
+		if index < 0 || index >= len(priorities) {
+			return fmt.Errorf("file index %d out of range", index)
+		}
+		priorities[index] = priority
+		return nil
+	})
+}
+
+// SelectFiles downloads only the files at the given indices and skips the
+// rest. Selected files keep their priority unless they were skipped.
+func (e *Engine) SelectFiles(infoHash string, indices []int) error {
+	return e.updateFilePriorities(infoHash, func(priorities []string) error {
+		for _, index := range indices {
+			if index < 0 || index >= len(priorities) {
+				return fmt.Errorf("file index %d out of range", index)
+			}
+		}
+		for i, priority := range priorities {
+			switch {
+			case !slices.Contains(indices, i):
+				priorities[i] = FilePrioritySkip
+			case priority == FilePrioritySkip:
+				priorities[i] = FilePriorityNormal
+			}
+		}
+		return nil
+	})
+}
+
+// updateFilePriorities lets change edit a copy of a torrent's file
+// priorities, then stores and applies the result
+func (e *Engine) updateFilePriorities(infoHash string, change func(priorities []string) error) error {
+	e.torrentsMutex.RLock()
+	t, exists := e.torrents[infoHash]
+	e.torrentsMutex.RUnlock()
+
+	if !exists {
+		return ErrTorrentNotFound
+	}
+	if t.Info() == nil {
+		return fmt.Errorf("torrent metadata not available yet")
+	}
+
+	session := e.getSession(infoHash)
+	priorities := make([]string, len(t.Files()))
+	for i := range priorities {
+		priorities[i] = session.filePriority(i)
+	}
+	if err := change(priorities); err != nil {
+		return err
+	}
+
+	e.sessionsMutex.Lock()
+	if session, ok := e.sessions[infoHash]; ok {
+		session.filePriorities = priorities
+	}
+	e.sessionsMutex.Unlock()
+
+	// Stopped torrents pick up the new priorities when they start again
+	if !e.isStopped(infoHash) {
+		e.startDownload(infoHash, t)
+	}
+	e.updateQueue()
+	e.saveTorrentStates()
+
+	log.Printf("✓ File priorities for %s: %v", t.Name(), priorities)
+	e.emit("torrent-updated", infoHash)
+	return nil
+}
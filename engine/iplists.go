@@ -0,0 +1,290 @@
+package engine
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"torrentflow/config"
+)
+
+// Formats of IP filter files
+const (
+	BlocklistFormatDAT  = "dat"  // eMule ipfilter.dat
+	BlocklistFormatP2P  = "p2p"  // PeerGuardian text
+	BlocklistFormatCIDR = "cidr" // One address, prefix or range per line
+)
+
+// blocklistCheckInterval is how often filter files are checked for changes
+const blocklistCheckInterval = 10 * time.Second
+
+// datMaxBlockedLevel is the highest eMule access level that is blocked
+const datMaxBlockedLevel = 127
+
+// BlocklistInfo describes an IP filter file in use
+type BlocklistInfo struct {
+	Path   string `json:"path"`
+	Format string `json:"format"`
+	Ranges int    `json:"ranges"`
+	// SkippedLines could not be read in any of the known formats
+	SkippedLines int `json:"skippedLines"`
+	// Blocked counts the connections refused since the engine started,
+	// across reloads of the file: incoming connections, dials, peers added
+	// by hand and connected peers dropped when the list loads. Addresses
+	// the client merely hears of aren't counted.
+	Blocked  int64     `json:"blocked"`
+	LoadedAt time.Time `json:"loadedAt"`
+	// Error is set when the file could not be read; the ranges of the last
+	// good read stay in force
+	Error string `json:"error,omitempty"`
+}
+
+// ipList is an IP filter file loaded into the blocklist. Lists are not
+// changed once loaded, apart from the blocked count.
+type ipList struct {
+	path     string
+	format   string
+	ranges   ipSet
+	skipped  int
+	modTime  time.Time
+	size     int64
+	loadedAt time.Time
+	err      error
+	blocked  atomic.Int64
+}
+
+// stale reports whether the file changed since the list was loaded
+func (l *ipList) stale() bool {
+	stat, err := os.Stat(l.path)
+	if err != nil {
+		// Report a vanished file once
+		return l.err == nil
+	}
+	return !stat.ModTime().Equal(l.modTime) || stat.Size() != l.size
+}
+
+func (l *ipList) info() BlocklistInfo {
+	info := BlocklistInfo{
+		Path:         l.path,
+		Format:       l.format,
+		Ranges:       len(l.ranges),
+		SkippedLines: l.skipped,
+		Blocked:      l.blocked.Load(),
+		LoadedAt:     l.loadedAt,
+	}
+	if l.err != nil {
+		info.Error = l.err.Error()
+	}
+	return info
+}
+
+// loadIPList reads an IP filter file. prev is the list loaded from the
+// same file before, if any; its blocked count carries over, and so do its
+// ranges when the file can't be read.
+func loadIPList(path string, prev *ipList) *ipList {
+	l := &ipList{path: path, loadedAt: time.Now()}
+	if prev != nil {
+		l.blocked.Store(prev.blocked.Load())
+	}
+
+	err := func() error {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		stat, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		l.modTime = stat.ModTime()
+		l.size = stat.Size()
+
+		ranges, format, skipped, err := parseIPList(f)
+		if err != nil {
+			return err
+		}
+		if len(ranges) == 0 && skipped > 0 {
+			return fmt.Errorf("no line is in a known format")
+		}
+		l.ranges = newIPSet(ranges)
+		l.format = format
+		l.skipped = skipped
+		return nil
+	}()
+
+	if err != nil {
+		l.err = err
+		if prev != nil {
+			l.ranges = prev.ranges
+			l.format = prev.format
+			l.skipped = prev.skipped
+		}
+		log.Printf("⚠ Failed to load blocklist %s: %v", path, err)
+		return l
+	}
+
+	log.Printf("✓ Loaded blocklist %s: %d ranges (%s), %d lines skipped", path, len(l.ranges), l.format, l.skipped)
+	return l
+}
+
+// parseIPList reads the ranges of an IP filter file, which may be gzipped.
+// The format is worked out for each line; the one of the first range is
+// reported.
+func parseIPList(r io.Reader) (ranges []ipRange, format string, skipped int, err error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, "", 0, err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+
+	scanner := bufio.NewScanner(br)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		r, lineFormat, blocked, ok := parseIPListLine(line)
+		switch {
+		case !ok:
+			skipped++
+		case blocked:
+			ranges = append(ranges, r)
+			if format == "" {
+				format = lineFormat
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", 0, err
+	}
+	return ranges, format, skipped, nil
+}
+
+// parseIPListLine reads one line of an IP filter file. Lines of eMule
+// lists with an access level above datMaxBlockedLevel parse but are not
+// blocked.
+func parseIPListLine(line string) (r ipRange, format string, blocked, ok bool) {
+	// eMule: "001.002.003.000 - 001.002.003.255 , 000 , Description"
+	if fields := strings.Split(line, ","); len(fields) >= 2 {
+		if from, to, found := strings.Cut(fields[0], "-"); found {
+			level, err := strconv.Atoi(strings.TrimSpace(fields[1]))
+			if r, err2 := parseAddrRange(from, to); err == nil && err2 == nil {
+				return r, BlocklistFormatDAT, level <= datMaxBlockedLevel, true
+			}
+		}
+	}
+
+	// Plain lists: "1.2.3.0/24", "1.2.3.4" or "1.2.3.0-1.2.3.255"
+	plain, _, _ := strings.Cut(line, "#")
+	if first, last, err := config.ParseIPRange(plain); err == nil {
+		return ipRange{first: first, last: last}, BlocklistFormatCIDR, true, true
+	}
+
+	// PeerGuardian: "Description:1.2.3.0-1.2.3.255". Both the description
+	// and IPv6 addresses may hold colons, so try each in turn.
+	for i := range line {
+		if line[i] != ':' {
+			continue
+		}
+		if from, to, found := strings.Cut(line[i+1:], "-"); found {
+			if r, err := parseAddrRange(from, to); err == nil {
+				return r, BlocklistFormatP2P, true, true
+			}
+		}
+	}
+
+	return ipRange{}, "", false, false
+}
+
+// parseAddrRange parses the two ends of an inclusive range
+func parseAddrRange(from, to string) (ipRange, error) {
+	first, err := parsePaddedAddr(from)
+	if err != nil {
+		return ipRange{}, err
+	}
+	last, err := parsePaddedAddr(to)
+	if err != nil {
+		return ipRange{}, err
+	}
+	if first.Is4() != last.Is4() || last.Less(first) {
+		return ipRange{}, fmt.Errorf("%s-%s is not a range", first, last)
+	}
+	return ipRange{first: first, last: last}, nil
+}
+
+// parsePaddedAddr parses an address, allowing the zero padded IPv4
+// octets eMule lists use ("001.002.003.004")
+func parsePaddedAddr(s string) (netip.Addr, error) {
+	s = strings.TrimSpace(s)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap(), nil
+	}
+
+	octets := strings.Split(s, ".")
+	if len(octets) != 4 {
+		return netip.Addr{}, fmt.Errorf("%q is not an IP address", s)
+	}
+	var b [4]byte
+	for i, octet := range octets {
+		n, err := strconv.ParseUint(octet, 10, 8)
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("%q is not an IP address", s)
+		}
+		b[i] = byte(n)
+	}
+	return netip.AddrFrom4(b), nil
+}
+
+// GetBlocklists returns the IP filter files in use and how many
+// connection attempts each of them refused
+func (e *Engine) GetBlocklists() []BlocklistInfo {
+	e.blocklist.mu.RLock()
+	defer e.blocklist.mu.RUnlock()
+
+	infos := make([]BlocklistInfo, 0, len(e.blocklist.lists))
+	for _, l := range e.blocklist.lists {
+		infos = append(infos, l.info())
+	}
+	return infos
+}
+
+// reloadBlocklists loads filter files that are new or changed on disk
+// and disconnects peers they now block
+func (e *Engine) reloadBlocklists(paths []string) {
+	if !e.blocklist.setLists(paths) {
+		return
+	}
+	e.disconnectBlocked()
+	e.emit("blocklists-updated", e.GetBlocklists())
+}
+
+// blocklistLoop reloads filter files when they change on disk
+func (e *Engine) blocklistLoop() {
+	ticker := time.NewTicker(blocklistCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			e.reloadBlocklists(e.Settings().Blocklists)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseIPListLine(t *testing.T) {
+	tests := []struct {
+		line        string
+		first, last string
+		format      string
+		blocked     bool
+		ok          bool
+	}{
+		// eMule, blocked up to access level 127
+		{"001.002.003.000 - 001.002.003.255 , 000 , Bad range", "1.2.3.0", "1.2.3.255", BlocklistFormatDAT, true, true},
+		{"1.2.3.0 - 1.2.3.255 , 127 , Bad range", "1.2.3.0", "1.2.3.255", BlocklistFormatDAT, true, true},
+		{"1.2.3.0 - 1.2.3.255 , 128 , Allowed range", "1.2.3.0", "1.2.3.255", BlocklistFormatDAT, false, true},
+		{"1.2.3.0 - 1.2.3.255 , 255", "1.2.3.0", "1.2.3.255", BlocklistFormatDAT, false, true},
+		{"1.2.3.0 - 1.2.3.255 , 100 , Description, with, commas", "1.2.3.0", "1.2.3.255", BlocklistFormatDAT, true, true},
+		{"2001:db8:: - 2001:db8::ffff , 0 , IPv6", "2001:db8::", "2001:db8::ffff", BlocklistFormatDAT, true, true},
+
+		// PeerGuardian, with colons in the description and the addresses
+		{"Bad peers:1.2.3.0-1.2.3.255", "1.2.3.0", "1.2.3.255", BlocklistFormatP2P, true, true},
+		{"Bad: peers: at 10:30:1.2.3.0-1.2.3.255", "1.2.3.0", "1.2.3.255", BlocklistFormatP2P, true, true},
+		{"Bad peers:2001:db8::-2001:db8::ffff", "2001:db8::", "2001:db8::ffff", BlocklistFormatP2P, true, true},
+		{"Time 12:00 - noon:001.002.003.004-001.002.003.004", "1.2.3.4", "1.2.3.4", BlocklistFormatP2P, true, true},
+
+		// Plain lists
+		{"1.2.3.4", "1.2.3.4", "1.2.3.4", BlocklistFormatCIDR, true, true},
+		{"1.2.3.0/24", "1.2.3.0", "1.2.3.255", BlocklistFormatCIDR, true, true},
+		{"1.2.3.4/24", "1.2.3.0", "1.2.3.255", BlocklistFormatCIDR, true, true},
+		{"1.2.3.0-1.2.3.9 # trailing comment", "1.2.3.0", "1.2.3.9", BlocklistFormatCIDR, true, true},
+		{"2001:db8::/32", "2001:db8::", "2001:db8:ffff:ffff:ffff:ffff:ffff:ffff", BlocklistFormatCIDR, true, true},
+		{"2001:db8::1/128", "2001:db8::1", "2001:db8::1", BlocklistFormatCIDR, true, true},
+		{"::ffff:1.2.3.4", "1.2.3.4", "1.2.3.4", BlocklistFormatCIDR, true, true},
+
+		// Not ranges
+		{"1.2.3.255 - 1.2.3.0 , 0 , Backwards", "", "", "", false, false},
+		{"1.2.3.0 - 2001:db8:: , 0 , Mixed families", "", "", "", false, false},
+		{"Bad peers:1.2.3.0-1.2.3", "", "", "", false, false},
+		{"Bad peers", "", "", "", false, false},
+		{"1.2.3.256", "", "", "", false, false},
+	}
+	for _, tt := range tests {
+		r, format, blocked, ok := parseIPListLine(tt.line)
+		if ok != tt.ok || blocked != tt.blocked || format != tt.format {
+			t.Errorf("parseIPListLine(%q) = format %q, blocked %v, ok %v; want %q, %v, %v", tt.line, format, blocked, ok, tt.format, tt.blocked, tt.ok)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if want := testRange(tt.first, tt.last); r != want {
+			t.Errorf("parseIPListLine(%q) = %s-%s, want %s-%s", tt.line, r.first, r.last, tt.first, tt.last)
+		}
+	}
+}
+
+func TestParseIPList(t *testing.T) {
+	list := strings.Join([]string{
+		"# A comment",
+		"// Another",
+		"",
+		"1.2.3.0 - 1.2.3.255 , 000 , Blocked",
+		"4.5.6.0 - 4.5.6.255 , 200 , Allowed",
+		"Bad peers:7.8.9.0-7.8.9.255",
+		"not an address",
+		"10.0.0.0/8",
+	}, "\n")
+	want := []ipRange{
+		testRange("1.2.3.0", "1.2.3.255"),
+		testRange("7.8.9.0", "7.8.9.255"),
+		testRange("10.0.0.0", "10.255.255.255"),
+	}
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(list))
+	gz.Close()
+
+	for name, data := range map[string][]byte{"plain": []byte(list), "gzipped": gzipped.Bytes()} {
+		ranges, format, skipped, err := parseIPList(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !slices.Equal(ranges, want) || format != BlocklistFormatDAT || skipped != 1 {
+			t.Errorf("%s: parsed %v as %q with %d lines skipped, want %v as %q with 1", name, ranges, format, skipped, want, BlocklistFormatDAT)
+		}
+	}
+}
+
+// testRange returns the range from first to last
+func testRange(first, last string) ipRange {
+	return ipRange{first: netip.MustParseAddr(first), last: netip.MustParseAddr(last)}
+}
@@ -0,0 +1,275 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// moveProgressInterval limits how often "storage-move-progress" is sent
+// while a large file is copied
+const moveProgressInterval = 250 * time.Millisecond
+
+// ErrTorrentBusy is returned while a torrent's storage is being moved or
+// its data is being checked
+var ErrTorrentBusy = errors.New("torrent is busy moving or checking its data")
+
+// MoveProgress is sent with the "storage-move-progress" event while a
+// torrent's files are moved, and with "storage-moved" once it is done
+type MoveProgress struct {
+	InfoHash   string  `json:"infoHash"`
+	File       string  `json:"file"`
+	BytesMoved int64   `json:"bytesMoved"`
+	TotalBytes int64   `json:"totalBytes"`
+	Progress   float64 `json:"progress"`
+	// StorageRoot is where the data is stored once the move is done
+	StorageRoot string `json:"storageRoot,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// storageMove tracks the progress of one move
+type storageMove struct {
+	e        *Engine
+	progress MoveProgress
+	lastEmit time.Time
+}
+
+// MoveTorrentStorage moves a torrent's files below newDir. The move runs
+// in the background: the torrent is stopped, its files are moved (copied
+// and removed across filesystems), and it is re-added with the new
+// storage, verified and started again unless it was paused. A torrent
+// that can't be re-added is left paused and "torrent-error" is sent.
+func (e *Engine) MoveTorrentStorage(infoHash, newDir string) error {
+	if newDir == "" || !filepath.IsAbs(newDir) {
+		return fmt.Errorf("new directory must be an absolute path")
+	}
+	newDir = filepath.Clean(newDir)
+
+	e.torrentsMutex.RLock()
+	t, exists := e.torrents[infoHash]
+	e.torrentsMutex.RUnlock()
+
+	if !exists {
+		return ErrTorrentNotFound
+	}
+	if t.Info() == nil {
+		return fmt.Errorf("torrent metadata not available yet")
+	}
+
+	if err := os.MkdirAll(newDir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	e.sessionsMutex.Lock()
+	session, ok := e.sessions[infoHash]
+	switch {
+	case !ok:
+		e.sessionsMutex.Unlock()
+		return ErrTorrentNotFound
+	case session.busy():
+		e.sessionsMutex.Unlock()
+		return ErrTorrentBusy
+	case session.pathMode == pathModeDefault && filepath.Clean(session.root(e.downloadDir)) == newDir:
+		e.sessionsMutex.Unlock()
+		return nil
+	}
+	session.moving = true
+	e.sessionsMutex.Unlock()
+
+	go e.moveStorage(infoHash, t, newDir)
+
+	e.emit("torrent-updated", infoHash)
+	return nil
+}
+
+// moveStorage does the work of MoveTorrentStorage. Moved torrents always
+// use the default layout below their new root.
+func (e *Engine) moveStorage(hash string, t *torrent.Torrent, newDir string) {
+	session := e.getSession(hash)
+	oldRoot := session.root(e.downloadDir)
+	src := session.filePaths(e.downloadDir, t)
+	dst := (&torrentSession{storageRoot: newDir, pathMode: pathModeDefault}).filePaths(e.downloadDir, t)
+
+	move := &storageMove{
+		e:        e,
+		progress: MoveProgress{InfoHash: hash, TotalBytes: t.Length()},
+	}
+
+	log.Printf("📦 Moving %s from %s to %s", t.Name(), oldRoot, newDir)
+
+	// Stop transfers and release the files
+	state := e.buildTorrentState(hash, t)
+	e.detachTorrent(t)
+
+	e.queueMutex.Lock()
+	delete(e.queuedTorrents, hash)
+	e.queueMutex.Unlock()
+
+	moveErr := moveFiles(src, dst, move)
+	if moveErr == nil {
+		state.StorageRoot = newDir
+		state.PathMode = pathModeDefault
+		for _, path := range src {
+			pruneEmptyDirs(filepath.Dir(path), oldRoot)
+		}
+	} else {
+		log.Printf("❌ Failed to move %s: %v", t.Name(), moveErr)
+	}
+
+	// Paused torrents are re-added too so their data can be verified
+	state.IsPaused = false
+	newT, err := e.reattachTorrent(hash, state)
+	if err != nil {
+		// Nothing left to fall back to. The torrent stays out of the
+		// client as a paused one that knows where its data went, so
+		// resuming it picks up from there.
+		log.Printf("❌ Failed to re-add %s after move: %v", hash, err)
+		moveErr = errors.Join(moveErr, err)
+		newT = t
+
+		e.pausedMutex.Lock()
+		e.pausedTorrents[hash] = true
+		e.pausedMutex.Unlock()
+
+		e.sessionsMutex.Lock()
+		if s, ok := e.sessions[hash]; ok {
+			s.storageRoot = state.StorageRoot
+			s.pathMode = state.PathMode
+		}
+		e.sessionsMutex.Unlock()
+
+		e.emit("torrent-error", map[string]string{
+			"infoHash": hash,
+			"error":    fmt.Sprintf("failed to restart after moving storage: %v", err),
+		})
+	}
+
+	if newT != t {
+		<-newT.GotInfo()
+		newT.VerifyData()
+		if e.isPaused(hash) {
+			e.detachTorrent(newT)
+		}
+	}
+
+	e.sessionsMutex.Lock()
+	if s, ok := e.sessions[hash]; ok {
+		s.moving = false
+	}
+	e.sessionsMutex.Unlock()
+
+	e.updateQueue()
+	if newT != t && !e.isStopped(hash) {
+		e.startDownload(hash, newT)
+	}
+	e.saveTorrentStates()
+
+	move.progress.File = ""
+	move.progress.StorageRoot = state.StorageRoot
+	if moveErr != nil {
+		move.progress.Error = moveErr.Error()
+	} else {
+		log.Printf("✓ Moved %s to %s", newT.Name(), newDir)
+	}
+	e.emit("storage-moved", move.progress)
+	e.emit("torrent-updated", hash)
+}
+
+// moveFiles moves every file from src to dst. Files that were never
+// downloaded are skipped. If a file cannot be moved the ones already
+// moved are put back.
+func moveFiles(src, dst []string, move *storageMove) error {
+	for i := range src {
+		move.progress.File = src[i]
+		if err := moveFile(src[i], dst[i], move); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				if err := moveFile(dst[j], src[j], nil); err != nil {
+					log.Printf("⚠ Could not move %s back: %v", dst[j], err)
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// moveFile renames src to dst, copying the data when they are on
+// different filesystems
+func moveFile(src, dst string, move *storageMove) error {
+	stat, err := os.Stat(src)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := os.Lstat(dst); err == nil {
+		return fmt.Errorf("%s already exists", dst)
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(src, dst); err == nil {
+		move.add(stat.Size())
+		return nil
+	}
+
+	if err := copyFileData(src, dst, stat.Mode(), move); err != nil {
+		os.Remove(dst)
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return os.Remove(src)
+}
+
+// copyFileData copies src to a new file dst, reporting progress as it goes
+func copyFileData(src, dst string, mode os.FileMode, move *storageMove) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, io.TeeReader(in, move)); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// Write counts copied bytes so the move can be used with io.TeeReader
+func (m *storageMove) Write(p []byte) (int, error) {
+	m.add(int64(len(p)))
+	return len(p), nil
+}
+
+// add records moved bytes and sends a progress event now and then. A
+// nil move (used when rolling back) ignores progress.
+func (m *storageMove) add(n int64) {
+	if m == nil {
+		return
+	}
+	m.progress.BytesMoved += n
+	if m.progress.TotalBytes > 0 {
+		m.progress.Progress = min(float64(m.progress.BytesMoved)/float64(m.progress.TotalBytes)*100, 100)
+	}
+	if time.Since(m.lastEmit) < moveProgressInterval {
+		return
+	}
+	m.lastEmit = time.Now()
+	m.e.emit("storage-move-progress", m.progress)
+}
@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMoveStorageReattachFails(t *testing.T) {
+	e := newTestEngine(t)
+	hash := addTestTorrent(t, e, "moved", true)
+
+	events := make(chan string, 10)
+	var failure map[string]string
+	e.Subscribe(func(name string, data ...interface{}) {
+		switch name {
+		case "torrent-error":
+			failure = data[0].(map[string]string)
+			events <- name
+		case "storage-moved":
+			events <- name
+		}
+	})
+
+	// Without a client the torrent can't be added back after the move
+	e.clientMutex.Lock()
+	client := e.client
+	e.client = nil
+	e.clientMutex.Unlock()
+
+	newDir := t.TempDir()
+	if err := e.MoveTorrentStorage(hash, newDir); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"torrent-error", "storage-moved"} {
+		select {
+		case name := <-events:
+			if name != want {
+				t.Fatalf("got %q event, want %q", name, want)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+	if failure["infoHash"] != hash || failure["error"] == "" {
+		t.Errorf("torrent-error = %v", failure)
+	}
+
+	if _, err := os.Stat(filepath.Join(newDir, "moved")); err != nil {
+		t.Fatalf("data was not moved: %v", err)
+	}
+	session := e.getSession(hash)
+	if got := session.root(e.downloadDir); got != newDir {
+		t.Errorf("storage root = %s, want %s", got, newDir)
+	}
+	if session.busy() {
+		t.Error("torrent still marked as moving")
+	}
+	if !e.isPaused(hash) {
+		t.Error("torrent is not paused")
+	}
+
+	// Resuming finds the data in its new place
+	e.clientMutex.Lock()
+	e.client = client
+	e.clientMutex.Unlock()
+	if err := e.ResumeTorrent(hash); err != nil {
+		t.Fatal(err)
+	}
+	e.torrentsMutex.RLock()
+	tor := e.torrents[hash]
+	e.torrentsMutex.RUnlock()
+	waitFor(t, "the moved data to be checked", func() bool {
+		session := e.getSession(hash)
+		return session.downloadDone(tor)
+	})
+
+	states, err := e.stateStore.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 1 || states[0].StorageRoot != newDir {
+		t.Errorf("saved %+v, want the storage root %s", states, newDir)
+	}
+}
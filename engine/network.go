@@ -0,0 +1,31 @@
+package engine
+
+import (
+	"torrentflow/config"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/mse"
+)
+
+// configurePeerProtocol applies the encryption settings to the client
+// config; the transports are the sockets peerSockets opens. The library's
+// defaults match the "prefer" policy: outgoing connections obfuscate their
+// headers and fall back to plaintext when the peer doesn't support it.
+func configurePeerProtocol(cfg *torrent.ClientConfig, settings config.Settings) {
+	switch settings.Encryption {
+	case config.EncryptionRequire:
+		// Refuse plaintext peers and use RC4 for the whole stream, not
+		// just the handshake
+		cfg.HeaderObfuscationPolicy = torrent.HeaderObfuscationPolicy{Preferred: true, RequirePreferred: true}
+		cfg.CryptoProvides = mse.CryptoMethodRC4
+		cfg.CryptoSelector = func(mse.CryptoMethod) mse.CryptoMethod {
+			return mse.CryptoMethodRC4
+		}
+	case config.EncryptionDisable:
+		cfg.HeaderObfuscationPolicy = torrent.HeaderObfuscationPolicy{Preferred: false, RequirePreferred: true}
+	default:
+		cfg.HeaderObfuscationPolicy = torrent.HeaderObfuscationPolicy{Preferred: true}
+		cfg.CryptoProvides = mse.AllSupportedCrypto
+		cfg.CryptoSelector = mse.DefaultCryptoSelector
+	}
+}
@@ -0,0 +1,323 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// PauseTorrent pauses a torrent. A paused torrent is taken out of the
+// client: its peers are disconnected, its trackers are told it stopped and
+// nothing is uploaded or downloaded. The dropped torrent stays in the
+// torrents map so its metadata and progress can still be shown, and is
+// added back to the client on resume.
+func (e *Engine) PauseTorrent(infoHash string) error {
+	e.torrentsMutex.RLock()
+	t, exists := e.torrents[infoHash]
+	e.torrentsMutex.RUnlock()
+
+	if !exists {
+		return ErrTorrentNotFound
+	}
+	if e.isBusy(infoHash) {
+		return ErrTorrentBusy
+	}
+
+	e.pauseTorrent(infoHash, t)
+	e.updateQueue()
+	e.saveTorrentStates()
+
+	log.Printf("⏸ Paused torrent: %s", t.Name())
+	return nil
+}
+
+// pauseTorrent stops a torrent without saving state
+func (e *Engine) pauseTorrent(infoHash string, t *torrent.Torrent) {
+	// Mark as paused first so the queue leaves it alone
+	e.pausedMutex.Lock()
+	e.pausedTorrents[infoHash] = true
+	e.pausedMutex.Unlock()
+
+	// The queue decides again once the torrent is resumed
+	e.queueMutex.Lock()
+	delete(e.queuedTorrents, infoHash)
+	e.queueMutex.Unlock()
+
+	e.detachTorrent(t)
+}
+
+// pausedBySchedule and pausedByNetwork select the flag of a pause reason
+// in a session
+func pausedBySchedule(s *torrentSession) *bool { return &s.scheduledPause }
+func pausedByNetwork(s *torrentSession) *bool  { return &s.networkPause }
+
+// pauseFor pauses a running torrent for the schedule or the kill-switch
+// and sets the reason's flag. A torrent one of them already paused gets
+// the flag too, so it stays paused until both let go of it. Torrents
+// paused by the user are left alone. It reports whether the torrent was
+// stopped.
+func (e *Engine) pauseFor(hash string, t *torrent.Torrent, reason func(*torrentSession) *bool) bool {
+	session := e.getSession(hash)
+	held := session.scheduledPause || session.networkPause
+
+	stopped := false
+	if !e.isPaused(hash) {
+		e.pauseTorrent(hash, t)
+		stopped = true
+	} else if !held {
+		return false
+	}
+
+	e.sessionsMutex.Lock()
+	if session, ok := e.sessions[hash]; ok {
+		*reason(session) = true
+	}
+	e.sessionsMutex.Unlock()
+	return stopped
+}
+
+// releasePause clears the flag of a pause reason and resumes the torrent
+// once no other reason holds it. It reports whether the torrent resumed.
+func (e *Engine) releasePause(hash string, t *torrent.Torrent, reason func(*torrentSession) *bool) (bool, error) {
+	e.sessionsMutex.Lock()
+	session, ok := e.sessions[hash]
+	if !ok || !*reason(session) {
+		e.sessionsMutex.Unlock()
+		return false, nil
+	}
+	*reason(session) = false
+	held := session.scheduledPause || session.networkPause
+	e.sessionsMutex.Unlock()
+
+	if held {
+		return false, nil
+	}
+	if err := e.resumeTorrent(hash, t); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ResumeTorrent resumes a torrent
+func (e *Engine) ResumeTorrent(infoHash string) error {
+	e.torrentsMutex.RLock()
+	t, exists := e.torrents[infoHash]
+	e.torrentsMutex.RUnlock()
+
+	if !exists {
+		return ErrTorrentNotFound
+	}
+	if e.isBusy(infoHash) {
+		return ErrTorrentBusy
+	}
+
+	err := e.resumeTorrent(infoHash, t)
+	e.updateQueue()
+	e.saveTorrentStates()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("▶ Resumed torrent: %s", t.Name())
+	return nil
+}
+
+// resumeTorrent restarts a torrent without saving state. A paused
+// torrent is added back to the client and downloads its files at the
+// priorities chosen before it was paused.
+func (e *Engine) resumeTorrent(infoHash string, t *torrent.Torrent) error {
+	// Mark as not paused
+	e.pausedMutex.Lock()
+	delete(e.pausedTorrents, infoHash)
+	e.pausedMutex.Unlock()
+
+	// A manual resume overrides a scheduled or kill-switch pause
+	e.sessionsMutex.Lock()
+	if session, ok := e.sessions[infoHash]; ok {
+		session.scheduledPause = false
+		session.networkPause = false
+	}
+	e.sessionsMutex.Unlock()
+
+	if isDetached(t) {
+		newT, err := e.reattachTorrent(infoHash, e.buildTorrentState(infoHash, t))
+		if err != nil {
+			e.pausedMutex.Lock()
+			e.pausedTorrents[infoHash] = true
+			e.pausedMutex.Unlock()
+			return fmt.Errorf("failed to restart torrent: %w", err)
+		}
+		t = newT
+	}
+
+	// Magnets still fetching metadata start once it arrives
+	if t.Info() == nil {
+		go e.startWhenReady(infoHash, t)
+		return nil
+	}
+
+	// Start downloading the chosen files, unless the queue holds it back
+	if !e.isQueued(infoHash) {
+		e.startDownload(infoHash, t)
+	}
+	return nil
+}
+
+// PauseAll pauses every torrent that is running or queued
+func (e *Engine) PauseAll() {
+	e.torrentsMutex.RLock()
+	torrents := make(map[string]*torrent.Torrent, len(e.torrents))
+	for hash, t := range e.torrents {
+		torrents[hash] = t
+	}
+	e.torrentsMutex.RUnlock()
+
+	paused := 0
+	for hash, t := range torrents {
+		if e.isPaused(hash) || e.isBusy(hash) {
+			continue
+		}
+		e.pauseTorrent(hash, t)
+		paused++
+	}
+
+	e.updateQueue()
+	e.saveTorrentStates()
+
+	log.Printf("⏸ Paused %d torrent(s)", paused)
+}
+
+// ResumeAll resumes every paused torrent, including those paused by the
+// schedule. Torrents that fail to restart stay paused.
+func (e *Engine) ResumeAll() error {
+	e.torrentsMutex.RLock()
+	torrents := make(map[string]*torrent.Torrent, len(e.torrents))
+	for hash, t := range e.torrents {
+		torrents[hash] = t
+	}
+	e.torrentsMutex.RUnlock()
+
+	var errs []error
+	resumed := 0
+	for hash, t := range torrents {
+		if !e.isPaused(hash) || e.isBusy(hash) {
+			continue
+		}
+		if err := e.resumeTorrent(hash, t); err != nil {
+			log.Printf("⚠ Failed to resume %s: %v", t.Name(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", t.Name(), err))
+			continue
+		}
+		resumed++
+	}
+
+	e.updateQueue()
+	e.saveTorrentStates()
+
+	log.Printf("▶ Resumed %d torrent(s)", resumed)
+	return errors.Join(errs...)
+}
+
+// isPaused reports whether a torrent has been paused
+func (e *Engine) isPaused(hash string) bool {
+	e.pausedMutex.RLock()
+	defer e.pausedMutex.RUnlock()
+	return e.pausedTorrents[hash]
+}
+
+// detachTorrent takes a torrent out of the client. Dropping it closes
+// every peer connection and announces "stopped" to its trackers. Pausing,
+// removing, the schedule, the kill-switch and rebuilding the client may
+// all detach the same torrent at once, and dropping it twice panics, so
+// the check and the drop happen under a lock of the torrent's own. The
+// client read lock keeps the client from closing the torrent meanwhile.
+func (e *Engine) detachTorrent(t *torrent.Torrent) {
+	lock, _ := e.detachLocks.LoadOrStore(t, &sync.Mutex{})
+	mu := lock.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+	// Whoever comes next finds the torrent detached
+	defer e.detachLocks.Delete(t)
+
+	e.clientMutex.RLock()
+	defer e.clientMutex.RUnlock()
+
+	if isDetached(t) {
+		return
+	}
+	stopDownload(t)
+	t.DisallowDataDownload()
+	t.DisallowDataUpload()
+	t.Drop()
+}
+
+// isDetached reports whether a torrent has been dropped from the client
+func isDetached(t *torrent.Torrent) bool {
+	select {
+	case <-t.Closed():
+		return true
+	default:
+		return false
+	}
+}
+
+// reattachTorrent adds a torrent back to the client from its saved state
+// and puts it in place of the old one. The new torrent counts transfers
+// from zero, so the session takes the saved totals as its base.
+func (e *Engine) reattachTorrent(hash string, state TorrentState) (*torrent.Torrent, error) {
+	t, err := e.restoreTorrent(state)
+	if err != nil {
+		return nil, err
+	}
+
+	e.speedsMutex.Lock()
+	e.downloadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
+	e.uploadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
+	e.speedsMutex.Unlock()
+
+	e.torrentsMutex.Lock()
+	if _, ok := e.torrents[hash]; ok {
+		e.torrents[hash] = t
+	}
+	e.torrentsMutex.Unlock()
+
+	e.sessionsMutex.Lock()
+	if s, ok := e.sessions[hash]; ok {
+		s.storageRoot = state.StorageRoot
+		s.pathMode = state.PathMode
+		s.trackers = state.Trackers
+		s.peers = state.Peers
+		s.baseUploaded = state.BytesUploaded
+		s.baseDownloaded = state.BytesDownloaded
+	}
+	e.sessionsMutex.Unlock()
+
+	return t, nil
+}
+
+// startWhenReady starts downloading once a torrent has its metadata,
+// unless it is stopped by then. It gives up if the torrent is dropped
+// first.
+func (e *Engine) startWhenReady(hash string, t *torrent.Torrent) {
+	fetching := t.Info() == nil
+	select {
+	case <-t.GotInfo():
+	case <-t.Closed():
+		return
+	}
+
+	e.updateQueue()
+	if !e.isStopped(hash) {
+		e.startDownload(hash, t)
+	}
+
+	if fetching {
+		// Keep the metadata so it is not fetched again
+		e.saveTorrentStates()
+		e.emit("torrent-updated", hash)
+	}
+}
@@ -0,0 +1,447 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	pp "github.com/anacrolix/torrent/peer_protocol"
+)
+
+// ErrPeerNotConnected is returned when a torrent has no peer at the given
+// address
+var ErrPeerNotConnected = errors.New("peer not connected")
+
+// Where a peer was found
+const (
+	PeerSourceTracker   = "tracker"
+	PeerSourceDHT       = "dht"
+	PeerSourcePEX       = "pex"
+	PeerSourceIncoming  = "incoming"
+	PeerSourceManual    = "manual"
+	PeerSourceHolepunch = "holepunch"
+	PeerSourceUnknown   = "unknown"
+)
+
+// PeerInfo describes a peer connected to a torrent
+type PeerInfo struct {
+	Address   string `json:"address"`
+	Port      int    `json:"port"`
+	Client    string `json:"client"`
+	Encrypted bool   `json:"encrypted"`
+	UTP       bool   `json:"utp"`
+	Incoming  bool   `json:"incoming"`
+	// Choked is set while the peer refuses to send us data
+	Choked bool `json:"choked"`
+	// Interested is set while the peer wants data from us
+	Interested bool `json:"interested"`
+	// Progress is how much of the torrent the peer has
+	Progress      float64 `json:"progress"`
+	DownloadSpeed int64   `json:"downloadSpeed"`
+	// UploadSpeed is the torrent's upload speed split between its peers by
+	// how much each of them requests from us
+	UploadSpeed      int64  `json:"uploadSpeed"`
+	DownloadSpeedStr string `json:"downloadSpeedStr"`
+	UploadSpeedStr   string `json:"uploadSpeedStr"`
+	Source           string `json:"source"`
+}
+
+// peerStats is what the engine learns about a peer from client callbacks.
+// The torrent library keeps choking state and per-peer counters private.
+type peerStats struct {
+	choked     bool
+	interested bool
+	downloaded int64 // Useful data received
+	requested  int64 // Data the peer asked us for, less what it cancelled
+	download   speedTracker
+	requests   speedTracker // Smoothed, peers request in bursts
+}
+
+// trackPeers registers the client callbacks that feed GetPeers
+func (e *Engine) trackPeers(callbacks *torrent.Callbacks) {
+	callbacks.ReadMessage = func(pc *torrent.PeerConn, msg *pp.Message) {
+		e.peersMutex.Lock()
+		defer e.peersMutex.Unlock()
+
+		stats := e.peerStatsLocked(&pc.Peer)
+		switch msg.Type {
+		case pp.Choke:
+			stats.choked = true
+		case pp.Unchoke:
+			stats.choked = false
+		case pp.Interested:
+			stats.interested = true
+		case pp.NotInterested:
+			stats.interested = false
+		case pp.Request:
+			stats.requested += int64(msg.Length)
+		case pp.Cancel:
+			stats.requested -= int64(msg.Length)
+		}
+	}
+	callbacks.ReceivedUsefulData = append(callbacks.ReceivedUsefulData, func(event torrent.ReceivedUsefulDataEvent) {
+		e.peersMutex.Lock()
+		e.peerStatsLocked(event.Peer).downloaded += int64(len(event.Message.Piece))
+		e.peersMutex.Unlock()
+	})
+	callbacks.PeerClosed = append(callbacks.PeerClosed, func(p *torrent.Peer) {
+		e.peersMutex.Lock()
+		delete(e.peers, p)
+		e.peersMutex.Unlock()
+	})
+}
+
+// peerStatsLocked returns the stats of a peer, creating them on first
+// use. Peers start out choked and not interested. peersMutex must be held.
+func (e *Engine) peerStatsLocked(p *torrent.Peer) *peerStats {
+	stats, ok := e.peers[p]
+	if !ok {
+		now := time.Now()
+		stats = &peerStats{
+			choked:   true,
+			download: speedTracker{lastTime: now},
+			requests: speedTracker{lastTime: now},
+		}
+		e.peers[p] = stats
+	}
+	return stats
+}
+
+// updatePeerSpeeds works out the speed of every peer since the last call
+func (e *Engine) updatePeerSpeeds(now time.Time) {
+	e.peersMutex.Lock()
+	defer e.peersMutex.Unlock()
+
+	for _, stats := range e.peers {
+		stats.download.update(stats.downloaded, now)
+
+		previous := stats.requests.speed
+		stats.requests.update(stats.requested, now)
+		stats.requests.speed = (previous + stats.requests.speed) / 2
+	}
+}
+
+// update sets the speed from the byte count reached at now
+func (s *speedTracker) update(bytes int64, now time.Time) {
+	elapsed := now.Sub(s.lastTime).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	s.speed = max(int64(float64(bytes-s.lastBytes)/elapsed), 0)
+	s.lastBytes = bytes
+	s.lastTime = now
+}
+
+// GetPeers returns the peers connected to a torrent, fastest first.
+// Paused torrents have no peers.
+func (e *Engine) GetPeers(infoHash string) ([]PeerInfo, error) {
+	e.torrentsMutex.RLock()
+	t, exists := e.torrents[infoHash]
+	e.torrentsMutex.RUnlock()
+
+	if !exists {
+		return nil, ErrTorrentNotFound
+	}
+
+	conns := t.PeerConns()
+	numPieces := 0
+	if t.Info() != nil {
+		numPieces = t.NumPieces()
+	}
+
+	encrypted := encryptedPeers(t)
+	peers := make([]PeerInfo, 0, len(conns))
+	requests := make([]int64, 0, len(conns))
+	var totalRequests int64
+	for _, pc := range conns {
+		peer := PeerInfo{
+			Client:    peerClientName(pc),
+			Encrypted: encrypted[pc.RemoteAddr.String()],
+			UTP:       strings.Contains(pc.Network, "udp"),
+			Incoming:  pc.Discovery == torrent.PeerSourceIncoming,
+			Choked:    true,
+			Source:    peerSource(pc.Discovery),
+		}
+
+		peer.Address = pc.RemoteAddr.String()
+		if addrPort, err := netip.ParseAddrPort(peer.Address); err == nil {
+			peer.Address = addrPort.Addr().Unmap().String()
+			peer.Port = int(addrPort.Port())
+		}
+
+		if numPieces > 0 {
+			have := min(int(pc.PeerPieces().GetCardinality()), numPieces)
+			peer.Progress = float64(have) / float64(numPieces) * 100
+		}
+
+		var requested int64
+		e.peersMutex.Lock()
+		if stats, ok := e.peers[&pc.Peer]; ok {
+			peer.Choked = stats.choked
+			peer.Interested = stats.interested
+			peer.DownloadSpeed = stats.download.speed
+			requested = stats.requests.speed
+		}
+		e.peersMutex.Unlock()
+
+		peers = append(peers, peer)
+		requests = append(requests, requested)
+		totalRequests += requested
+	}
+
+	// The client doesn't count uploads per peer, only per torrent
+	e.speedsMutex.RLock()
+	var uploadSpeed int64
+	if tracker, ok := e.uploadSpeeds[infoHash]; ok {
+		uploadSpeed = tracker.speed
+	}
+	e.speedsMutex.RUnlock()
+
+	for i := range peers {
+		if totalRequests > 0 {
+			peers[i].UploadSpeed = int64(float64(uploadSpeed) * float64(requests[i]) / float64(totalRequests))
+		}
+		peers[i].DownloadSpeedStr = formatSpeed(peers[i].DownloadSpeed)
+		peers[i].UploadSpeedStr = formatSpeed(peers[i].UploadSpeed)
+	}
+
+	slices.SortFunc(peers, func(a, b PeerInfo) int {
+		if a.DownloadSpeed != b.DownloadSpeed {
+			return int(b.DownloadSpeed - a.DownloadSpeed)
+		}
+		return strings.Compare(a.Address, b.Address)
+	})
+	return peers, nil
+}
+
+// AddPeer connects a torrent to a peer given as ip:port, e.g. another of
+// our machines seeding it without a tracker. The peer is saved with the
+// torrent and given to the client again after a restart or resume.
+func (e *Engine) AddPeer(infoHash, addr string) error {
+	e.torrentsMutex.RLock()
+	t, exists := e.torrents[infoHash]
+	e.torrentsMutex.RUnlock()
+
+	if !exists {
+		return ErrTorrentNotFound
+	}
+
+	addrPort, err := netip.ParseAddrPort(strings.TrimSpace(addr))
+	if err != nil || addrPort.Port() == 0 {
+		return fmt.Errorf("%q is not an ip:port address", addr)
+	}
+	addrPort = netip.AddrPortFrom(addrPort.Addr().Unmap(), addrPort.Port())
+	if e.blocklist.refuse(addrPort.Addr()) {
+		return fmt.Errorf("%s is banned", addrPort.Addr())
+	}
+	addr = addrPort.String()
+
+	e.sessionsMutex.Lock()
+	if session, ok := e.sessions[infoHash]; ok && !slices.Contains(session.peers, addr) {
+		session.peers = append(session.peers, addr)
+	}
+	e.sessionsMutex.Unlock()
+
+	// Paused torrents connect once they are resumed
+	if !isDetached(t) {
+		t.AddPeers([]torrent.PeerInfo{{
+			Addr:    torrent.StringAddr(addr),
+			Source:  torrent.PeerSourceDirect,
+			Trusted: true,
+		}})
+	}
+	e.saveTorrentStates()
+
+	log.Printf("✓ Added peer %s to %s", addr, t.Name())
+	return nil
+}
+
+// KickPeer disconnects a peer from a torrent. The peer is given as
+// ip:port like in GetPeers and may connect again later; ban it to keep
+// it away. A peer added by hand is forgotten.
+func (e *Engine) KickPeer(infoHash, addr string) error {
+	e.torrentsMutex.RLock()
+	t, exists := e.torrents[infoHash]
+	e.torrentsMutex.RUnlock()
+
+	if !exists {
+		return ErrTorrentNotFound
+	}
+
+	addrPort, err := netip.ParseAddrPort(strings.TrimSpace(addr))
+	if err != nil {
+		return fmt.Errorf("%q is not an ip:port address", addr)
+	}
+	addrPort = netip.AddrPortFrom(addrPort.Addr().Unmap(), addrPort.Port())
+
+	e.sessionsMutex.Lock()
+	forgotten := false
+	if session, ok := e.sessions[infoHash]; ok {
+		before := len(session.peers)
+		session.peers = slices.DeleteFunc(session.peers, func(peer string) bool {
+			return peer == addrPort.String()
+		})
+		forgotten = len(session.peers) < before
+	}
+	e.sessionsMutex.Unlock()
+	if forgotten {
+		e.saveTorrentStates()
+	}
+
+	kicked := false
+	for _, pc := range t.PeerConns() {
+		remote, err := netip.ParseAddrPort(pc.RemoteAddr.String())
+		if err == nil && netip.AddrPortFrom(remote.Addr().Unmap(), remote.Port()) == addrPort {
+			pc.Close()
+			kicked = true
+		}
+	}
+	if !kicked && !forgotten {
+		return ErrPeerNotConnected
+	}
+
+	log.Printf("👢 Kicked peer %s from %s", addrPort, t.Name())
+	return nil
+}
+
+// peerSource names where the client found a peer
+func peerSource(source torrent.PeerSource) string {
+	switch source {
+	case torrent.PeerSourceTracker:
+		return PeerSourceTracker
+	case torrent.PeerSourceDhtGetPeers, torrent.PeerSourceDhtAnnouncePeer:
+		return PeerSourceDHT
+	case torrent.PeerSourcePex:
+		return PeerSourcePEX
+	case torrent.PeerSourceIncoming:
+		return PeerSourceIncoming
+	case torrent.PeerSourceDirect:
+		return PeerSourceManual
+	case torrent.PeerSourceUtHolepunch:
+		return PeerSourceHolepunch
+	}
+	return PeerSourceUnknown
+}
+
+// encryptedPeers returns whether the connection to each peer of a torrent
+// is encrypted, by remote address. The library only tells this in the
+// swarm it knows of, where connected peers come last and carry whether
+// their handshake was encrypted.
+func encryptedPeers(t *torrent.Torrent) map[string]bool {
+	encrypted := make(map[string]bool)
+	for _, peer := range t.KnownSwarm() {
+		if peer.Addr != nil {
+			encrypted[peer.Addr.String()] = peer.SupportsEncryption
+		}
+	}
+	return encrypted
+}
+
+// peerClientName prefers the name the peer sent in its extended handshake
+// and falls back to decoding its peer ID
+func peerClientName(pc *torrent.PeerConn) string {
+	if name, ok := pc.PeerClientName.Load().(string); ok && name != "" {
+		return name
+	}
+	return decodePeerID(pc.PeerID)
+}
+
+// azureusClients maps the client codes of Azureus style peer IDs
+var azureusClients = map[string]string{
+	"AZ": "Vuze",
+	"BI": "BiglyBT",
+	"BT": "BitTorrent",
+	"DE": "Deluge",
+	"FD": "Free Download Manager",
+	"FW": "FrostWire",
+	"GT": "anacrolix/torrent",
+	"KT": "KTorrent",
+	"LT": "libTorrent",
+	"lt": "libtorrent",
+	"PI": "PicoTorrent",
+	"qB": "qBittorrent",
+	"SD": "Thunder",
+	"TL": "Tribler",
+	"TR": "Transmission",
+	"TX": "Tixati",
+	"UM": "µTorrent Mac",
+	"UT": "µTorrent",
+	"WD": "WebTorrent Desktop",
+	"WW": "WebTorrent",
+	"XL": "Xunlei",
+}
+
+// shadowClients maps the client letters of Shadow style peer IDs
+var shadowClients = map[byte]string{
+	'A': "ABC",
+	'O': "Osprey Permaseed",
+	'Q': "BTQueue",
+	'R': "Tribler",
+	'S': "Shadow",
+	'T': "BitTornado",
+	'U': "UPnP NAT Bit Torrent",
+}
+
+// decodePeerID names the client behind a peer ID. It understands the
+// Azureus style ("-qB4500-"), the Shadow style ("S58B-----") and the
+// Mainline style ("M7-4-3--"). IDs in none of them, or with bytes that
+// can't be shown, are "Unknown".
+func decodePeerID(id torrent.PeerID) string {
+	switch {
+	case id[0] == '-' && id[7] == '-' && isAlnum(id[1:7]):
+		code := string(id[1:3])
+		name, ok := azureusClients[code]
+		if !ok {
+			name = code
+		}
+		version := strings.TrimRight(string(id[3:7]), "0")
+		if len(version) < 2 {
+			version += strings.Repeat("0", 2-len(version))
+		}
+		return fmt.Sprintf("%s %s", name, strings.Join(strings.Split(version, ""), "."))
+	case id[0] == 'M' && id[2] == '-' && isAlnum(id[1:2]):
+		version, _, _ := strings.Cut(string(id[1:8]), "--")
+		version = strings.TrimRight(version, "-")
+		if !isAlnum([]byte(strings.ReplaceAll(version, "-", ""))) {
+			break
+		}
+		return "BitTorrent " + strings.ReplaceAll(version, "-", ".")
+	case shadowClients[id[0]] != "" && string(id[6:9]) == "---":
+		var version []string
+		for _, c := range id[1:6] {
+			if c == '-' {
+				break
+			}
+			n := strings.IndexByte(shadowDigits, c)
+			if n < 0 {
+				return "Unknown"
+			}
+			version = append(version, strconv.Itoa(n))
+		}
+		if len(version) == 0 {
+			break
+		}
+		return fmt.Sprintf("%s %s", shadowClients[id[0]], strings.Join(version, "."))
+	}
+	return "Unknown"
+}
+
+// shadowDigits are the version digits of Shadow style peer IDs in order
+// of value
+const shadowDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz.-"
+
+// isAlnum reports whether b is made of ASCII letters and digits only
+func isAlnum(b []byte) bool {
+	for _, c := range b {
+		if !('0' <= c && c <= '9' || 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z') {
+			return false
+		}
+	}
+	return true
+}
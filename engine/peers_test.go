@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"torrentflow/config"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestGetPeersEncrypted(t *testing.T) {
+	tests := []struct {
+		encryption string
+		want       bool
+	}{
+		{config.EncryptionRequire, true},
+		{config.EncryptionPrefer, true},
+		{config.EncryptionDisable, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.encryption, func(t *testing.T) {
+			mi, dir := seededTorrent(t)
+
+			seederCfg := torrent.NewDefaultClientConfig()
+			seederCfg.DataDir = dir
+			seederCfg.ListenPort = 0
+			seederCfg.NoDHT = true
+			seederCfg.Seed = true
+			seederCfg.DisableIPv6 = true
+			seederCfg.NoDefaultPortForwarding = true
+			seeder, err := torrent.NewClient(seederCfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer seeder.Close()
+			if _, err := seeder.AddTorrent(mi); err != nil {
+				t.Fatal(err)
+			}
+
+			settings := config.Default()
+			settings.DownloadDir = t.TempDir()
+			settings.StateFile = filepath.Join(t.TempDir(), "torrents.json")
+			settings.ListenPort = 0
+			settings.PortForwarding = false
+			settings.EnableDHT = false
+			settings.Trackers = nil
+			settings.Encryption = tt.encryption
+
+			e := New(settings)
+			// Keep the download from finishing, which would leave two seeds
+			// with no reason to stay connected
+			setRateLimit(e.downloadLimiter, 1)
+			client, _, err := e.newClient(settings, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			tor, err := client.AddTorrent(mi)
+			if err != nil {
+				t.Fatal(err)
+			}
+			hash := tor.InfoHash().HexString()
+			e.torrents[hash] = tor
+			tor.DownloadAll()
+			tor.AddPeers([]torrent.PeerInfo{{
+				Addr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: seeder.LocalPort()},
+			}})
+
+			var peers []PeerInfo
+			waitFor(t, "the seeder to connect", func() bool {
+				peers, err = e.GetPeers(hash)
+				return err == nil && len(peers) > 0
+			})
+			if peers[0].Encrypted != tt.want {
+				t.Errorf("Encrypted = %v, want %v", peers[0].Encrypted, tt.want)
+			}
+			want := 0.0
+			if tt.want {
+				want = 100
+			}
+			if got := e.getTorrentInfo(hash, tor).EncryptedPeers; got != want {
+				t.Errorf("EncryptedPeers = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodePeerID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"-qB4500-abcdefghijkl", "qBittorrent 4.5"},
+		{"-TR2940-abcdefghijkl", "Transmission 2.9.4"},
+		{"-lt0D60-abcdefghijkl", "libtorrent 0.D.6"},
+		{"-XX1000-abcdefghijkl", "XX 1.0"},
+		{"M7-4-3--abcdefghijkl", "BitTorrent 7.4.3"},
+		{"M4-20-8-abcdefghijkl", "BitTorrent 4.20.8"},
+		{"S58B-----abcdefghijk", "Shadow 5.8.11"},
+		{"T03I-----abcdefghijk", "BitTornado 0.3.18"},
+		{"A-------------------", "Unknown"},
+		{"-q\x00\x01500-abcdefghijkl", "Unknown"},
+		{"-qB45\xff0-abcdefghijkl", "Unknown"},
+		{"M\x07-4-3--abcdefghijk", "Unknown"},
+		{"S5\x008-----abcdefghij", "Unknown"},
+		// Short IDs are padded with zero bytes
+		{"-qB", "Unknown"},
+		{"M7-", "Unknown"},
+		{"S58", "Unknown"},
+		{"", "Unknown"},
+		{"\xff\xfe\xfd\xfc\xfb\xfa\xf9\xf8\xf7\xf6\xf5\xf4\xf3\xf2\xf1\xf0\xef\xee\xed\xec", "Unknown"},
+	}
+	for _, tt := range tests {
+		var id torrent.PeerID
+		copy(id[:], tt.id)
+		if got := decodePeerID(id); got != tt.want {
+			t.Errorf("decodePeerID(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+}
+
+// seededTorrent returns a torrent without trackers, larger than a rate
+// limiter's burst, and the directory that holds its data
+func seededTorrent(t *testing.T) (*metainfo.MetaInfo, string) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.bin")
+	if err := os.WriteFile(path, make([]byte, 4*minLimiterBurst), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := metainfo.Info{PieceLength: 16 * 1024}
+	if err := info.BuildFromFilePath(path); err != nil {
+		t.Fatal(err)
+	}
+	return &metainfo.MetaInfo{InfoBytes: bencode.MustMarshal(info)}, dir
+}
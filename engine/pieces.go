@@ -0,0 +1,136 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Piece states in a PieceMap
+const (
+	PieceComplete = "complete"
+	PiecePartial  = "partial" // Some blocks downloaded, not verified yet
+	PiecePending  = "pending"
+)
+
+// PieceRun is a run of consecutive pieces in the same state
+type PieceRun struct {
+	State  string `json:"state"`
+	Length int    `json:"length"`
+}
+
+// AvailabilityRun is a run of consecutive pieces held by the same number
+// of connected peers
+type AvailabilityRun struct {
+	Peers  int `json:"peers"`
+	Length int `json:"length"`
+}
+
+// PieceMap describes the pieces of a torrent for drawing a piece bar. The
+// runs are run-length encoded and each list adds up to NumPieces.
+type PieceMap struct {
+	InfoHash     string            `json:"infoHash"`
+	NumPieces    int               `json:"numPieces"`
+	PieceLength  int64             `json:"pieceLength"`
+	Complete     int               `json:"complete"`
+	Partial      int               `json:"partial"`
+	Pending      int               `json:"pending"`
+	Pieces       []PieceRun        `json:"pieces"`
+	Availability []AvailabilityRun `json:"availability"`
+	// Peers is the number of connected peers availability is counted over
+	Peers int `json:"peers"`
+	// DistributedCopies is how many full copies the connected peers hold
+	// together: the lowest availability plus the share of pieces above it
+	DistributedCopies float64 `json:"distributedCopies"`
+}
+
+// GetPieceMap returns the completion and availability of every piece of a
+// torrent. Paused torrents have no connected peers, so their availability
+// is zero throughout.
+func (e *Engine) GetPieceMap(infoHash string) (PieceMap, error) {
+	e.torrentsMutex.RLock()
+	t, exists := e.torrents[infoHash]
+	e.torrentsMutex.RUnlock()
+
+	if !exists {
+		return PieceMap{}, ErrTorrentNotFound
+	}
+	info := t.Info()
+	if info == nil {
+		return PieceMap{}, fmt.Errorf("torrent metadata not available yet")
+	}
+
+	pm := PieceMap{
+		InfoHash:    infoHash,
+		NumPieces:   t.NumPieces(),
+		PieceLength: info.PieceLength,
+	}
+
+	for _, run := range t.PieceStateRuns() {
+		state := PiecePending
+		switch {
+		case run.Complete:
+			state = PieceComplete
+			pm.Complete += run.Length
+		case run.Partial:
+			state = PiecePartial
+			pm.Partial += run.Length
+		default:
+			pm.Pending += run.Length
+		}
+
+		// Runs differing only in priority or hashing merge into one
+		pm.Pieces = appendPieceRun(pm.Pieces, state, run.Length)
+	}
+
+	availability := make([]int, pm.NumPieces)
+	for _, conn := range t.PeerConns() {
+		pm.Peers++
+		conn.PeerPieces().Iterate(func(piece uint32) bool {
+			if int(piece) < len(availability) {
+				availability[piece]++
+			}
+			return true
+		})
+	}
+
+	pm.Availability = availabilityRuns(availability)
+
+	if pm.NumPieces > 0 {
+		lowest, above := slices.Min(availability), 0
+		for _, peers := range availability {
+			if peers > lowest {
+				above++
+			}
+		}
+		pm.DistributedCopies = float64(lowest) + float64(above)/float64(pm.NumPieces)
+	}
+
+	return pm, nil
+}
+
+// appendPieceRun adds length pieces in state to the end of runs, growing
+// the last run when it is in the same state
+func appendPieceRun(runs []PieceRun, state string, length int) []PieceRun {
+	if length <= 0 {
+		return runs
+	}
+	if n := len(runs); n > 0 && runs[n-1].State == state {
+		runs[n-1].Length += length
+		return runs
+	}
+	return append(runs, PieceRun{State: state, Length: length})
+}
+
+// availabilityRuns run-length encodes the number of peers holding each
+// piece
+func availabilityRuns(availability []int) []AvailabilityRun {
+	var runs []AvailabilityRun
+	for _, peers := range availability {
+		if n := len(runs); n > 0 && runs[n-1].Peers == peers {
+			runs[n-1].Length++
+			continue
+		}
+		runs = append(runs, AvailabilityRun{Peers: peers, Length: 1})
+	}
+	return runs
+}
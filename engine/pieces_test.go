@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestPieceRunsRoundTrip(t *testing.T) {
+	const c, p, x = PieceComplete, PiecePartial, PiecePending
+	tests := []struct {
+		name   string
+		pieces []string
+		want   []PieceRun
+	}{
+		{"empty torrent", nil, nil},
+		{"single piece", []string{c}, []PieceRun{{c, 1}}},
+		{"single run", []string{x, x, x, x}, []PieceRun{{x, 4}}},
+		{"alternating", []string{c, x, c, x, p, x}, []PieceRun{{c, 1}, {x, 1}, {c, 1}, {x, 1}, {p, 1}, {x, 1}}},
+		{"last run ends at the final piece", []string{c, c, p, x, x, x}, []PieceRun{{c, 2}, {p, 1}, {x, 3}}},
+		{"last piece alone", []string{c, c, c, x}, []PieceRun{{c, 3}, {x, 1}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var runs []PieceRun
+			for _, state := range tt.pieces {
+				runs = appendPieceRun(runs, state, 1)
+			}
+			if !slices.Equal(runs, tt.want) {
+				t.Errorf("encoded %v, want %v", runs, tt.want)
+			}
+			if got := decodePieceRuns(runs); !slices.Equal(got, tt.pieces) {
+				t.Errorf("decoded %v, want %v", got, tt.pieces)
+			}
+
+			// The client hands over runs that differ only in what the piece
+			// map leaves out; they come out the same as single pieces
+			var split []PieceRun
+			for _, run := range tt.want {
+				split = appendPieceRun(split, run.State, 0)
+				for _, n := range []int{run.Length / 2, run.Length - run.Length/2} {
+					split = appendPieceRun(split, run.State, n)
+				}
+			}
+			if !slices.Equal(split, tt.want) {
+				t.Errorf("merged split runs into %v, want %v", split, tt.want)
+			}
+		})
+	}
+}
+
+func TestAvailabilityRunsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		availability []int
+		want         []AvailabilityRun
+	}{
+		{"empty torrent", nil, nil},
+		{"single run", []int{2, 2, 2}, []AvailabilityRun{{2, 3}}},
+		{"alternating", []int{0, 1, 0, 1}, []AvailabilityRun{{0, 1}, {1, 1}, {0, 1}, {1, 1}}},
+		{"last run ends at the final piece", []int{3, 1, 1, 0, 0}, []AvailabilityRun{{3, 1}, {1, 2}, {0, 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := availabilityRuns(tt.availability)
+			if !slices.Equal(runs, tt.want) {
+				t.Errorf("encoded %v, want %v", runs, tt.want)
+			}
+			var decoded []int
+			for _, run := range runs {
+				for range run.Length {
+					decoded = append(decoded, run.Peers)
+				}
+			}
+			if !slices.Equal(decoded, tt.availability) {
+				t.Errorf("decoded %v, want %v", decoded, tt.availability)
+			}
+		})
+	}
+}
+
+func TestGetPieceMap(t *testing.T) {
+	const pieceLength = 16 * 1024
+	e := newTestEngine(t)
+
+	// Five pieces, the second and the last two of which are missing on disk
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.bin")
+	data := make([]byte, 5*pieceLength-100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := metainfo.Info{PieceLength: pieceLength}
+	if err := info.BuildFromFilePath(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, piece := range []int{1, 3, 4} {
+		data[piece*pieceLength] ^= 0xff
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := e.AddTorrentMetaInfo(&metainfo.MetaInfo{InfoBytes: bencode.MustMarshal(info)}, AddOptions{SavePath: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{PieceComplete, PiecePending, PieceComplete, PiecePending, PiecePending}
+	var pm PieceMap
+	waitFor(t, "the pieces to be checked", func() bool {
+		pm, err = e.GetPieceMap(hash)
+		return err == nil && slices.Equal(decodePieceRuns(pm.Pieces), want)
+	})
+	if pm.NumPieces != 5 || pm.Complete != 2 || pm.Partial != 0 || pm.Pending != 3 {
+		t.Errorf("counted %d complete, %d partial and %d pending of %d pieces", pm.Complete, pm.Partial, pm.Pending, pm.NumPieces)
+	}
+	if want := []AvailabilityRun{{Peers: 0, Length: 5}}; !slices.Equal(pm.Availability, want) {
+		t.Errorf("availability %v, want %v", pm.Availability, want)
+	}
+	if pm.DistributedCopies != 0 {
+		t.Errorf("distributed copies %v without peers", pm.DistributedCopies)
+	}
+
+	if _, err := e.GetPieceMap("unknown"); err != ErrTorrentNotFound {
+		t.Errorf("GetPieceMap of an unknown torrent: %v", err)
+	}
+}
+
+// decodePieceRuns expands runs into the state of each piece
+func decodePieceRuns(runs []PieceRun) []string {
+	var pieces []string
+	for _, run := range runs {
+		for range run.Length {
+			pieces = append(pieces, run.State)
+		}
+	}
+	return pieces
+}
@@ -0,0 +1,265 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// portCheckInterval is how often the port loop checks whether mappings
+// are due for renewal
+const portCheckInterval = time.Minute
+
+// portRetryDelay is how long to wait before trying a port change again,
+// such as when a torrent was busy
+const portRetryDelay = 5 * time.Second
+
+// portUnmapTimeout bounds how long Close waits for the router's
+// mappings to be removed
+const portUnmapTimeout = 3 * time.Second
+
+// portTestTimeout bounds a port test
+const portTestTimeout = 20 * time.Second
+
+// portCheckURL is asked whether a port can be reached from the internet.
+// It answers "1" when it could connect to the port and "0" otherwise.
+var portCheckURL = "https://portcheck.transmissionbt.com/%d"
+
+// PortStatus describes the port peers connect to and how it is forwarded
+type PortStatus struct {
+	// Port is the port the client listens on, 0 when it accepts no
+	// connections, such as when peers are reached through a proxy
+	Port int `json:"port"`
+	// Configured is the listen port setting the client was built with, 0
+	// for a random port
+	Configured int `json:"configured"`
+	// Fallback is set when the configured port was taken and a random
+	// one is used until the next start
+	Fallback bool `json:"fallback"`
+	// Forwarding is set while the port is forwarded on the router
+	Forwarding bool          `json:"forwarding"`
+	Mappings   []PortMapping `json:"mappings"`
+	// ExternalAddress is where peers on the internet reach the client, as
+	// reported by the first router that forwarded the port
+	ExternalAddress string `json:"externalAddress"`
+	// Test is the result of the last port test on this port
+	Test *PortTest `json:"test,omitempty"`
+}
+
+// PortTest is the result of checking whether the port can be reached from
+// the internet
+type PortTest struct {
+	Port     int       `json:"port"`
+	Open     bool      `json:"open"`
+	Error    string    `json:"error,omitempty"`
+	TestedAt time.Time `json:"testedAt"`
+}
+
+// GetPortStatus returns the listen port and the state of its mappings
+func (e *Engine) GetPortStatus() PortStatus {
+	e.portMutex.Lock()
+	defer e.portMutex.Unlock()
+
+	status := e.port
+	status.Mappings = slices.Clone(e.port.Mappings)
+	if status.Mappings == nil {
+		status.Mappings = []PortMapping{}
+	}
+	return status
+}
+
+// setListenPort records the port of a new client and asks the port loop
+// to forward it
+func (e *Engine) setListenPort(configured, port int) {
+	e.portMutex.Lock()
+	if port != e.port.Port {
+		e.port.Test = nil
+	}
+	e.port.Configured = configured
+	e.port.Port = port
+	e.port.Fallback = configured != 0 && port != 0 && port != configured
+	e.portMutex.Unlock()
+
+	e.signalPortChange()
+}
+
+// signalPortChange asks the port loop to check the port at once
+func (e *Engine) signalPortChange() {
+	select {
+	case e.portChanged <- struct{}{}:
+	default:
+	}
+}
+
+// portLoop moves the client to a new port when the setting changes and
+// keeps the port forwarded on the router. The mappings are removed when
+// the engine closes.
+func (e *Engine) portLoop() {
+	defer close(e.portLoopDone)
+
+	ticker := time.NewTicker(portCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			e.portMapper.unmap()
+			return
+		case <-ticker.C:
+			if e.checkPort() {
+				e.emit("port-status", e.GetPortStatus())
+			}
+		case <-e.portChanged:
+			e.checkPort()
+			e.emit("port-status", e.GetPortStatus())
+		}
+	}
+}
+
+// checkPort rebuilds the client when the listen port setting changed and
+// brings the router's mappings in line with the port in use. It reports
+// whether anything changed.
+func (e *Engine) checkPort() bool {
+	settings := e.Settings()
+	want := settings.ListenPort
+
+	e.portMutex.Lock()
+	status := e.port
+	if want != 0 && want == status.Port {
+		// The random port the client picked was saved as the setting
+		e.port.Configured = want
+		e.port.Fallback = false
+	}
+	e.portMutex.Unlock()
+
+	if want != status.Configured && (want == 0 || want != status.Port) {
+		if err := e.rebuildClient(); err != nil {
+			log.Printf("⚠ Failed to move to port %d: %v", want, err)
+			time.AfterFunc(portRetryDelay, e.signalPortChange)
+		}
+	}
+	return e.updatePortMappings()
+}
+
+// updatePortMappings forwards the port in use on the router, removing
+// the mappings of a port no longer used and renewing those that are due.
+// It reports whether the mappings changed.
+func (e *Engine) updatePortMappings() bool {
+	settings := e.Settings()
+	port := e.GetPortStatus().Port
+
+	e.networkMutex.Lock()
+	b, up := e.binding, e.network.Up
+	e.networkMutex.Unlock()
+
+	if !settings.PortForwarding || !up {
+		port = 0
+	}
+
+	m := &e.portMapper
+	if port == m.port && b.equal(m.binding) && (port == 0 || time.Now().Before(m.renewAt)) {
+		return false
+	}
+	if m.port != 0 && (port != m.port || !b.equal(m.binding)) {
+		m.unmap()
+	}
+
+	var mappings []PortMapping
+	if port != 0 {
+		mappings = m.mapPort(port, b)
+	}
+
+	external := ""
+	for _, pm := range mappings {
+		if pm.Status == PortMapMapped && pm.ExternalIP != "" {
+			external = net.JoinHostPort(pm.ExternalIP, strconv.Itoa(pm.ExternalPort))
+			break
+		}
+	}
+
+	e.portMutex.Lock()
+	e.port.Forwarding = port != 0
+	e.port.Mappings = mappings
+	e.port.ExternalAddress = external
+	e.portMutex.Unlock()
+
+	for _, pm := range mappings {
+		if pm.Status == PortMapMapped {
+			log.Printf("✓ Port %d forwarded with %s by %s as %s:%d", port, pm.Protocol, pm.Gateway, pm.ExternalIP, pm.ExternalPort)
+		} else {
+			log.Printf("⚠ Port %d not forwarded with %s: %s", port, pm.Protocol, pm.Error)
+		}
+	}
+	return true
+}
+
+// TestPort asks a service on the internet whether it can connect to the
+// listen port
+func (e *Engine) TestPort() (PortTest, error) {
+	port := e.GetPortStatus().Port
+	if port == 0 {
+		return PortTest{}, fmt.Errorf("the client does not accept incoming connections")
+	}
+
+	test := PortTest{Port: port, TestedAt: time.Now()}
+	open, err := e.checkPortOpen(port)
+	if err != nil {
+		test.Error = err.Error()
+	}
+	test.Open = open
+
+	e.portMutex.Lock()
+	if e.port.Port == port {
+		e.port.Test = &test
+	}
+	e.portMutex.Unlock()
+	e.emit("port-status", e.GetPortStatus())
+
+	if err != nil {
+		return test, fmt.Errorf("port test failed: %w", err)
+	}
+	return test, nil
+}
+
+// checkPortOpen asks the port check service about port, from the bound
+// address if traffic is bound
+func (e *Engine) checkPortOpen(port int) (bool, error) {
+	transport := &http.Transport{}
+	e.networkMutex.Lock()
+	if e.binding != nil {
+		transport.DialContext = e.binding.DialContext
+	}
+	e.networkMutex.Unlock()
+
+	client := &http.Client{Transport: transport, Timeout: portTestTimeout}
+	defer transport.CloseIdleConnections()
+
+	resp, err := client.Get(fmt.Sprintf(portCheckURL, port))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("port check service answered %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return false, err
+	}
+	switch strings.TrimSpace(string(body)) {
+	case "1":
+		return true, nil
+	case "0":
+		return false, nil
+	default:
+		return false, errors.New("unexpected answer from the port check service")
+	}
+}
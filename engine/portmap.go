@@ -0,0 +1,451 @@
+package engine
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	alog "github.com/anacrolix/log"
+	"github.com/anacrolix/upnp"
+)
+
+// Router protocols the listen port is forwarded with
+const (
+	PortMapUPnP   = "upnp"
+	PortMapNATPMP = "natpmp"
+)
+
+// States of a port mapping
+const (
+	// PortMapMapped means the router forwards the port
+	PortMapMapped = "mapped"
+	// PortMapFailed means the router was found but refused the mapping
+	PortMapFailed = "failed"
+	// PortMapUnavailable means no router speaking the protocol was found
+	PortMapUnavailable = "unavailable"
+)
+
+// portMapLifetime is how long routers are asked to keep a mapping. They
+// are renewed halfway through.
+const portMapLifetime = 2 * time.Hour
+
+// portMapRetryInterval is how long to wait before asking again after no
+// router forwarded the port
+const portMapRetryInterval = 5 * time.Minute
+
+// upnpDiscoverTimeout is how long to wait for UPnP routers to answer
+const upnpDiscoverTimeout = 2 * time.Second
+
+// portMapDescription names the mappings in the router's UPnP table
+const portMapDescription = "SeedRush"
+
+// NAT-PMP (RFC 6886) constants
+const (
+	natpmpPort = 5351
+	// The first request waits this long for an answer, doubling with
+	// every attempt
+	natpmpFirstTimeout = 250 * time.Millisecond
+	natpmpAttempts     = 4
+	natpmpOpExternal   = 0
+	natpmpOpMapUDP     = 1
+	natpmpOpMapTCP     = 2
+)
+
+// PortMapping is the state of the listen port on a router protocol
+type PortMapping struct {
+	// Protocol is one of the PortMap* protocols
+	Protocol string `json:"protocol"`
+	// Status is one of the PortMap* states
+	Status string `json:"status"`
+	// Gateway is the router that was asked
+	Gateway      string    `json:"gateway,omitempty"`
+	ExternalIP   string    `json:"externalIp,omitempty"`
+	ExternalPort int       `json:"externalPort,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+// upnpMapping is a port forwarded by a UPnP router
+type upnpMapping struct {
+	device       upnp.Device
+	proto        upnp.Protocol
+	externalPort int
+}
+
+// portMapper forwards the listen port on the router with UPnP and
+// NAT-PMP. Only the port loop uses it.
+type portMapper struct {
+	port    int      // Forwarded internal port, 0 when nothing is
+	binding *binding // Binding the port was forwarded for
+	renewAt time.Time
+	upnp    []upnpMapping
+	// natpmp is the gateway holding NAT-PMP mappings, asked from natpmpFrom
+	natpmp     netip.AddrPort
+	natpmpFrom netip.Addr
+}
+
+// mapPort forwards port with both protocols at once and returns how each
+// of them went. Traffic bound to an interface is only forwarded by
+// routers reached through it.
+func (m *portMapper) mapPort(port int, b *binding) []PortMapping {
+	var upnpResult, natpmpResult PortMapping
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		upnpResult = m.mapUPnP(port, b)
+	}()
+	go func() {
+		defer wg.Done()
+		natpmpResult = m.mapNATPMP(port, b)
+	}()
+	wg.Wait()
+
+	m.port = port
+	m.binding = b
+	if upnpResult.Status == PortMapMapped || natpmpResult.Status == PortMapMapped {
+		m.renewAt = time.Now().Add(portMapLifetime / 2)
+	} else {
+		m.renewAt = time.Now().Add(portMapRetryInterval)
+	}
+	return []PortMapping{upnpResult, natpmpResult}
+}
+
+// unmap removes every mapping made
+func (m *portMapper) unmap() {
+	var wg sync.WaitGroup
+	for _, um := range m.upnp {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			um.device.DeletePortMapping(um.proto, um.externalPort)
+		}()
+	}
+	if m.natpmp.IsValid() {
+		for _, op := range []byte{natpmpOpMapUDP, natpmpOpMapTCP} {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// A lifetime of 0 deletes the mapping
+				natpmpCall(m.natpmp, m.natpmpFrom, natpmpMapRequest(op, m.port, 0, 0), 16)
+			}()
+		}
+	}
+	wg.Wait()
+
+	*m = portMapper{}
+}
+
+// mapUPnP forwards port on every UPnP router found
+func (m *portMapper) mapUPnP(port int, b *binding) PortMapping {
+	pm := PortMapping{Protocol: PortMapUPnP, Status: PortMapUnavailable, UpdatedAt: time.Now()}
+
+	var devices []upnp.Device
+	for _, d := range upnp.Discover(0, upnpDiscoverTimeout, alog.Default) {
+		// Routers forward to the address the client reached them from
+		local, ok := netip.AddrFromSlice(d.GetLocalIPAddress())
+		if b == nil || (ok && slices.Contains(b.addrs(), local.Unmap())) {
+			devices = append(devices, d)
+		}
+	}
+	if len(devices) == 0 {
+		pm.Error = "no UPnP router found"
+		return pm
+	}
+
+	m.upnp = nil
+	var errs []error
+	for _, d := range devices {
+		mapped := 0
+		for _, proto := range []upnp.Protocol{upnp.TCP, upnp.UDP} {
+			externalPort, err := d.AddPortMapping(proto, port, port, portMapDescription, portMapLifetime)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", proto, err))
+				continue
+			}
+			m.upnp = append(m.upnp, upnpMapping{device: d, proto: proto, externalPort: externalPort})
+			pm.ExternalPort = externalPort
+			mapped++
+		}
+		if mapped < 2 || pm.Status == PortMapMapped {
+			continue
+		}
+
+		pm.Status = PortMapMapped
+		pm.Gateway = upnpGateway(d)
+		if ip, err := d.GetExternalIPAddress(); err == nil && ip != nil {
+			pm.ExternalIP = ip.String()
+		}
+	}
+
+	if pm.Status != PortMapMapped {
+		pm.Status = PortMapFailed
+		pm.Gateway = upnpGateway(devices[0])
+		pm.ExternalPort = 0
+		pm.Error = errors.Join(errs...).Error()
+	}
+	return pm
+}
+
+// upnpGateway returns the host of a UPnP router
+func upnpGateway(d upnp.Device) string {
+	if igd, ok := d.(*upnp.IGDService); ok {
+		if u, err := url.Parse(igd.URL); err == nil {
+			return u.Hostname()
+		}
+	}
+	return ""
+}
+
+// mapNATPMP forwards port on the default gateway with NAT-PMP
+func (m *portMapper) mapNATPMP(port int, b *binding) PortMapping {
+	pm := PortMapping{Protocol: PortMapNATPMP, Status: PortMapUnavailable, UpdatedAt: time.Now()}
+
+	var from netip.Addr
+	iface := ""
+	if b != nil {
+		if !b.v4.IsValid() {
+			pm.Error = "NAT-PMP needs an IPv4 address"
+			return pm
+		}
+		from = b.v4
+		iface = interfaceWith(b.v4)
+	}
+	gateway, err := defaultGateway(iface)
+	if err != nil {
+		pm.Error = err.Error()
+		return pm
+	}
+	pm.Gateway = gateway.String()
+	addr := netip.AddrPortFrom(gateway, natpmpPort)
+
+	resp, err := natpmpCall(addr, from, []byte{0, natpmpOpExternal}, 12)
+	if err != nil {
+		pm.Status, pm.Error = natpmpStatus(err), err.Error()
+		return pm
+	}
+	pm.ExternalIP = natpmpExternalIP(resp).String()
+
+	lifetime := uint32(portMapLifetime / time.Second)
+	for _, op := range []byte{natpmpOpMapTCP, natpmpOpMapUDP} {
+		resp, err := natpmpCall(addr, from, natpmpMapRequest(op, port, port, lifetime), 16)
+		if err != nil {
+			pm.Status, pm.Error = natpmpStatus(err), err.Error()
+			pm.ExternalPort = 0
+			return pm
+		}
+		m.natpmp, m.natpmpFrom = addr, from
+		pm.ExternalPort = natpmpMappedPort(resp)
+	}
+	pm.Status = PortMapMapped
+	return pm
+}
+
+// natpmpResultError is a result code a NAT-PMP gateway answered with
+type natpmpResultError uint16
+
+func (e natpmpResultError) Error() string {
+	switch e {
+	case 1:
+		return "NAT-PMP version not supported by the gateway"
+	case 2:
+		return "the gateway refused the mapping"
+	case 3:
+		return "the gateway has no external address"
+	case 4:
+		return "the gateway is out of mappings"
+	case 5:
+		return "the gateway does not support the request"
+	default:
+		return fmt.Sprintf("NAT-PMP result code %d", uint16(e))
+	}
+}
+
+// natpmpStatus tells a gateway that refused a request apart from one that
+// doesn't speak NAT-PMP
+func natpmpStatus(err error) string {
+	var resultErr natpmpResultError
+	if errors.As(err, &resultErr) {
+		return PortMapFailed
+	}
+	return PortMapUnavailable
+}
+
+// natpmpMapRequest builds a request to map or, with a lifetime of 0,
+// unmap a port
+func natpmpMapRequest(op byte, internalPort, externalPort int, lifetime uint32) []byte {
+	req := make([]byte, 12)
+	req[1] = op
+	binary.BigEndian.PutUint16(req[4:6], uint16(internalPort))
+	binary.BigEndian.PutUint16(req[6:8], uint16(externalPort))
+	binary.BigEndian.PutUint32(req[8:12], lifetime)
+	return req
+}
+
+// natpmpExternalIP reads the address from an answer to an external
+// address request
+func natpmpExternalIP(resp []byte) netip.Addr {
+	return netip.AddrFrom4([4]byte(resp[8:12]))
+}
+
+// natpmpMappedPort reads the external port from an answer to a mapping
+// request
+func natpmpMappedPort(resp []byte) int {
+	return int(binary.BigEndian.Uint16(resp[10:12]))
+}
+
+// natpmpAnswer checks that resp answers req with at least size bytes. It
+// reports false for packets that answer something else, and the result
+// code as an error when the gateway refused the request.
+func natpmpAnswer(req, resp []byte, size int) (bool, error) {
+	if len(resp) < size || resp[0] != 0 || resp[1] != 0x80|req[1] {
+		return false, nil
+	}
+	if code := binary.BigEndian.Uint16(resp[2:4]); code != 0 {
+		return true, natpmpResultError(code)
+	}
+	return true, nil
+}
+
+// natpmpCall sends a request to a NAT-PMP gateway from the address from,
+// or any address when it is invalid, and returns the answer of at least
+// size bytes. Requests are repeated with a growing timeout as RFC 6886
+// asks, though fewer times.
+func natpmpCall(gateway netip.AddrPort, from netip.Addr, req []byte, size int) ([]byte, error) {
+	var laddr *net.UDPAddr
+	if from.IsValid() {
+		laddr = net.UDPAddrFromAddrPort(netip.AddrPortFrom(from, 0))
+	}
+	conn, err := net.DialUDP("udp4", laddr, net.UDPAddrFromAddrPort(gateway))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	resp := make([]byte, 16)
+	timeout := natpmpFirstTimeout
+	for range natpmpAttempts {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		for {
+			n, err := conn.Read(resp)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				break
+			}
+			if err != nil {
+				return nil, fmt.Errorf("no NAT-PMP gateway at %s: %w", gateway.Addr(), err)
+			}
+			// Skip answers to earlier requests of another kind
+			ok, err := natpmpAnswer(req, resp[:n], size)
+			if !ok {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			return resp[:n], nil
+		}
+		timeout *= 2
+	}
+	return nil, fmt.Errorf("no NAT-PMP answer from %s", gateway.Addr())
+}
+
+// defaultGateway returns the IPv4 gateway of the default route, through
+// iface when it is set. The Linux routing table is read where there is
+// one; elsewhere the first address of the interface's network is assumed,
+// which is where most home routers sit.
+func defaultGateway(iface string) (netip.Addr, error) {
+	if gateway, err := routeGateway(iface); err == nil {
+		return gateway, nil
+	}
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	for _, ifc := range ifaces {
+		if ifc.Flags&net.FlagUp == 0 || ifc.Flags&net.FlagLoopback != 0 || iface != "" && ifc.Name != iface {
+			continue
+		}
+		addrs, _ := ifc.Addrs()
+		for _, a := range addrs {
+			prefix, err := netip.ParsePrefix(a.String())
+			if err != nil || !prefix.Addr().Is4() || !prefix.Addr().IsPrivate() || prefix.Bits() >= 31 {
+				continue
+			}
+			return prefix.Masked().Addr().Next(), nil
+		}
+	}
+	return netip.Addr{}, fmt.Errorf("no gateway found")
+}
+
+// routeGateway reads the default route's gateway from /proc/net/route
+func routeGateway(iface string) (netip.Addr, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer f.Close()
+	return parseRouteTable(f, iface)
+}
+
+// parseRouteTable finds the default route's gateway in a routing table in
+// the format of /proc/net/route
+func parseRouteTable(r io.Reader, iface string) (netip.Addr, error) {
+	const rtfGateway = 0x2
+
+	scanner := bufio.NewScanner(r)
+	scanner.Scan() // Header
+	for scanner.Scan() {
+		// Iface Destination Gateway Flags ..., addresses in host byte order
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || fields[1] != "00000000" || iface != "" && fields[0] != iface {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 16)
+		if err != nil || flags&rtfGateway == 0 {
+			continue
+		}
+		gw, err := strconv.ParseUint(fields[2], 16, 32)
+		if err != nil {
+			continue
+		}
+		var ip [4]byte
+		binary.NativeEndian.PutUint32(ip[:], uint32(gw))
+		return netip.AddrFrom4(ip), nil
+	}
+	if err := scanner.Err(); err != nil {
+		return netip.Addr{}, err
+	}
+	return netip.Addr{}, fmt.Errorf("no default route")
+}
+
+// interfaceWith returns the name of the interface holding addr
+func interfaceWith(addr netip.Addr) string {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+	for _, ifc := range ifaces {
+		addrs, _ := ifc.Addrs()
+		for _, a := range addrs {
+			if prefix, err := netip.ParsePrefix(a.String()); err == nil && prefix.Addr().Unmap() == addr {
+				return ifc.Name
+			}
+		}
+	}
+	return ""
+}
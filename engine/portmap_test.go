@@ -0,0 +1,183 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestNATPMPMapRequest(t *testing.T) {
+	got := natpmpMapRequest(natpmpOpMapTCP, 42069, 42070, 7200)
+	want := []byte{
+		0, natpmpOpMapTCP, 0, 0,
+		0xa4, 0x55, // internal port 42069
+		0xa4, 0x56, // external port 42070
+		0, 0, 0x1c, 0x20, // lifetime 7200
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("natpmpMapRequest = % x, want % x", got, want)
+	}
+}
+
+func TestNATPMPAnswer(t *testing.T) {
+	mapTCP := natpmpMapRequest(natpmpOpMapTCP, 42069, 42069, 7200)
+	external := []byte{0, natpmpOpExternal}
+
+	tests := []struct {
+		name   string
+		req    []byte
+		resp   []byte
+		size   int
+		ok     bool
+		result natpmpResultError
+	}{
+		{"mapping", mapTCP, natpmpResponse(natpmpOpMapTCP, 0, 16), 16, true, 0},
+		{"external address", external, natpmpResponse(natpmpOpExternal, 0, 12), 12, true, 0},
+		{"refused", mapTCP, natpmpResponse(natpmpOpMapTCP, 2, 16), 16, true, 2},
+		{"answer to another request", mapTCP, natpmpResponse(natpmpOpMapUDP, 0, 16), 16, false, 0},
+		{"request echoed", mapTCP, mapTCP, 12, false, 0},
+		{"short", mapTCP, natpmpResponse(natpmpOpMapTCP, 0, 16)[:12], 16, false, 0},
+		{"other version", mapTCP, append([]byte{1}, natpmpResponse(natpmpOpMapTCP, 0, 16)[1:]...), 16, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := natpmpAnswer(tt.req, tt.resp, tt.size)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			var result natpmpResultError
+			if errors.As(err, &result) != (tt.result != 0) || result != tt.result {
+				t.Fatalf("err = %v, want result code %d", err, tt.result)
+			}
+		})
+	}
+}
+
+func TestNATPMPDecode(t *testing.T) {
+	resp := natpmpResponse(natpmpOpExternal, 0, 12)
+	copy(resp[8:12], []byte{203, 0, 113, 7})
+	if got := natpmpExternalIP(resp); got != netip.MustParseAddr("203.0.113.7") {
+		t.Errorf("natpmpExternalIP = %s, want 203.0.113.7", got)
+	}
+
+	resp = natpmpResponse(natpmpOpMapUDP, 0, 16)
+	binary.BigEndian.PutUint16(resp[8:10], 42069)
+	binary.BigEndian.PutUint16(resp[10:12], 50000)
+	if got := natpmpMappedPort(resp); got != 50000 {
+		t.Errorf("natpmpMappedPort = %d, want 50000", got)
+	}
+}
+
+func TestNATPMPCall(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	gateway := conn.LocalAddr().(*net.UDPAddr).AddrPort()
+
+	// A stand-in gateway that first answers a request it wasn't asked,
+	// then refuses UDP mappings and grants the rest
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			op := buf[1]
+			conn.WriteToUDP(natpmpResponse(op^1, 0, 16), from)
+
+			resp := natpmpResponse(op, 0, 16)
+			switch op {
+			case natpmpOpExternal:
+				resp = resp[:12]
+				copy(resp[8:12], []byte{203, 0, 113, 7})
+			case natpmpOpMapUDP:
+				binary.BigEndian.PutUint16(resp[2:4], 2)
+			default:
+				copy(resp[8:12], buf[4:n])
+			}
+			conn.WriteToUDP(resp, from)
+		}
+	}()
+
+	resp, err := natpmpCall(gateway, netip.Addr{}, []byte{0, natpmpOpExternal}, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := natpmpExternalIP(resp); got != netip.MustParseAddr("203.0.113.7") {
+		t.Errorf("external address = %s, want 203.0.113.7", got)
+	}
+
+	resp, err = natpmpCall(gateway, netip.MustParseAddr("127.0.0.1"), natpmpMapRequest(natpmpOpMapTCP, 42069, 42069, 7200), 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := natpmpMappedPort(resp); got != 42069 {
+		t.Errorf("mapped port = %d, want 42069", got)
+	}
+
+	_, err = natpmpCall(gateway, netip.Addr{}, natpmpMapRequest(natpmpOpMapUDP, 42069, 42069, 7200), 16)
+	if status := natpmpStatus(err); status != PortMapFailed {
+		t.Errorf("refused mapping: err = %v, status %q, want %q", err, status, PortMapFailed)
+	}
+}
+
+func TestParseRouteTable(t *testing.T) {
+	table := strings.Join([]string{
+		"Iface\tDestination\tGateway \tFlags\tRefCnt\tUse\tMetric\tMask\t\tMTU\tWindow\tIRTT",
+		routeLine("eth0", "192.0.2.0", "0.0.0.0", 0x1, 0),
+		routeLine("wg0", "0.0.0.0", "0.0.0.0", 0x1, 50),
+		routeLine("eth0", "0.0.0.0", "192.0.2.1", 0x3, 100),
+		routeLine("wlan0", "0.0.0.0", "198.51.100.1", 0x3, 600),
+	}, "\n") + "\n"
+
+	tests := []struct {
+		iface string
+		want  string
+		err   bool
+	}{
+		{"", "192.0.2.1", false},
+		{"eth0", "192.0.2.1", false},
+		{"wlan0", "198.51.100.1", false},
+		// A default route without a gateway, such as a tunnel's
+		{"wg0", "", true},
+		{"eth1", "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseRouteTable(strings.NewReader(table), tt.iface)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseRouteTable(%q) = %s, want an error", tt.iface, got)
+			}
+			continue
+		}
+		if err != nil || got != netip.MustParseAddr(tt.want) {
+			t.Errorf("parseRouteTable(%q) = %s, %v, want %s", tt.iface, got, err, tt.want)
+		}
+	}
+}
+
+// natpmpResponse builds a gateway's answer to op with a result code
+func natpmpResponse(op byte, code uint16, size int) []byte {
+	resp := make([]byte, size)
+	resp[1] = 0x80 | op
+	binary.BigEndian.PutUint16(resp[2:4], code)
+	return resp
+}
+
+// routeLine formats a route as /proc/net/route does, with addresses in
+// host byte order
+func routeLine(iface, dst, gateway string, flags, metric int) string {
+	hex := func(addr string) string {
+		ip := netip.MustParseAddr(addr).As4()
+		return fmt.Sprintf("%08X", binary.NativeEndian.Uint32(ip[:]))
+	}
+	return fmt.Sprintf("%s\t%s\t%s\t%04X\t0\t0\t%d\t00000000\t0\t0\t0", iface, hex(dst), hex(gateway), flags, metric)
+}
@@ -0,0 +1,419 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+// previewTTL is how long a preview waits to be committed before it is
+// dropped
+const previewTTL = 10 * time.Minute
+
+// previewCleanupInterval is how often expired previews are dropped
+const previewCleanupInterval = 30 * time.Second
+
+// ErrPreviewNotFound is returned when no pending preview has the given id
+var ErrPreviewNotFound = errors.New("preview not found or expired")
+
+// TorrentPreview describes a torrent before it is added. Magnet previews
+// are not Ready until their metadata has been fetched from peers; the
+// "preview-ready" event is sent with the preview when that happens.
+type TorrentPreview struct {
+	// ID identifies the preview for CommitPreview and is the info hash
+	ID          string     `json:"id"`
+	Name        string     `json:"name"`
+	Ready       bool       `json:"ready"`
+	Size        int64      `json:"size"`
+	SizeStr     string     `json:"sizeStr"`
+	Files       []FileInfo `json:"files"`
+	Trackers    []string   `json:"trackers"`
+	PieceLength int64      `json:"pieceLength"`
+	NumPieces   int        `json:"numPieces"`
+	ExpiresAt   time.Time  `json:"expiresAt"`
+}
+
+// AddOptions are chosen when a previewed torrent is committed
+type AddOptions struct {
+	// Files are the indices of the files to download, nil for all
+	Files []int `json:"files"`
+	// SavePath is where the torrent stores its data, empty for the
+	// download directory
+	SavePath string   `json:"savePath"`
+	Paused   bool     `json:"paused"`
+	Category string   `json:"category"`
+	Tags     []string `json:"tags"`
+}
+
+// pendingPreview is a preview waiting to be committed
+type pendingPreview struct {
+	preview   TorrentPreview
+	infoBytes []byte
+	trackers  [][]string
+	magnet    *torrent.Torrent // Fetches metadata; nil once it has arrived
+	cancel    chan struct{}
+}
+
+// PreviewMagnet starts fetching the metadata of a magnet link without
+// downloading anything. The returned preview becomes Ready once the
+// metadata has arrived.
+func (e *Engine) PreviewMagnet(magnetURI string) (TorrentPreview, error) {
+	client := e.torrentClient()
+	if client == nil {
+		return TorrentPreview{}, fmt.Errorf("torrent client not initialized")
+	}
+
+	spec, err := torrent.TorrentSpecFromMagnetUri(magnetURI)
+	if err != nil {
+		return TorrentPreview{}, fmt.Errorf("invalid magnet link: %w", err)
+	}
+	hash := spec.InfoHash.String()
+	if preview, ok := e.existingPreview(hash); ok {
+		return preview, nil
+	}
+	if err := e.checkNotAdded(hash); err != nil {
+		return TorrentPreview{}, err
+	}
+
+	t, _, err := client.AddTorrentSpec(spec)
+	if err != nil {
+		return TorrentPreview{}, fmt.Errorf("failed to add magnet: %w", err)
+	}
+
+	p := &pendingPreview{
+		preview: TorrentPreview{
+			ID:        hash,
+			Name:      spec.DisplayName,
+			ExpiresAt: time.Now().Add(previewTTL),
+		},
+		trackers: spec.Trackers,
+		magnet:   t,
+		cancel:   make(chan struct{}),
+	}
+	for _, tier := range spec.Trackers {
+		p.preview.Trackers = append(p.preview.Trackers, tier...)
+	}
+	preview := e.storePreview(p)
+
+	go e.awaitPreviewInfo(p)
+
+	log.Printf("🔍 Previewing magnet %s, waiting for metadata...", hash)
+	return preview, nil
+}
+
+// PreviewTorrentFile reads a .torrent file without adding it
+func (e *Engine) PreviewTorrentFile(filePath string) (TorrentPreview, error) {
+	mi, err := metainfo.LoadFromFile(filePath)
+	if err != nil {
+		return TorrentPreview{}, fmt.Errorf("failed to load torrent file: %w", err)
+	}
+	return e.PreviewMetaInfo(mi)
+}
+
+// PreviewMetaInfo describes parsed metainfo without adding it
+func (e *Engine) PreviewMetaInfo(mi *metainfo.MetaInfo) (TorrentPreview, error) {
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		return TorrentPreview{}, fmt.Errorf("invalid torrent info: %w", err)
+	}
+
+	hash := mi.HashInfoBytes().String()
+	if preview, ok := e.existingPreview(hash); ok {
+		return preview, nil
+	}
+	if err := e.checkNotAdded(hash); err != nil {
+		return TorrentPreview{}, err
+	}
+
+	announceList := mi.UpvertedAnnounceList()
+	p := &pendingPreview{
+		preview: TorrentPreview{
+			ID:        hash,
+			ExpiresAt: time.Now().Add(previewTTL),
+		},
+		trackers: announceList,
+		cancel:   make(chan struct{}),
+	}
+	for _, tier := range announceList {
+		p.preview.Trackers = append(p.preview.Trackers, tier...)
+	}
+	p.setInfo(mi.InfoBytes, &info)
+
+	return e.storePreview(p), nil
+}
+
+// GetPreview returns a pending preview
+func (e *Engine) GetPreview(id string) (TorrentPreview, error) {
+	e.previewsMutex.Lock()
+	defer e.previewsMutex.Unlock()
+
+	p, ok := e.previews[id]
+	if !ok {
+		return TorrentPreview{}, ErrPreviewNotFound
+	}
+	return p.preview, nil
+}
+
+// CancelPreview drops a pending preview without adding the torrent
+func (e *Engine) CancelPreview(id string) error {
+	if e.takePreview(id) == nil {
+		return ErrPreviewNotFound
+	}
+	log.Printf("✗ Cancelled preview: %s", id)
+	return nil
+}
+
+// CommitPreview adds a previewed torrent with the chosen options and
+// returns its info hash. Magnet previews must be Ready.
+func (e *Engine) CommitPreview(id string, opts AddOptions) (string, error) {
+	e.previewsMutex.Lock()
+	p, ok := e.previews[id]
+	if ok && !p.preview.Ready {
+		e.previewsMutex.Unlock()
+		return "", fmt.Errorf("torrent metadata not available yet")
+	}
+	e.previewsMutex.Unlock()
+	if !ok {
+		return "", ErrPreviewNotFound
+	}
+
+	if err := opts.validate(len(p.preview.Files)); err != nil {
+		return "", err
+	}
+	if e.takePreview(id) == nil {
+		return "", ErrPreviewNotFound
+	}
+
+	mi := &metainfo.MetaInfo{
+		InfoBytes:    p.infoBytes,
+		AnnounceList: p.trackers,
+	}
+	return e.AddTorrentMetaInfo(mi, opts)
+}
+
+// validate checks add options against a torrent with numFiles files
+func (o *AddOptions) validate(numFiles int) error {
+	if o.SavePath != "" && !filepath.IsAbs(o.SavePath) {
+		return fmt.Errorf("save path must be an absolute path")
+	}
+	if o.Files != nil && len(o.Files) == 0 {
+		return fmt.Errorf("no files selected")
+	}
+	for _, index := range o.Files {
+		if index < 0 || index >= numFiles {
+			return fmt.Errorf("file index %d out of range", index)
+		}
+	}
+	o.Category = strings.TrimSpace(o.Category)
+	o.Tags = normalizeTags(o.Tags)
+	return nil
+}
+
+// filePriorities returns the priorities that download only the selected
+// files, or nil to download everything
+func (o AddOptions) filePriorities(numFiles int) []string {
+	if o.Files == nil {
+		return nil
+	}
+	priorities := make([]string, numFiles)
+	for i := range priorities {
+		priorities[i] = FilePrioritySkip
+		if slices.Contains(o.Files, i) {
+			priorities[i] = FilePriorityNormal
+		}
+	}
+	return priorities
+}
+
+// normalizeTags trims tags and drops empty and duplicate ones
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// awaitPreviewInfo fills in a magnet preview once its metadata arrives.
+// The metadata torrent is dropped then; committing adds the torrent again
+// with its chosen storage.
+func (e *Engine) awaitPreviewInfo(p *pendingPreview) {
+	e.previewsMutex.Lock()
+	t := p.magnet
+	e.previewsMutex.Unlock()
+
+	for {
+		select {
+		case <-t.GotInfo():
+		case <-t.Closed():
+		case <-p.cancel:
+			return
+		}
+		if t.Info() != nil {
+			break
+		}
+
+		// The client was rebuilt; wait on the torrent that took over
+		e.previewsMutex.Lock()
+		next := p.magnet
+		e.previewsMutex.Unlock()
+		if next == nil || next == t {
+			return
+		}
+		t = next
+	}
+
+	info := t.Info()
+	infoBytes := t.Metainfo().InfoBytes
+
+	e.previewsMutex.Lock()
+	if e.previews[p.preview.ID] != p {
+		e.previewsMutex.Unlock()
+		return
+	}
+	p.setInfo(infoBytes, info)
+	// Dropped before the preview can be committed, so that the torrent
+	// added then doesn't find it in the client
+	e.detachTorrent(p.magnet)
+	p.magnet = nil
+	preview := p.preview
+	e.previewsMutex.Unlock()
+
+	log.Printf("✓ Got preview metadata: %s (%d files)", preview.Name, len(preview.Files))
+	e.emit("preview-ready", preview)
+}
+
+// setInfo fills in the preview from the torrent's info
+func (p *pendingPreview) setInfo(infoBytes []byte, info *metainfo.Info) {
+	p.infoBytes = infoBytes
+	p.preview.Ready = true
+	p.preview.Name = info.BestName()
+	p.preview.Size = info.TotalLength()
+	p.preview.SizeStr = formatBytes(p.preview.Size)
+	p.preview.PieceLength = info.PieceLength
+	p.preview.NumPieces = info.NumPieces()
+	p.preview.Files = nil
+	for i, fi := range info.UpvertedFiles() {
+		p.preview.Files = append(p.preview.Files, FileInfo{
+			Name:     fi.DisplayPath(info),
+			Size:     fi.Length,
+			SizeStr:  formatBytes(fi.Length),
+			Path:     strings.Join(append([]string{info.BestName()}, fi.BestPath()...), "/"),
+			Index:    i,
+			Priority: FilePriorityNormal,
+		})
+	}
+}
+
+// closePreview stops waiting for metadata and drops the metadata
+// torrent. It is called with previewsMutex held once the preview has been
+// removed.
+func (e *Engine) closePreview(p *pendingPreview) {
+	close(p.cancel)
+	if p.magnet != nil {
+		e.detachTorrent(p.magnet)
+	}
+}
+
+// storePreview records a new preview and returns a copy of it
+func (e *Engine) storePreview(p *pendingPreview) TorrentPreview {
+	e.previewsMutex.Lock()
+	defer e.previewsMutex.Unlock()
+
+	e.previews[p.preview.ID] = p
+	return p.preview
+}
+
+// existingPreview returns the pending preview of a torrent, if any
+func (e *Engine) existingPreview(hash string) (TorrentPreview, bool) {
+	e.previewsMutex.Lock()
+	defer e.previewsMutex.Unlock()
+
+	p, ok := e.previews[hash]
+	if !ok {
+		return TorrentPreview{}, false
+	}
+	return p.preview, true
+}
+
+// takePreview removes and closes a pending preview and returns it, or
+// nil
+func (e *Engine) takePreview(id string) *pendingPreview {
+	e.previewsMutex.Lock()
+	defer e.previewsMutex.Unlock()
+
+	p, ok := e.previews[id]
+	if !ok {
+		return nil
+	}
+	delete(e.previews, id)
+	e.closePreview(p)
+	return p
+}
+
+// dropPreview cancels the pending preview of a torrent that is added
+// without committing it. Its metadata torrent would stand in for the added
+// one in the client and take it along when the preview is closed. The
+// "preview-cancelled" event is sent with the preview's id.
+func (e *Engine) dropPreview(hash string) {
+	if e.takePreview(hash) == nil {
+		return
+	}
+	log.Printf("✗ Cancelled preview %s, the torrent was added", hash)
+	e.emit("preview-cancelled", hash)
+}
+
+// checkNotAdded fails if a torrent has already been added
+func (e *Engine) checkNotAdded(hash string) error {
+	e.torrentsMutex.RLock()
+	defer e.torrentsMutex.RUnlock()
+
+	if _, exists := e.torrents[hash]; exists {
+		return fmt.Errorf("torrent already added")
+	}
+	return nil
+}
+
+// previewLoop drops expired previews until the engine is closed
+func (e *Engine) previewLoop() {
+	ticker := time.NewTicker(previewCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case now := <-ticker.C:
+			e.expirePreviews(now)
+		}
+	}
+}
+
+// expirePreviews drops every preview that was not committed in time
+func (e *Engine) expirePreviews(now time.Time) {
+	e.previewsMutex.Lock()
+	var expired []*pendingPreview
+	for id, p := range e.previews {
+		if now.After(p.preview.ExpiresAt) {
+			expired = append(expired, p)
+			delete(e.previews, id)
+			e.closePreview(p)
+		}
+	}
+	e.previewsMutex.Unlock()
+
+	for _, p := range expired {
+		log.Printf("⌛ Preview expired: %s", p.preview.ID)
+		e.emit("preview-expired", p.preview.ID)
+	}
+}
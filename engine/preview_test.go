@@ -0,0 +1,96 @@
+package engine
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"torrentflow/config"
+)
+
+func TestAddDropsPendingPreview(t *testing.T) {
+	mi, _ := seededTorrent(t)
+	magnet := mi.Magnet(nil, nil).String()
+
+	tests := []struct {
+		name    string
+		preview func(e *Engine) error
+		add     func(e *Engine, opts AddOptions) (string, error)
+	}{
+		{
+			name: "magnet",
+			preview: func(e *Engine) error {
+				_, err := e.PreviewMagnet(magnet)
+				return err
+			},
+			add: func(e *Engine, opts AddOptions) (string, error) {
+				return e.AddMagnet(magnet, opts)
+			},
+		},
+		{
+			name: "metainfo",
+			preview: func(e *Engine) error {
+				_, err := e.PreviewMetaInfo(mi)
+				return err
+			},
+			add: func(e *Engine, opts AddOptions) (string, error) {
+				return e.AddTorrentMetaInfo(mi, opts)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t)
+			if err := tt.preview(e); err != nil {
+				t.Fatal(err)
+			}
+
+			savePath := t.TempDir()
+			hash, err := tt.add(e, AddOptions{SavePath: savePath})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := e.GetPreview(hash); !errors.Is(err, ErrPreviewNotFound) {
+				t.Errorf("preview still pending after the add: %v", err)
+			}
+			session := e.getSession(hash)
+			if got := session.root(e.downloadDir); got != savePath {
+				t.Errorf("save path = %s, want %s", got, savePath)
+			}
+
+			// What the preview would have dropped when it expired
+			e.expirePreviews(time.Now().Add(2 * previewTTL))
+			e.torrentsMutex.RLock()
+			tor := e.torrents[hash]
+			e.torrentsMutex.RUnlock()
+			if isDetached(tor) {
+				t.Error("added torrent was dropped with the preview")
+			}
+		})
+	}
+}
+
+// newTestEngine returns an engine with a client that finds no peers,
+// closed at the end of the test
+func newTestEngine(t *testing.T) *Engine {
+	settings := config.Default()
+	settings.DownloadDir = t.TempDir()
+	settings.StateFile = filepath.Join(t.TempDir(), "torrents.json")
+	settings.ListenPort = 0
+	settings.PortForwarding = false
+	settings.EnableDHT = false
+	settings.Trackers = nil
+
+	e := New(settings)
+	client, sockets, err := e.newClient(settings, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.client = client
+	t.Cleanup(func() {
+		client.Close()
+		closeSockets(sockets)
+	})
+	return e
+}
@@ -0,0 +1,171 @@
+package engine
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"torrentflow/config"
+
+	"github.com/anacrolix/torrent"
+	"golang.org/x/net/proxy"
+)
+
+// proxyHandshakeTimeout bounds the proxy handshake when the caller sets
+// no deadline
+const proxyHandshakeTimeout = 30 * time.Second
+
+// configureProxy routes tracker announces, web seeds and peer connections
+// through the proxy in the settings. The proxy itself is reached with
+// forward. It returns the dialer peers must be reached through, to be added
+// once the client is built, or nil when peers are connected to directly.
+//
+// Proxied peers are only dialled over TCP: the client gets no peer sockets,
+// so it doesn't listen for incoming connections and uTP is off, as neither
+// can go through the proxy. UDP trackers are skipped for the same reason,
+// and the DHT is off whatever the settings say, as it would reveal the
+// real address.
+func configureProxy(cfg *torrent.ClientConfig, settings config.ProxySettings, forward *net.Dialer) (torrent.Dialer, error) {
+	if !settings.Enabled() {
+		return nil, nil
+	}
+
+	// HTTP and websocket trackers, and web seeds unless overridden below.
+	// net/http speaks both SOCKS5 and HTTP proxies, with authentication.
+	cfg.HTTPProxy = http.ProxyURL(settings.URL())
+	cfg.TrackerListenPacket = func(network, addr string) (net.PacketConn, error) {
+		return nil, fmt.Errorf("UDP trackers can't be reached through the proxy")
+	}
+
+	if settings.TrackersOnly {
+		// Web seeds are fetched directly, with the client's usual
+		// per-host limit
+		cfg.WebTransport = &http.Transport{
+			DialContext:     cfg.HTTPDialContext,
+			MaxConnsPerHost: 10,
+		}
+		return nil, nil
+	}
+
+	dialer, err := newProxyDialer(settings, forward)
+	if err != nil {
+		return nil, err
+	}
+	cfg.DisableWebtorrent = true
+	cfg.AcceptPeerConnections = false
+	return torrent.NetworkDialer{Network: "tcp", Dialer: dialer}, nil
+}
+
+// contextDialer opens connections through a proxy
+type contextDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// newProxyDialer returns a dialer connecting through the proxy
+func newProxyDialer(settings config.ProxySettings, forward *net.Dialer) (contextDialer, error) {
+	addr := settings.URL().Host
+
+	switch settings.Type {
+	case config.ProxySOCKS5:
+		var auth *proxy.Auth
+		if settings.Username != "" {
+			auth = &proxy.Auth{User: settings.Username, Password: settings.Password}
+		}
+		d, err := proxy.SOCKS5("tcp", addr, auth, forward)
+		if err != nil {
+			return nil, err
+		}
+		cd, ok := d.(contextDialer)
+		if !ok {
+			return nil, fmt.Errorf("SOCKS5 dialer can't be cancelled")
+		}
+		return cd, nil
+	case config.ProxyHTTP:
+		d := &httpConnectDialer{addr: addr, forward: forward}
+		if settings.Username != "" {
+			d.auth = "Basic " + base64.StdEncoding.EncodeToString([]byte(settings.Username+":"+settings.Password))
+		}
+		return d, nil
+	default:
+		return nil, fmt.Errorf("unknown proxy type %q", settings.Type)
+	}
+}
+
+// httpConnectDialer tunnels connections through an HTTP proxy with the
+// CONNECT method
+type httpConnectDialer struct {
+	addr    string
+	forward *net.Dialer
+	// auth is the Proxy-Authorization header, if any
+	auth string
+}
+
+// DialContext implements contextDialer
+func (d *httpConnectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.forward.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach proxy: %w", err)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(proxyHandshakeTimeout)
+	}
+	conn.SetDeadline(deadline)
+
+	// Close the connection if the context ends during the handshake
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if d.auth != "" {
+		req.Header.Set("Proxy-Authorization", d.auth)
+	}
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send CONNECT: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read CONNECT response: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused CONNECT to %s: %s", addr, resp.Status)
+	}
+
+	if !stop() {
+		// The context ended and closed the connection
+		return nil, ctx.Err()
+	}
+	conn.SetDeadline(time.Time{})
+	if br.Buffered() > 0 {
+		// The peer spoke before the response was read to the end
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection with bytes already read into a buffer
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
@@ -0,0 +1,322 @@
+package engine
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"torrentflow/config"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestProxy(t *testing.T) {
+	tests := []struct {
+		name         string
+		user, pass   string
+		trackersOnly bool
+	}{
+		{name: "socks5"},
+		{name: "socks5 with auth", user: "seed", pass: "rush"},
+		{name: "trackers only", trackersOnly: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			socks := startSOCKS5(t, tt.user, tt.pass)
+
+			tracker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("d8:intervali1800e5:peers0:e"))
+			}))
+			defer tracker.Close()
+			webSeed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "gone", http.StatusNotFound)
+			}))
+			defer webSeed.Close()
+
+			peers, err := net.Listen("tcp4", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer peers.Close()
+			peerDialed := make(chan struct{}, 1)
+			go func() {
+				for {
+					conn, err := peers.Accept()
+					if err != nil {
+						return
+					}
+					conn.Close()
+					select {
+					case peerDialed <- struct{}{}:
+					default:
+					}
+				}
+			}()
+
+			settings := config.Default()
+			settings.DownloadDir = t.TempDir()
+			settings.StateFile = filepath.Join(t.TempDir(), "torrents.json")
+			settings.ListenPort = 0
+			settings.PortForwarding = false
+			settings.Trackers = nil
+			settings.Proxy = config.ProxySettings{
+				Type:         config.ProxySOCKS5,
+				Host:         "127.0.0.1",
+				Port:         socks.port(),
+				Username:     tt.user,
+				Password:     tt.pass,
+				TrackersOnly: tt.trackersOnly,
+			}
+
+			e := New(settings)
+			client, _, err := e.newClient(settings, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer client.Close()
+
+			tor, err := client.AddTorrent(proxyTestTorrent(t, tracker.URL+"/announce", webSeed.URL+"/file.bin"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tor.DownloadAll()
+			tor.AddPeers([]torrent.PeerInfo{{Addr: peers.Addr()}})
+
+			waitFor(t, "tracker announce through the proxy", func() bool {
+				return socks.dialed(tracker.Listener.Addr().String())
+			})
+			if tt.trackersOnly {
+				select {
+				case <-peerDialed:
+				case <-time.After(10 * time.Second):
+					t.Fatal("peer was not dialled")
+				}
+				if socks.dialed(peers.Addr().String()) {
+					t.Error("peer was dialled through the proxy")
+				}
+				return
+			}
+			if len(client.DhtServers()) != 0 {
+				t.Error("DHT is on while peers go through the proxy")
+			}
+			waitFor(t, "peer dial through the proxy", func() bool {
+				return socks.dialed(peers.Addr().String())
+			})
+			waitFor(t, "web seed request through the proxy", func() bool {
+				return socks.dialed(webSeed.Listener.Addr().String())
+			})
+		})
+	}
+}
+
+func TestProxyAuthRefused(t *testing.T) {
+	socks := startSOCKS5(t, "seed", "rush")
+	target, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+
+	settings := config.ProxySettings{
+		Type:     config.ProxySOCKS5,
+		Host:     "127.0.0.1",
+		Port:     socks.port(),
+		Username: "seed",
+		Password: "wrong",
+	}
+	d, err := newProxyDialer(settings, &net.Dialer{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn, err := d.DialContext(context.Background(), "tcp", target.Addr().String()); err == nil {
+		conn.Close()
+		t.Fatal("dial with a wrong password succeeded")
+	}
+	if socks.dialed(target.Addr().String()) {
+		t.Fatal("proxy connected with a wrong password")
+	}
+}
+
+// proxyTestTorrent returns a torrent of a file the client doesn't have,
+// announced to tracker and served by webSeed
+func proxyTestTorrent(t *testing.T, tracker, webSeed string) *metainfo.MetaInfo {
+	path := filepath.Join(t.TempDir(), "file.bin")
+	if err := os.WriteFile(path, make([]byte, 64*1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := metainfo.Info{PieceLength: 16 * 1024}
+	if err := info.BuildFromFilePath(path); err != nil {
+		t.Fatal(err)
+	}
+	return &metainfo.MetaInfo{
+		InfoBytes: bencode.MustMarshal(info),
+		Announce:  tracker,
+		UrlList:   []string{webSeed},
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// socks5StandIn is a SOCKS5 proxy (RFC 1928) that remembers where it was
+// asked to connect. It asks for username and password authentication
+// (RFC 1929) when user is set.
+type socks5StandIn struct {
+	ln         net.Listener
+	user, pass string
+
+	mu      sync.Mutex
+	targets []string
+}
+
+func startSOCKS5(t *testing.T, user, pass string) *socks5StandIn {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socks5StandIn{ln: ln, user: user, pass: pass}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *socks5StandIn) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// dialed reports whether the proxy connected to addr
+func (s *socks5StandIn) dialed(addr string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Contains(s.targets, addr)
+}
+
+func (s *socks5StandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	// Greeting: version, methods
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(conn, head); err != nil || head[0] != 5 {
+		return
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	method := byte(0)
+	if s.user != "" {
+		method = 2
+	}
+	if !slices.Contains(methods, method) {
+		conn.Write([]byte{5, 0xff})
+		return
+	}
+	conn.Write([]byte{5, method})
+
+	if method == 2 {
+		user, pass := readAuthField(conn, true), readAuthField(conn, false)
+		if user != s.user || pass != s.pass {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	// Request: version, command, reserved, address
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil || req[1] != 1 {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1, 4:
+		ip := make([]byte, 4)
+		if req[3] == 4 {
+			ip = make([]byte, 16)
+		}
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case 3:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(conn, n); err != nil {
+			return
+		}
+		name := make([]byte, n[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return
+		}
+		host = string(name)
+	default:
+		return
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+	s.mu.Lock()
+	s.targets = append(s.targets, addr)
+	s.mu.Unlock()
+
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer target.Close()
+	conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+	conn.SetDeadline(time.Time{})
+
+	go io.Copy(target, conn)
+	io.Copy(conn, target)
+}
+
+// readAuthField reads the username, after the subnegotiation version, or
+// the password of an RFC 1929 request
+func readAuthField(r io.Reader, first bool) string {
+	n := 1
+	if first {
+		n = 2
+	}
+	head := make([]byte, n)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return ""
+	}
+	field := make([]byte, head[n-1])
+	if _, err := io.ReadFull(r, field); err != nil {
+		return ""
+	}
+	return string(field)
+}
@@ -0,0 +1,154 @@
+package engine
+
+import (
+	"errors"
+	"log"
+	"slices"
+
+	"github.com/anacrolix/torrent"
+)
+
+// Queue moves accepted by MoveInQueue
+const (
+	QueueUp     = "up"
+	QueueDown   = "down"
+	QueueTop    = "top"
+	QueueBottom = "bottom"
+)
+
+// ErrInvalidQueueMove is returned for an unknown queue move
+var ErrInvalidQueueMove = errors.New("queue move must be up, down, top or bottom")
+
+// enqueue puts a torrent at the bottom of the queue
+func (e *Engine) enqueue(hash string) {
+	e.queueMutex.Lock()
+	defer e.queueMutex.Unlock()
+
+	if !slices.Contains(e.queue, hash) {
+		e.queue = append(e.queue, hash)
+	}
+}
+
+// dequeue drops a removed torrent from the queue
+func (e *Engine) dequeue(hash string) {
+	e.queueMutex.Lock()
+	defer e.queueMutex.Unlock()
+
+	e.queue = slices.DeleteFunc(e.queue, func(h string) bool { return h == hash })
+	delete(e.queuedTorrents, hash)
+}
+
+// queuePosition returns the 1-based queue position of a torrent, or 0 if
+// it is not queued
+func (e *Engine) queuePosition(hash string) int {
+	e.queueMutex.Lock()
+	defer e.queueMutex.Unlock()
+	return slices.Index(e.queue, hash) + 1
+}
+
+// isQueued reports whether the queue is holding a torrent back
+func (e *Engine) isQueued(hash string) bool {
+	e.queueMutex.Lock()
+	defer e.queueMutex.Unlock()
+	return e.queuedTorrents[hash]
+}
+
+// isStopped reports whether a torrent is paused or held back by the queue
+func (e *Engine) isStopped(hash string) bool {
+	return e.isPaused(hash) || e.isQueued(hash)
+}
+
+// MoveInQueue moves a torrent up, down, to the top or to the bottom of
+// the queue. Torrents higher up get download and seed slots first.
+func (e *Engine) MoveInQueue(infoHash, move string) error {
+	e.queueMutex.Lock()
+	i := slices.Index(e.queue, infoHash)
+	if i < 0 {
+		e.queueMutex.Unlock()
+		return ErrTorrentNotFound
+	}
+
+	j := i
+	switch move {
+	case QueueUp:
+		j = max(i-1, 0)
+	case QueueDown:
+		j = min(i+1, len(e.queue)-1)
+	case QueueTop:
+		j = 0
+	case QueueBottom:
+		j = len(e.queue) - 1
+	default:
+		e.queueMutex.Unlock()
+		return ErrInvalidQueueMove
+	}
+	e.queue = slices.Insert(slices.Delete(e.queue, i, i+1), j, infoHash)
+	e.queueMutex.Unlock()
+
+	e.updateQueue()
+	e.saveTorrentStates()
+
+	log.Printf("↕ Moved %s %s in queue (position %d)", infoHash, move, j+1)
+	e.emit("torrent-updated", infoHash)
+	return nil
+}
+
+// updateQueue starts and stops torrents in queue order so that no more
+// than the configured number download and seed at the same time. Paused,
+// moving and checking torrents and torrents still fetching metadata do
+// not take a slot.
+func (e *Engine) updateQueue() {
+	settings := e.Settings().Queue
+
+	e.torrentsMutex.RLock()
+	torrents := make(map[string]*torrent.Torrent, len(e.torrents))
+	for hash, t := range e.torrents {
+		torrents[hash] = t
+	}
+	e.torrentsMutex.RUnlock()
+
+	e.queueMutex.Lock()
+	defer e.queueMutex.Unlock()
+
+	downloads, seeds := 0, 0
+	for _, hash := range e.queue {
+		t, ok := torrents[hash]
+		if !ok {
+			continue
+		}
+
+		e.pausedMutex.RLock()
+		isPaused := e.pausedTorrents[hash]
+		e.pausedMutex.RUnlock()
+		session := e.getSession(hash)
+		if isPaused || session.busy() || t.Info() == nil {
+			continue
+		}
+
+		hold := false
+		if !session.downloadDone(t) {
+			downloads++
+			hold = settings.MaxActiveDownloads > 0 && downloads > settings.MaxActiveDownloads
+		} else {
+			seeds++
+			hold = settings.MaxActiveSeeds > 0 && seeds > settings.MaxActiveSeeds
+		}
+
+		if hold == e.queuedTorrents[hash] {
+			continue
+		}
+		if hold {
+			e.queuedTorrents[hash] = true
+			stopDownload(t)
+			t.DisallowDataDownload()
+			t.DisallowDataUpload()
+			log.Printf("⏳ Queued torrent: %s", t.Name())
+		} else {
+			delete(e.queuedTorrents, hash)
+			t.AllowDataDownload()
+			t.AllowDataUpload()
+			e.startDownload(hash, t)
+			log.Printf("▶ Started queued torrent: %s", t.Name())
+		}
+	}
+}
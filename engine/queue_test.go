@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"torrentflow/config"
+
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+)
+
+func TestMoveInQueue(t *testing.T) {
+	tests := []struct {
+		hash, move string
+		want       []string
+		err        error
+	}{
+		{"c", QueueTop, []string{"c", "a", "b", "d"}, nil},
+		{"a", QueueTop, []string{"a", "b", "c", "d"}, nil},
+		{"b", QueueBottom, []string{"a", "c", "d", "b"}, nil},
+		{"d", QueueBottom, []string{"a", "b", "c", "d"}, nil},
+		{"b", QueueUp, []string{"b", "a", "c", "d"}, nil},
+		{"a", QueueUp, []string{"a", "b", "c", "d"}, nil},
+		{"c", QueueDown, []string{"a", "b", "d", "c"}, nil},
+		{"d", QueueDown, []string{"a", "b", "c", "d"}, nil},
+		{"e", QueueTop, []string{"a", "b", "c", "d"}, ErrTorrentNotFound},
+		{"b", "sideways", []string{"a", "b", "c", "d"}, ErrInvalidQueueMove},
+	}
+	for _, tt := range tests {
+		e := New(config.Default())
+		e.queue = []string{"a", "b", "c", "d"}
+		if err := e.MoveInQueue(tt.hash, tt.move); !errors.Is(err, tt.err) {
+			t.Errorf("MoveInQueue(%s, %s) error = %v, want %v", tt.hash, tt.move, err, tt.err)
+		}
+		if !slices.Equal(e.queue, tt.want) {
+			t.Errorf("MoveInQueue(%s, %s) left queue %v, want %v", tt.hash, tt.move, e.queue, tt.want)
+		}
+	}
+}
+
+func TestUpdateQueue(t *testing.T) {
+	e := newTestEngine(t)
+	e.settings.Queue = config.QueueSettings{MaxActiveDownloads: 2, MaxActiveSeeds: 1}
+
+	d1 := addTestTorrent(t, e, "d1", false)
+	d2 := addTestTorrent(t, e, "d2", false)
+	s1 := addTestTorrent(t, e, "s1", true)
+	d3 := addTestTorrent(t, e, "d3", false)
+	s2 := addTestTorrent(t, e, "s2", true)
+	names := map[string]string{d1: "d1", d2: "d2", d3: "d3", s1: "s1", s2: "s2"}
+
+	wantQueued := func(what string, want ...string) {
+		t.Helper()
+		var got []string
+		for _, hash := range e.queue {
+			if e.isQueued(hash) {
+				got = append(got, names[hash])
+			}
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: queued %v, want %v", what, got, want)
+		}
+	}
+
+	// Downloads and seeds take separate slots in queue order
+	e.updateQueue()
+	wantQueued("added", "d3", "s2")
+
+	// A paused torrent gives up its slot
+	if err := e.PauseTorrent(d1); err != nil {
+		t.Fatal(err)
+	}
+	wantQueued("first download paused", "s2")
+	if err := e.PauseTorrent(s1); err != nil {
+		t.Fatal(err)
+	}
+	wantQueued("first seed paused")
+
+	// and takes it back from lower down the queue on resume
+	if err := e.ResumeTorrent(d1); err != nil {
+		t.Fatal(err)
+	}
+	wantQueued("first download resumed", "d3")
+
+	// Moving a torrent up hands it the slot of the one it passes
+	if err := e.MoveInQueue(d3, QueueTop); err != nil {
+		t.Fatal(err)
+	}
+	wantQueued("last download moved to the top", "d2")
+	if err := e.MoveInQueue(d3, QueueBottom); err != nil {
+		t.Fatal(err)
+	}
+	wantQueued("moved back to the bottom", "d3")
+
+	// A torrent that is moving storage keeps no slot either
+	e.sessionsMutex.Lock()
+	e.sessions[d1].moving = true
+	e.sessionsMutex.Unlock()
+	e.updateQueue()
+	wantQueued("first download moving")
+	e.sessionsMutex.Lock()
+	e.sessions[d1].moving = false
+	e.sessionsMutex.Unlock()
+
+	// 0 lifts a limit
+	e.settings.Queue = config.QueueSettings{}
+	if err := e.ResumeTorrent(s1); err != nil {
+		t.Fatal(err)
+	}
+	wantQueued("no limits")
+}
+
+// addTestTorrent adds a torrent of a small file named name that is
+// complete on disk when complete is set, and returns its info hash
+func addTestTorrent(t *testing.T, e *Engine, name string, complete bool) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info := metainfo.Info{PieceLength: 16 * 1024}
+	if err := info.BuildFromFilePath(path); err != nil {
+		t.Fatal(err)
+	}
+	if !complete {
+		os.Remove(path)
+	}
+
+	hash, err := e.AddTorrentMetaInfo(&metainfo.MetaInfo{InfoBytes: bencode.MustMarshal(info)}, AddOptions{SavePath: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if complete {
+		e.torrentsMutex.RLock()
+		tor := e.torrents[hash]
+		e.torrentsMutex.RUnlock()
+		waitFor(t, name+" to be checked", func() bool {
+			session := e.getSession(hash)
+			return session.downloadDone(tor)
+		})
+	}
+	return hash
+}
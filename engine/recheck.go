@@ -0,0 +1,220 @@
+package engine
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// RecheckProgress is sent with the "recheck-progress" event after each
+// piece has been verified
+type RecheckProgress struct {
+	InfoHash      string  `json:"infoHash"`
+	Piece         int     `json:"piece"`
+	Passed        bool    `json:"passed"`
+	PiecesChecked int     `json:"piecesChecked"`
+	NumPieces     int     `json:"numPieces"`
+	Progress      float64 `json:"progress"`
+}
+
+// RecheckReport is sent with the "recheck-finished" event once every
+// piece of a torrent has been verified against the data on disk
+type RecheckReport struct {
+	InfoHash       string `json:"infoHash"`
+	Name           string `json:"name"`
+	NumPieces      int    `json:"numPieces"`
+	PiecesChecked  int    `json:"piecesChecked"`
+	PiecesComplete int    `json:"piecesComplete"`
+	// PiecesFailed were complete before the check but failed it
+	PiecesFailed int `json:"piecesFailed"`
+	// Progress is how much of the wanted data is complete after the check
+	Progress float64 `json:"progress"`
+	// Missing are wanted files that are not on disk
+	Missing []string `json:"missing"`
+	// Corrupted are files holding pieces that failed the check
+	Corrupted  []string  `json:"corrupted"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// RecheckTorrent verifies every piece of a torrent against the data on
+// disk, e.g. after a disk problem or after files were moved by hand. The
+// check runs in the background with transfers stopped; the torrent shows
+// as "checking" until the report is sent.
+func (e *Engine) RecheckTorrent(infoHash string) error {
+	e.torrentsMutex.RLock()
+	t, exists := e.torrents[infoHash]
+	e.torrentsMutex.RUnlock()
+
+	if !exists {
+		return ErrTorrentNotFound
+	}
+	if t.Info() == nil {
+		return fmt.Errorf("torrent metadata not available yet")
+	}
+
+	e.sessionsMutex.Lock()
+	session, ok := e.sessions[infoHash]
+	switch {
+	case !ok:
+		e.sessionsMutex.Unlock()
+		return ErrTorrentNotFound
+	case session.busy():
+		e.sessionsMutex.Unlock()
+		return ErrTorrentBusy
+	}
+	session.checking = true
+	e.sessionsMutex.Unlock()
+
+	go e.recheck(infoHash, t)
+
+	e.emit("torrent-updated", infoHash)
+	return nil
+}
+
+// GetRecheckReport returns the report of the last recheck of a torrent
+// since the engine started
+func (e *Engine) GetRecheckReport(infoHash string) (RecheckReport, error) {
+	e.sessionsMutex.RLock()
+	defer e.sessionsMutex.RUnlock()
+
+	session, ok := e.sessions[infoHash]
+	if !ok {
+		return RecheckReport{}, ErrTorrentNotFound
+	}
+	if session.recheck == nil {
+		return RecheckReport{}, fmt.Errorf("torrent has not been rechecked")
+	}
+	return *session.recheck, nil
+}
+
+// recheck does the work of RecheckTorrent. Paused torrents are added back
+// to the client for the check and dropped again afterwards.
+func (e *Engine) recheck(hash string, t *torrent.Torrent) {
+	report := RecheckReport{
+		InfoHash:  hash,
+		Name:      t.Name(),
+		NumPieces: t.NumPieces(),
+		StartedAt: time.Now(),
+	}
+
+	log.Printf("🔎 Rechecking %s (%d pieces)", t.Name(), report.NumPieces)
+
+	if isDetached(t) {
+		state := e.buildTorrentState(hash, t)
+		state.IsPaused = false
+		newT, err := e.reattachTorrent(hash, state)
+		if err != nil {
+			report.Error = fmt.Sprintf("failed to re-add torrent: %v", err)
+			e.finishRecheck(hash, t, report)
+			return
+		}
+		t = newT
+		<-t.GotInfo()
+	}
+
+	// Stop transfers while the data is read back
+	stopDownload(t)
+	t.DisallowDataDownload()
+	t.DisallowDataUpload()
+
+	e.verifyPieces(hash, t, &report)
+	e.finishRecheck(hash, t, report)
+}
+
+// verifyPieces hashes every piece in turn and fills in the report
+func (e *Engine) verifyPieces(hash string, t *torrent.Torrent, report *RecheckReport) {
+	session := e.getSession(hash)
+	numPieces := t.NumPieces()
+
+	wasComplete := make([]bool, numPieces)
+	for i := range wasComplete {
+		wasComplete[i] = t.PieceState(i).Complete
+	}
+
+	failed := make([]bool, numPieces)
+	progress := RecheckProgress{InfoHash: hash, NumPieces: numPieces}
+	for i := 0; i < numPieces; i++ {
+		select {
+		case <-e.done:
+			report.Error = "interrupted by shutdown"
+			return
+		default:
+		}
+
+		t.Piece(i).VerifyData()
+		passed := t.PieceState(i).Complete
+		if passed {
+			report.PiecesComplete++
+		} else if wasComplete[i] {
+			failed[i] = true
+			report.PiecesFailed++
+		}
+		report.PiecesChecked++
+
+		progress.Piece = i
+		progress.Passed = passed
+		progress.PiecesChecked = i + 1
+		progress.Progress = float64(i+1) / float64(numPieces) * 100
+		e.emit("recheck-progress", progress)
+	}
+
+	paths := session.filePaths(e.downloadDir, t)
+	for i, file := range t.Files() {
+		if session.filePriority(i) == FilePrioritySkip || file.Length() == 0 {
+			continue
+		}
+		if _, err := os.Stat(paths[i]); os.IsNotExist(err) {
+			report.Missing = append(report.Missing, paths[i])
+			continue
+		}
+		for p := file.BeginPieceIndex(); p < file.EndPieceIndex(); p++ {
+			if failed[p] {
+				report.Corrupted = append(report.Corrupted, paths[i])
+				break
+			}
+		}
+	}
+
+	completed, wanted := session.wantedBytes(t)
+	if wanted > 0 {
+		report.Progress = float64(completed) / float64(wanted) * 100
+	}
+}
+
+// finishRecheck starts the torrent again as it was before the check and
+// sends the report
+func (e *Engine) finishRecheck(hash string, t *torrent.Torrent, report RecheckReport) {
+	report.FinishedAt = time.Now()
+
+	e.sessionsMutex.Lock()
+	if s, ok := e.sessions[hash]; ok {
+		s.checking = false
+		s.recheck = &report
+	}
+	e.sessionsMutex.Unlock()
+
+	switch {
+	case e.isPaused(hash):
+		e.detachTorrent(t)
+	case !e.isStopped(hash):
+		t.AllowDataDownload()
+		t.AllowDataUpload()
+		e.startDownload(hash, t)
+	}
+	e.updateQueue()
+	e.saveTorrentStates()
+
+	if report.Error != "" {
+		log.Printf("❌ Recheck of %s failed: %s", report.Name, report.Error)
+	} else {
+		log.Printf("✓ Rechecked %s: %d/%d pieces complete, %d failed, %d files missing, %d corrupted",
+			report.Name, report.PiecesComplete, report.NumPieces, report.PiecesFailed, len(report.Missing), len(report.Corrupted))
+	}
+	e.emit("recheck-finished", report)
+	e.emit("torrent-updated", hash)
+}
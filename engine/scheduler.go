@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"log"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// scheduleInterval is how often schedule rules are evaluated
+const scheduleInterval = 30 * time.Second
+
+// ScheduleState describes what the bandwidth schedule currently applies.
+// It is sent with the "schedule-changed" event.
+type ScheduleState struct {
+	// Profile is the speed profile in force (see config.Profile*)
+	Profile string `json:"profile"`
+	// Scheduled is set while a schedule rule picks the profile rather
+	// than the manual alternative speed toggle
+	Scheduled bool `json:"scheduled"`
+	// Paused is set while a rule keeps all torrents paused
+	Paused bool `json:"paused"`
+}
+
+// scheduleLoop re-evaluates the schedule until the engine is closed
+func (e *Engine) scheduleLoop() {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+			e.applySchedule(time.Now())
+		}
+	}
+}
+
+// applySchedule switches to the profile the schedule wants at now and
+// reports whether anything changed
+func (e *Engine) applySchedule(now time.Time) bool {
+	settings := e.Settings()
+
+	state := ScheduleState{Profile: settings.Bandwidth.ManualProfile()}
+	if rule := settings.Schedule.Active(now); rule != nil {
+		state = ScheduleState{
+			Profile:   rule.Profile,
+			Scheduled: true,
+			Paused:    rule.PauseAll,
+		}
+	}
+
+	e.scheduleMutex.Lock()
+	old := e.schedule
+	e.schedule = state
+	e.scheduleMutex.Unlock()
+
+	if state == old {
+		return false
+	}
+
+	e.applyGlobalLimits()
+	if state.Paused != old.Paused || old.Profile == "" {
+		e.setScheduledPause(state.Paused)
+	}
+
+	log.Printf("🕑 Schedule switched to %s profile (scheduled: %v, paused: %v)", state.Profile, state.Scheduled, state.Paused)
+	e.emit("schedule-changed", state)
+	return true
+}
+
+// Schedule returns what the bandwidth schedule currently applies
+func (e *Engine) Schedule() ScheduleState {
+	e.scheduleMutex.Lock()
+	defer e.scheduleMutex.Unlock()
+	return e.schedule
+}
+
+// speedProfile returns the speed profile in force
+func (e *Engine) speedProfile() string {
+	if profile := e.Schedule().Profile; profile != "" {
+		return profile
+	}
+	return e.Settings().Bandwidth.ManualProfile()
+}
+
+// setScheduledPause pauses every running torrent, or resumes the ones a
+// previous scheduled pause stopped unless the kill-switch still holds
+// them. Torrents paused by the user stay paused either way.
+func (e *Engine) setScheduledPause(pause bool) {
+	e.torrentsMutex.RLock()
+	torrents := make(map[string]*torrent.Torrent, len(e.torrents))
+	for hash, t := range e.torrents {
+		torrents[hash] = t
+	}
+	e.torrentsMutex.RUnlock()
+
+	changed := 0
+	for hash, t := range torrents {
+		if e.isBusy(hash) {
+			continue
+		}
+
+		if pause {
+			if e.pauseFor(hash, t, pausedBySchedule) {
+				changed++
+			}
+			continue
+		}
+		resumed, err := e.releasePause(hash, t, pausedBySchedule)
+		if err != nil {
+			log.Printf("⚠ Schedule failed to resume %s: %v", t.Name(), err)
+			continue
+		}
+		if resumed {
+			changed++
+		}
+	}
+
+	if changed == 0 {
+		return
+	}
+	if pause {
+		log.Printf("⏸ Schedule paused %d torrent(s)", changed)
+	} else {
+		log.Printf("▶ Schedule resumed %d torrent(s)", changed)
+	}
+	e.saveTorrentStates()
+}
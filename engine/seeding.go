@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"log"
+	"time"
+
+	"torrentflow/config"
+
+	"github.com/anacrolix/torrent"
+)
+
+// SeedGoalProgress shows how far a torrent is from its seeding goal.
+// Times are in seconds and progress values are percentages capped at 100.
+type SeedGoalProgress struct {
+	Enabled bool `json:"enabled"`
+	// Custom is set when the torrent has its own goals
+	Custom           bool    `json:"custom"`
+	Action           string  `json:"action"`
+	TargetRatio      float64 `json:"targetRatio"`
+	RatioProgress    float64 `json:"ratioProgress"`
+	SeedTime         int64   `json:"seedTime"`
+	MinSeedTime      int64   `json:"minSeedTime"`
+	MaxSeedTime      int64   `json:"maxSeedTime"`
+	SeedTimeProgress float64 `json:"seedTimeProgress"`
+	IdleTime         int64   `json:"idleTime"`
+	IdleTimeout      int64   `json:"idleTimeout"`
+	IdleProgress     float64 `json:"idleProgress"`
+	Met              bool    `json:"met"`
+}
+
+// seedGoals returns the goals that apply to a torrent and whether they
+// are its own
+func (e *Engine) seedGoals(session torrentSession) (config.SeedGoals, bool) {
+	if session.seedGoals != nil {
+		return *session.seedGoals, true
+	}
+	return e.Settings().Seeding, false
+}
+
+// seedGoalProgress measures a torrent against its goals. Seed time runs
+// from completion; idle time is counted while the torrent runs without
+// transfers after that.
+func (e *Engine) seedGoalProgress(session torrentSession, ratio float64, complete bool, now time.Time) SeedGoalProgress {
+	goals, custom := e.seedGoals(session)
+	p := SeedGoalProgress{
+		Enabled:     goals.Enabled(),
+		Custom:      custom,
+		Action:      goals.Action,
+		TargetRatio: goals.Ratio,
+		MinSeedTime: int64(goals.MinSeedMinutes) * 60,
+		MaxSeedTime: int64(goals.MaxSeedMinutes) * 60,
+		IdleTimeout: int64(goals.IdleMinutes) * 60,
+	}
+
+	if complete && !session.completedAt.IsZero() {
+		p.SeedTime = int64(now.Sub(session.completedAt).Seconds())
+		p.IdleTime = int64(session.idleTime.Seconds())
+	}
+
+	ratioMet := goals.Ratio > 0 && ratio >= goals.Ratio
+	maxSeedMet := p.MaxSeedTime > 0 && p.SeedTime >= p.MaxSeedTime
+	idleMet := p.IdleTimeout > 0 && p.IdleTime >= p.IdleTimeout
+
+	if goals.Ratio > 0 {
+		p.RatioProgress = percentOf(ratio, goals.Ratio)
+	}
+	if p.MaxSeedTime > 0 {
+		p.SeedTimeProgress = percentOf(float64(p.SeedTime), float64(p.MaxSeedTime))
+	}
+	if p.IdleTimeout > 0 {
+		p.IdleProgress = percentOf(float64(p.IdleTime), float64(p.IdleTimeout))
+	}
+
+	p.Met = p.Enabled && complete && p.SeedTime >= p.MinSeedTime && (ratioMet || maxSeedMet || idleMet)
+	return p
+}
+
+// SetTorrentSeedGoals gives a torrent its own seeding goals. Passing nil
+// makes it follow the global goals again.
+func (e *Engine) SetTorrentSeedGoals(infoHash string, goals *config.SeedGoals) error {
+	if goals != nil {
+		if err := goals.Validate(); err != nil {
+			return err
+		}
+		copied := *goals
+		goals = &copied
+	}
+
+	e.sessionsMutex.Lock()
+	session, ok := e.sessions[infoHash]
+	if ok {
+		session.seedGoals = goals
+	}
+	e.sessionsMutex.Unlock()
+
+	if !ok {
+		return ErrTorrentNotFound
+	}
+
+	e.saveTorrentStates()
+	e.emit("torrent-updated", infoHash)
+	return nil
+}
+
+// checkSeedGoals runs the goal action for every seeding torrent that has
+// met its goal. It is called from the stats loop.
+func (e *Engine) checkSeedGoals(now time.Time) {
+	e.torrentsMutex.RLock()
+	torrents := make(map[string]*torrent.Torrent, len(e.torrents))
+	for hash, t := range e.torrents {
+		torrents[hash] = t
+	}
+	e.torrentsMutex.RUnlock()
+
+	for hash, t := range torrents {
+		e.pausedMutex.RLock()
+		isPaused := e.pausedTorrents[hash]
+		e.pausedMutex.RUnlock()
+		session := e.getSession(hash)
+		if isPaused || session.busy() || t.Info() == nil {
+			continue
+		}
+
+		uploaded, downloaded := session.lifetimeCounters(t.Stats())
+		ratio := shareRatio(uploaded, downloaded, t.Length())
+		complete := session.downloadDone(t)
+
+		progress := e.seedGoalProgress(session, ratio, complete, now)
+		if !progress.Met {
+			continue
+		}
+
+		action := progress.Action
+		if action == config.SeedActionRemoveData && session.pathMode == pathModeFlat {
+			// The torrent was created from the user's own files, which
+			// only they may delete
+			action = config.SeedActionRemove
+		}
+
+		log.Printf("🎯 Seeding goal reached for %s (ratio %.2f, seeded %s), running %s", t.Name(), ratio,
+			formatDuration(time.Duration(progress.SeedTime)*time.Second), action)
+
+		var err error
+		switch action {
+		case config.SeedActionRemove:
+			err = e.RemoveTorrent(hash, false)
+		case config.SeedActionRemoveData:
+			err = e.RemoveTorrent(hash, true)
+		default:
+			err = e.PauseTorrent(hash)
+		}
+		if err != nil {
+			log.Printf("⚠ Seeding goal action failed for %s: %v", hash, err)
+			continue
+		}
+
+		e.emit("seed-goal-reached", map[string]string{
+			"infoHash": hash,
+			"action":   action,
+		})
+	}
+}
+
+func percentOf(value, target float64) float64 {
+	if target <= 0 {
+		return 0
+	}
+	return min(value/target*100, 100)
+}
@@ -0,0 +1,148 @@
+package engine
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"torrentflow/config"
+)
+
+func TestRecordActivityIdleTime(t *testing.T) {
+	e := newTestEngine(t)
+	seed := addTestTorrent(t, e, "seed", true)
+	leech := addTestTorrent(t, e, "leech", false)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ticks := []struct {
+		minute      int
+		transferred bool
+		running     bool
+		idle        time.Duration // Of the complete torrent
+	}{
+		{0, false, true, 0},
+		{1, false, true, time.Minute},
+		{3, false, true, 3 * time.Minute},
+		// Paused or queued, then running again: the stopped minutes don't count
+		{4, false, false, 3 * time.Minute},
+		{10, false, false, 3 * time.Minute},
+		{11, false, true, 3 * time.Minute},
+		{12, false, true, 4 * time.Minute},
+		// A transfer starts over
+		{13, true, true, 0},
+		{15, false, true, 2 * time.Minute},
+		{16, true, false, 0},
+		{17, false, true, 0},
+	}
+	for _, tick := range ticks {
+		now := start.Add(time.Duration(tick.minute) * time.Minute)
+		for _, hash := range []string{seed, leech} {
+			e.torrentsMutex.RLock()
+			tor := e.torrents[hash]
+			e.torrentsMutex.RUnlock()
+			e.recordActivity(hash, tor, tick.transferred, tick.running, now)
+		}
+
+		session := e.getSession(seed)
+		if session.idleTime != tick.idle {
+			t.Errorf("minute %d: idle time %s, want %s", tick.minute, session.idleTime, tick.idle)
+		}
+		if tick.transferred && !session.lastActivityAt.Equal(now) {
+			t.Errorf("minute %d: last activity %s, want %s", tick.minute, session.lastActivityAt, now)
+		}
+		if session := e.getSession(leech); session.idleTime != 0 {
+			t.Errorf("minute %d: incomplete torrent idle for %s", tick.minute, session.idleTime)
+		}
+	}
+
+	if session := e.getSession(seed); !session.completedAt.Equal(start) {
+		t.Errorf("completed at %s, want the first tick %s", session.completedAt, start)
+	}
+	if session := e.getSession(leech); !session.completedAt.IsZero() {
+		t.Errorf("incomplete torrent completed at %s", session.completedAt)
+	}
+}
+
+func TestCheckSeedGoalsOnce(t *testing.T) {
+	tests := []struct {
+		name  string
+		goals config.SeedGoals
+		// Idle for a minute when set, ratio 2 otherwise
+		idle bool
+		// Minutes from completion the goal is met at
+		metAt int
+	}{
+		{name: "ratio, pause", goals: config.SeedGoals{Ratio: 1, Action: config.SeedActionPause}},
+		{name: "ratio, remove", goals: config.SeedGoals{Ratio: 1, Action: config.SeedActionRemove}},
+		{name: "ratio after the minimum seed time", goals: config.SeedGoals{Ratio: 1, MinSeedMinutes: 10, Action: config.SeedActionPause}, metAt: 10},
+		{name: "idle, pause", goals: config.SeedGoals{IdleMinutes: 1, Action: config.SeedActionPause}, idle: true, metAt: 1},
+		{name: "idle, remove", goals: config.SeedGoals{IdleMinutes: 1, Action: config.SeedActionRemove}, idle: true, metAt: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t)
+			e.settings.Seeding = tt.goals
+			hash := addTestTorrent(t, e, "seed", true)
+			e.torrentsMutex.RLock()
+			tor := e.torrents[hash]
+			e.torrentsMutex.RUnlock()
+
+			var mu sync.Mutex
+			reached := 0
+			e.Subscribe(func(name string, data ...interface{}) {
+				if name == "seed-goal-reached" {
+					mu.Lock()
+					reached++
+					mu.Unlock()
+				}
+			})
+			wantReached := func(what string, want int) {
+				t.Helper()
+				mu.Lock()
+				defer mu.Unlock()
+				if reached != want {
+					t.Errorf("%s: goal reached %d times, want %d", what, reached, want)
+				}
+			}
+
+			start := time.Now()
+			if !tt.idle {
+				e.sessionsMutex.Lock()
+				e.sessions[hash].baseUploaded = 2 * tor.Length()
+				e.sessionsMutex.Unlock()
+			}
+			// Complete from the first tick, transferring until the idle goal
+			// is to be counted
+			e.recordActivity(hash, tor, !tt.idle, true, start)
+
+			if tt.metAt > 0 {
+				before := start.Add(time.Duration(tt.metAt)*time.Minute - time.Second)
+				e.recordActivity(hash, tor, false, true, before)
+				e.checkSeedGoals(before)
+				wantReached("before the goal", 0)
+			}
+			met := start.Add(time.Duration(tt.metAt) * time.Minute)
+			e.recordActivity(hash, tor, false, true, met)
+			e.checkSeedGoals(met)
+			wantReached("at the goal", 1)
+
+			e.checkSeedGoals(met.Add(time.Minute))
+			e.checkSeedGoals(met.Add(time.Hour))
+			wantReached("after the goal", 1)
+
+			e.torrentsMutex.RLock()
+			_, kept := e.torrents[hash]
+			e.torrentsMutex.RUnlock()
+			switch tt.goals.Action {
+			case config.SeedActionPause:
+				if !kept || !e.isPaused(hash) {
+					t.Error("torrent was not paused")
+				}
+			case config.SeedActionRemove:
+				if kept {
+					t.Error("torrent was not removed")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"torrentflow/config"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+)
+
+// File path maker modes. They decide how a torrent's files are laid out
+// below its storage root and must be restored exactly, otherwise the
+// client will not find data that is already on disk.
+const (
+	// pathModeDefault stores files as <root>/<torrent name>/<file path>,
+	// which is what the client's default storage does.
+	pathModeDefault = "default"
+	// pathModeFlat stores files as <root>/<file path>. Torrents made by
+	// CreateTorrentFromFiles use it so they seed from the original files.
+	pathModeFlat = "flat"
+)
+
+// torrentSession holds per-torrent details that the torrent client does
+// not keep for us but that are needed to restore a torrent after restart
+type torrentSession struct {
+	storageRoot    string
+	pathMode       string
+	baseUploaded   int64 // Bytes uploaded in previous runs
+	baseDownloaded int64 // Bytes downloaded in previous runs
+	addedAt        time.Time
+	completedAt    time.Time         // Zero until the torrent first completes
+	lastActivityAt time.Time         // Zero until data is first transferred
+	idleTime       time.Duration     // Seeded without transfers since the last one, while running
+	idleCountedAt  time.Time         // Last tick idle time was counted up to; not saved
+	downloadLimit  int64             // Bytes per second, 0 for no limit
+	uploadLimit    int64             // Bytes per second, 0 for no limit
+	scheduledPause bool              // Paused by the schedule, resumed when it ends
+	networkPause   bool              // Paused by the kill-switch, resumed when the network returns
+	seedGoals      *config.SeedGoals // Nil to follow the global goals
+	filePriorities []string          // Per file, nil to download everything
+	category       string
+	tags           []string
+	// Trackers saved for the torrent. Paused torrents are restored without
+	// them, so they are kept here until the torrent is resumed.
+	trackers [][]string
+	// Peers added by hand, given to the client again whenever the torrent
+	// is restored
+	peers    []string
+	moving   bool           // Storage is being moved; not saved
+	checking bool           // Data is being rechecked; not saved
+	recheck  *RecheckReport // Result of the last recheck; not saved
+}
+
+// busy reports whether the torrent's storage is being moved or checked,
+// which leaves it alone until that is done
+func (s *torrentSession) busy() bool {
+	return s.moving || s.checking
+}
+
+// lifetimeCounters returns total bytes uploaded and downloaded across all
+// runs, given the counters of the current run
+func (s *torrentSession) lifetimeCounters(stats torrent.TorrentStats) (uploaded, downloaded int64) {
+	return s.baseUploaded + stats.BytesWrittenData.Int64(), s.baseDownloaded + stats.BytesReadData.Int64()
+}
+
+// shareRatio returns uploaded divided by downloaded. Torrents that were
+// never downloaded (e.g. created locally) use their size instead.
+func shareRatio(uploaded, downloaded, size int64) float64 {
+	base := downloaded
+	if base == 0 {
+		base = size
+	}
+	if base == 0 {
+		return 0
+	}
+	return float64(uploaded) / float64(base)
+}
+
+// usesCustomStorage reports whether the torrent needs its own storage
+// instead of the client's default storage in downloadDir
+func (s *torrentSession) usesCustomStorage(downloadDir string) bool {
+	if s.pathMode == pathModeFlat {
+		return true
+	}
+	return s.storageRoot != "" && filepath.Clean(s.storageRoot) != filepath.Clean(downloadDir)
+}
+
+// root returns the directory the torrent's data is stored below
+func (s *torrentSession) root(downloadDir string) string {
+	if s.storageRoot == "" {
+		return downloadDir
+	}
+	return s.storageRoot
+}
+
+// filePaths returns where each of a torrent's files is stored on disk,
+// following the path mode the torrent was added with
+func (s *torrentSession) filePaths(downloadDir string, t *torrent.Torrent) []string {
+	root := s.root(downloadDir)
+	var paths []string
+	for _, file := range t.Files() {
+		if s.pathMode == pathModeFlat {
+			paths = append(paths, filepath.Join(root, filepath.Join(file.FileInfo().Path...)))
+		} else {
+			paths = append(paths, filepath.Join(root, filepath.FromSlash(file.Path())))
+		}
+	}
+	return paths
+}
+
+// newTorrentStorage creates file storage rooted at root using the given
+// path mode. The piece completion database lives next to the data so a
+// restored torrent does not have to rehash everything.
+func newTorrentStorage(root, pathMode string) (storage.ClientImplCloser, error) {
+	pcDir := root
+	if stat, err := os.Stat(root); err == nil && !stat.IsDir() {
+		// Single file torrents use the file itself as the root
+		pcDir = filepath.Dir(root)
+	}
+
+	pc, err := storage.NewDefaultPieceCompletionForDir(pcDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create piece completion: %w", err)
+	}
+
+	opts := storage.NewFileClientOpts{
+		ClientBaseDir:   root,
+		PieceCompletion: pc,
+	}
+	if pathMode == pathModeFlat {
+		opts.FilePathMaker = func(opts storage.FilePathMakerOpts) string {
+			// Return the path structure as-is from the metainfo
+			return filepath.Join(opts.File.Path...)
+		}
+	}
+
+	return storage.NewFileOpts(opts), nil
+}
+
+// buildTorrentState captures everything needed to bring a torrent back
+// without contacting peers
+func (e *Engine) buildTorrentState(hash string, t *torrent.Torrent) TorrentState {
+	mi := t.Metainfo()
+
+	sess := e.getSession(hash)
+	if sess.storageRoot == "" {
+		sess.storageRoot = e.downloadDir
+	}
+	if sess.pathMode == "" {
+		sess.pathMode = pathModeDefault
+	}
+
+	trackers := mi.AnnounceList
+	if len(trackers) == 0 {
+		trackers = sess.trackers
+	}
+
+	magnet := metainfo.Magnet{
+		InfoHash:    t.InfoHash(),
+		DisplayName: t.Name(),
+	}
+	for _, tier := range trackers {
+		magnet.Trackers = append(magnet.Trackers, tier...)
+	}
+
+	uploaded, downloaded := sess.lifetimeCounters(t.Stats())
+
+	e.pausedMutex.RLock()
+	isPaused := e.pausedTorrents[hash]
+	e.pausedMutex.RUnlock()
+
+	return TorrentState{
+		InfoHash:        hash,
+		MagnetURI:       magnet.String(),
+		InfoBytes:       mi.InfoBytes,
+		Trackers:        trackers,
+		StorageRoot:     sess.storageRoot,
+		PathMode:        sess.pathMode,
+		FilePriorities:  sess.filePriorities,
+		Category:        sess.category,
+		Tags:            sess.tags,
+		BytesUploaded:   uploaded,
+		BytesDownloaded: downloaded,
+		IsPaused:        isPaused,
+		AddedAt:         sess.addedAt,
+		CompletedAt:     sess.completedAt,
+		LastActivityAt:  sess.lastActivityAt,
+		IdleSeconds:     int64(sess.idleTime.Seconds()),
+		DownloadLimit:   sess.downloadLimit,
+		UploadLimit:     sess.uploadLimit,
+		ScheduledPause:  sess.scheduledPause,
+		NetworkPause:    sess.networkPause,
+		QueuePosition:   e.queuePosition(hash),
+		SeedGoals:       sess.seedGoals,
+		Peers:           sess.peers,
+	}
+}
+
+// restoreTorrent adds a saved torrent back to the client. Torrents with
+// saved info bytes are restored directly; the magnet URI is only used
+// for torrents that never got their metadata. Paused torrents are added
+// without trackers and dropped again right away, so they never announce
+// or connect to peers.
+func (e *Engine) restoreTorrent(state TorrentState) (*torrent.Torrent, error) {
+	var spec *torrent.TorrentSpec
+	if len(state.InfoBytes) == 0 {
+		if state.MagnetURI == "" {
+			return nil, fmt.Errorf("no metainfo or magnet saved")
+		}
+		var err error
+		spec, err = torrent.TorrentSpecFromMagnetUri(state.MagnetURI)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		spec = &torrent.TorrentSpec{
+			InfoHash:  metainfo.HashBytes(state.InfoBytes),
+			InfoBytes: state.InfoBytes,
+			Trackers:  state.Trackers,
+		}
+	}
+
+	session := state.session()
+	if session.usesCustomStorage(e.downloadDir) {
+		st, err := newTorrentStorage(session.storageRoot, session.pathMode)
+		if err != nil {
+			return nil, err
+		}
+		spec.Storage = st
+	}
+
+	spec.PeerAddrs = append(spec.PeerAddrs, state.Peers...)
+
+	if state.IsPaused {
+		spec.Trackers = nil
+		spec.PeerAddrs = nil
+		spec.DisallowDataDownload = true
+		spec.DisallowDataUpload = true
+		spec.DisableInitialPieceCheck = true
+	}
+
+	client := e.torrentClient()
+	if client == nil {
+		return nil, fmt.Errorf("torrent client not initialized")
+	}
+	t, _, err := client.AddTorrentSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if state.IsPaused {
+		e.detachTorrent(t)
+	}
+	return t, nil
+}
+
+// session returns the in-memory session details for a saved state
+func (s TorrentState) session() *torrentSession {
+	pathMode := s.PathMode
+	if pathMode == "" {
+		pathMode = pathModeDefault
+	}
+	addedAt := s.AddedAt
+	if addedAt.IsZero() {
+		addedAt = time.Now()
+	}
+	return &torrentSession{
+		storageRoot:    s.StorageRoot,
+		pathMode:       pathMode,
+		baseUploaded:   s.BytesUploaded,
+		baseDownloaded: s.BytesDownloaded,
+		addedAt:        addedAt,
+		completedAt:    s.CompletedAt,
+		lastActivityAt: s.LastActivityAt,
+		idleTime:       time.Duration(s.IdleSeconds) * time.Second,
+		downloadLimit:  s.DownloadLimit,
+		uploadLimit:    s.UploadLimit,
+		scheduledPause: s.ScheduledPause,
+		networkPause:   s.NetworkPause,
+		seedGoals:      s.SeedGoals,
+		filePriorities: s.FilePriorities,
+		category:       s.Category,
+		tags:           s.Tags,
+		trackers:       s.Trackers,
+		peers:          s.Peers,
+	}
+}
+
+// getSession returns a copy of the session details for a torrent
+func (e *Engine) getSession(hash string) torrentSession {
+	e.sessionsMutex.RLock()
+	defer e.sessionsMutex.RUnlock()
+
+	if session, ok := e.sessions[hash]; ok {
+		return *session
+	}
+	return torrentSession{}
+}
+
+// isBusy reports whether a torrent's storage is being moved or checked
+func (e *Engine) isBusy(hash string) bool {
+	session := e.getSession(hash)
+	return session.busy()
+}
+
+// recordActivity updates completion and activity timestamps, and counts
+// idle time while a complete torrent runs without transfers. Paused and
+// queued torrents can't transfer, so their idle time stands still. It is
+// called once per tick of the stats loop.
+func (e *Engine) recordActivity(hash string, t *torrent.Torrent, transferred, running bool, now time.Time) {
+	e.sessionsMutex.Lock()
+	defer e.sessionsMutex.Unlock()
+
+	session, ok := e.sessions[hash]
+	if !ok {
+		return
+	}
+	done := session.downloadDone(t)
+	if done && session.completedAt.IsZero() {
+		session.completedAt = now
+	}
+
+	switch {
+	case transferred:
+		session.lastActivityAt = now
+		session.idleTime = 0
+	case running && done && !session.idleCountedAt.IsZero():
+		session.idleTime += now.Sub(session.idleCountedAt)
+	}
+	session.idleCountedAt = time.Time{}
+	if running && done {
+		session.idleCountedAt = now
+	}
+}
@@ -0,0 +1,158 @@
+package engine
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"syscall"
+
+	"torrentflow/config"
+
+	"github.com/anacrolix/dht/v2"
+	alog "github.com/anacrolix/log"
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/dialer"
+)
+
+// The client is built without sockets of its own and the engine opens them
+// instead, so that every peer connection, incoming or dialled, passes the
+// blocklist here and a refusal is counted against the list that blocks the
+// address. The client would refuse the same peers without telling anyone.
+
+// peerSockets opens the TCP and UDP sockets peers are reached over and
+// hands them to the client: on the bound addresses when b is set, on every
+// address otherwise. The UDP sockets carry uTP and the DHT. The first
+// socket takes port, or a random port when it is 0, and the others share
+// it. The returned sockets are to be closed with the client.
+func (e *Engine) peerSockets(client *torrent.Client, settings config.Settings, b *binding, port int) ([]io.Closer, error) {
+	addrs := []netip.Addr{netip.IPv4Unspecified()}
+	if settings.EnableIPv6 {
+		addrs = append(addrs, netip.IPv6Unspecified())
+	}
+	if b != nil {
+		addrs = b.addrs()
+	}
+
+	var tcp, udp []net.Listener
+	var sockets []io.Closer
+	for _, addr := range addrs {
+		family := "4"
+		if addr.Is6() {
+			family = "6"
+		}
+		local := netip.AddrPortFrom(addr, uint16(port)).String()
+
+		var l net.Listener
+		if settings.EnableTCP {
+			var err error
+			l, err = net.Listen("tcp"+family, local)
+			if unsupportedFamily(b, addr, err) {
+				continue
+			}
+			if err != nil {
+				closeSockets(sockets)
+				return nil, err
+			}
+			local = l.Addr().String()
+		}
+
+		var s net.Listener
+		if settings.EnableUTP || settings.EnableDHT {
+			var err error
+			s, err = torrent.NewUtpSocket("udp"+family, local, nil, alog.Default)
+			if err != nil {
+				if l != nil {
+					l.Close()
+				}
+				if unsupportedFamily(b, addr, err) {
+					continue
+				}
+				closeSockets(sockets)
+				return nil, err
+			}
+			local = s.Addr().String()
+		}
+
+		// Later addresses share the port picked for the first
+		if l != nil {
+			tcp = append(tcp, l)
+			sockets = append(sockets, l)
+		}
+		if s != nil {
+			udp = append(udp, s)
+			sockets = append(sockets, s)
+		}
+		if addrPort, err := netip.ParseAddrPort(local); err == nil {
+			port = int(addrPort.Port())
+		}
+	}
+
+	var dhts []*dht.Server
+	if settings.EnableDHT {
+		for _, s := range udp {
+			ds, err := client.NewAnacrolixDhtServer(s.(net.PacketConn))
+			if err != nil {
+				for _, ds := range dhts {
+					ds.Close()
+				}
+				closeSockets(sockets)
+				return nil, err
+			}
+			dhts = append(dhts, ds)
+		}
+	}
+
+	for _, l := range tcp {
+		network := "tcp4"
+		if l.Addr().(*net.TCPAddr).IP.To4() == nil {
+			network = "tcp6"
+		}
+		d := &net.Dialer{}
+		if b != nil {
+			d.LocalAddr = &net.TCPAddr{IP: l.Addr().(*net.TCPAddr).IP}
+		}
+		client.AddListener(refusingListener{Listener: l, blocklist: e.blocklist})
+		client.AddDialer(refusingDialer{Dialer: torrent.NetworkDialer{Network: network, Dialer: d}, blocklist: e.blocklist})
+	}
+	if settings.EnableUTP {
+		for _, s := range udp {
+			network := "udp4"
+			if s.Addr().(*net.UDPAddr).IP.To4() == nil {
+				network = "udp6"
+			}
+			client.AddListener(refusingListener{Listener: s, blocklist: e.blocklist})
+			client.AddDialer(refusingDialer{Dialer: torrent.NetworkDialer{Network: network, Dialer: s.(dialer.WithContext)}, blocklist: e.blocklist})
+		}
+	}
+	// The DHT servers are closed before the sockets they run on
+	closers := make([]io.Closer, 0, len(dhts)+len(sockets))
+	for _, ds := range dhts {
+		client.AddDhtServer(torrent.AnacrolixDhtServerWrapper{Server: ds})
+		closers = append(closers, closerFunc(ds.Close))
+	}
+	return append(closers, sockets...), nil
+}
+
+// unsupportedFamily reports whether err means the machine has no address
+// in addr's family to listen on. The unbound client goes without IPv6 on
+// such machines, as the torrent library does.
+func unsupportedFamily(b *binding, addr netip.Addr, err error) bool {
+	return err != nil && b == nil && addr.Is6() && (errors.Is(err, syscall.EADDRNOTAVAIL) || errors.Is(err, syscall.EAFNOSUPPORT))
+}
+
+// closerFunc closes something whose Close returns nothing
+type closerFunc func()
+
+// Close implements io.Closer
+func (f closerFunc) Close() error {
+	f()
+	return nil
+}
+
+// closeSockets closes sockets opened for a client
+func closeSockets(sockets []io.Closer) {
+	for _, s := range sockets {
+		s.Close()
+	}
+}
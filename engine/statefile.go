@@ -0,0 +1,298 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// stateSchemaVersion is the version written to the state file. Bump it
+// and append a migration to stateMigrations when the format changes.
+const stateSchemaVersion = 2
+
+// stateBackupCount is how many previous state files are kept around
+const stateBackupCount = 3
+
+// stateDocument is the on-disk layout of the state file
+type stateDocument struct {
+	Version  int            `json:"version"`
+	SavedAt  time.Time      `json:"savedAt"`
+	Torrents []TorrentState `json:"torrents"`
+}
+
+// stateMigrations upgrade a raw state document by one version each.
+// Entry i takes a version i document and returns a version i+1 document.
+var stateMigrations = []func(data []byte) ([]byte, error){
+	migrateStateV0,
+	migrateStateV1,
+}
+
+// migrateStateV0 wraps the original bare []TorrentState array in a
+// versioned document
+func migrateStateV0(data []byte) ([]byte, error) {
+	var torrents []json.RawMessage
+	if err := json.Unmarshal(data, &torrents); err != nil {
+		return nil, fmt.Errorf("invalid legacy state: %w", err)
+	}
+	if torrents == nil {
+		torrents = []json.RawMessage{}
+	}
+	return json.Marshal(map[string]interface{}{
+		"version":  1,
+		"torrents": torrents,
+	})
+}
+
+// migrateStateV1 drops file priorities saved as raw piece priorities.
+// They were never set by the user, and as priority names an all-zero
+// list would read as every file skipped.
+func migrateStateV1(data []byte) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var torrents []map[string]json.RawMessage
+	if err := json.Unmarshal(doc["torrents"], &torrents); err != nil {
+		return nil, err
+	}
+	for _, torrent := range torrents {
+		delete(torrent, "filePriorities")
+	}
+	if torrents == nil {
+		torrents = []map[string]json.RawMessage{}
+	}
+
+	encoded, err := json.Marshal(torrents)
+	if err != nil {
+		return nil, err
+	}
+	doc["torrents"] = encoded
+	doc["version"] = json.RawMessage("2")
+	return json.Marshal(doc)
+}
+
+// stateStore reads and writes the torrent state file. Writes go to a
+// temporary file that is synced and renamed over the old one, so a crash
+// never leaves a half written state file behind.
+type stateStore struct {
+	path    string
+	backups int
+	mu      sync.Mutex
+}
+
+// newStateStore creates a state store for the given file
+func newStateStore(path string) *stateStore {
+	return &stateStore{
+		path:    path,
+		backups: stateBackupCount,
+	}
+}
+
+// Save atomically replaces the state file, rotating the previous one
+// into the backup set
+func (s *stateStore) Save(states []TorrentState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if states == nil {
+		states = []TorrentState{}
+	}
+	data, err := json.MarshalIndent(stateDocument{
+		Version:  stateSchemaVersion,
+		SavedAt:  time.Now(),
+		Torrents: states,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create state directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp state file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close state: %w", err)
+	}
+
+	if err := s.rotateBackups(); err != nil {
+		log.Printf("⚠ Could not rotate state backups: %v", err)
+	}
+
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return fmt.Errorf("failed to replace state file: %w", err)
+	}
+	syncDir(dir)
+
+	return nil
+}
+
+// Load reads the state file, migrating older formats. If the main file
+// is missing or corrupt the newest readable backup is used instead.
+func (s *stateStore) Load() ([]TorrentState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states, err := readStateFile(s.path)
+	if err == nil || os.IsNotExist(err) && !s.hasBackups() {
+		return states, err
+	}
+	log.Printf("⚠ Could not read state file %s: %v", s.path, err)
+
+	for i := 1; i <= s.backups; i++ {
+		backup := s.backupPath(i)
+		states, backupErr := readStateFile(backup)
+		if backupErr == nil {
+			log.Printf("✓ Recovered state from backup %s", backup)
+			return states, nil
+		}
+		if !os.IsNotExist(backupErr) {
+			log.Printf("⚠ Could not read state backup %s: %v", backup, backupErr)
+		}
+	}
+
+	return nil, err
+}
+
+func (s *stateStore) backupPath(n int) string {
+	return fmt.Sprintf("%s.%d", s.path, n)
+}
+
+func (s *stateStore) hasBackups() bool {
+	for i := 1; i <= s.backups; i++ {
+		if _, err := os.Stat(s.backupPath(i)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// rotateBackups shifts state.N-1 to state.N and copies the current state
+// file to state.1. The current file is copied rather than renamed so a
+// valid state file exists at every point in time.
+func (s *stateStore) rotateBackups() error {
+	if s.backups <= 0 {
+		return nil
+	}
+	if _, err := os.Stat(s.path); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for i := s.backups - 1; i >= 1; i-- {
+		if err := os.Rename(s.backupPath(i), s.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return copyFile(s.path, s.backupPath(1))
+}
+
+// readStateFile reads and migrates a single state file
+func readStateFile(path string) ([]TorrentState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := decodeState(data)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Torrents, nil
+}
+
+// decodeState upgrades raw state data to the current schema and decodes it
+func decodeState(data []byte) (*stateDocument, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("state file is empty")
+	}
+
+	version := 0
+	if data[0] != '[' {
+		var header struct {
+			Version int `json:"version"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return nil, fmt.Errorf("invalid state file: %w", err)
+		}
+		version = header.Version
+	}
+
+	if version > stateSchemaVersion {
+		return nil, fmt.Errorf("state file version %d is newer than supported version %d", version, stateSchemaVersion)
+	}
+
+	for ; version < stateSchemaVersion; version++ {
+		migrated, err := stateMigrations[version](data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate state from version %d: %w", version, err)
+		}
+		data = migrated
+		log.Printf("✓ Migrated state file from version %d to %d", version, version+1)
+	}
+
+	var doc stateDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid state file: %w", err)
+	}
+	return &doc, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// syncDir flushes a directory entry so a rename survives a power loss.
+// Not every platform supports this, so errors are ignored.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestStateStoreLoad(t *testing.T) {
+	current := fmt.Sprintf(`{"version": %d, "torrents": [{"infoHash": "aa", "filePriorities": ["skip", "normal"]}]}`, stateSchemaVersion)
+
+	tests := []struct {
+		name  string
+		files map[string]string // By suffix to the state file's path
+		want  []TorrentState
+		err   string
+	}{
+		{
+			name:  "current",
+			files: map[string]string{"": current},
+			want:  []TorrentState{{InfoHash: "aa", FilePriorities: []string{"skip", "normal"}}},
+		},
+		{
+			name:  "legacy bare array",
+			files: map[string]string{"": `[{"infoHash": "aa", "filePriorities": ["0", "0"]}, {"infoHash": "bb"}]`},
+			want:  []TorrentState{{InfoHash: "aa"}, {InfoHash: "bb"}},
+		},
+		{
+			name:  "legacy empty array",
+			files: map[string]string{"": `[]`},
+			want:  []TorrentState{},
+		},
+		{
+			name:  "v1 piece priorities dropped",
+			files: map[string]string{"": `{"version": 1, "savedAt": "2024-01-02T03:04:05Z", "torrents": [{"infoHash": "aa", "filePriorities": ["0", "1"], "bytesUploaded": 7}]}`},
+			want:  []TorrentState{{InfoHash: "aa", BytesUploaded: 7}},
+		},
+		{
+			name: "corrupt main file, good backup",
+			files: map[string]string{
+				"":   `{"version": 2, "torr`,
+				".1": current,
+			},
+			want: []TorrentState{{InfoHash: "aa", FilePriorities: []string{"skip", "normal"}}},
+		},
+		{
+			name: "missing main file, newest good backup",
+			files: map[string]string{
+				".1": "",
+				".2": `[{"infoHash": "bb"}]`,
+				".3": current,
+			},
+			want: []TorrentState{{InfoHash: "bb"}},
+		},
+		{
+			name: "truncated temp file left behind",
+			files: map[string]string{
+				"":          current,
+				".tmp-1234": `{"version": 2, "torrents": [{"info`,
+			},
+			want: []TorrentState{{InfoHash: "aa", FilePriorities: []string{"skip", "normal"}}},
+		},
+		{
+			name:  "newer version",
+			files: map[string]string{"": fmt.Sprintf(`{"version": %d, "torrents": []}`, stateSchemaVersion+1)},
+			err:   "newer than supported",
+		},
+		{
+			name: "newer version, no older backup",
+			files: map[string]string{
+				"":   fmt.Sprintf(`{"version": %d, "torrents": []}`, stateSchemaVersion+1),
+				".1": fmt.Sprintf(`{"version": %d, "torrents": []}`, stateSchemaVersion+1),
+			},
+			err: "newer than supported",
+		},
+		{
+			name:  "empty",
+			files: map[string]string{"": " \n"},
+			err:   "empty",
+		},
+		{
+			name:  "no state yet",
+			files: map[string]string{},
+			err:   "no such file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "torrents.json")
+			for suffix, data := range tt.files {
+				if err := os.WriteFile(path+suffix, []byte(data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := newStateStore(path).Load()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Load() error = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.EqualFunc(got, tt.want, stateEqual) {
+				t.Errorf("Load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStateStoreSaveRotatesBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "torrents.json")
+	store := newStateStore(path)
+
+	// A temp file from a save that crashed doesn't get in the way
+	if err := os.WriteFile(path+".tmp-1234", []byte(`{"vers`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range stateBackupCount + 2 {
+		if err := store.Save([]TorrentState{{InfoHash: fmt.Sprint(i)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The newest save is the state file, the ones before it the backups
+	last := stateBackupCount + 1
+	for n := 0; n <= stateBackupCount; n++ {
+		file := path
+		if n > 0 {
+			file = store.backupPath(n)
+		}
+		states, err := readStateFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprint(last - n); len(states) != 1 || states[0].InfoHash != want {
+			t.Errorf("%s holds %+v, want torrent %s", filepath.Base(file), states, want)
+		}
+	}
+	if _, err := os.Stat(store.backupPath(stateBackupCount + 1)); !os.IsNotExist(err) {
+		t.Errorf("more than %d backups kept", stateBackupCount)
+	}
+
+	// Nothing but the state file, its backups and the old temp file
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != stateBackupCount+2 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("state directory holds %v", names)
+	}
+}
+
+// stateEqual compares the fields the state tests set
+func stateEqual(a, b TorrentState) bool {
+	return a.InfoHash == b.InfoHash &&
+		slices.Equal(a.FilePriorities, b.FilePriorities) &&
+		a.BytesUploaded == b.BytesUploaded
+}
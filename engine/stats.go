@@ -0,0 +1,294 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/anacrolix/torrent"
+)
+
+// speedTracker tracks download/upload speeds
+type speedTracker struct {
+	lastBytes int64
+	lastTime  time.Time
+	speed     int64
+}
+
+func (e *Engine) getTorrentInfo(hash string, t *torrent.Torrent) TorrentInfo {
+	stats := t.Stats()
+
+	// Check if paused
+	e.pausedMutex.RLock()
+	isPaused := e.pausedTorrents[hash]
+	e.pausedMutex.RUnlock()
+
+	// Lifetime metadata kept across restarts
+	session := e.getSession(hash)
+
+	// Determine status
+	status := e.getTorrentStatus(t, stats, session, isPaused, e.isQueued(hash))
+
+	// Calculate progress over the files that are not skipped
+	completed, wanted := session.wantedBytes(t)
+	progress := 0.0
+	if wanted > 0 {
+		progress = float64(completed) / float64(wanted) * 100
+	}
+
+	// Get files info
+	var files []FileInfo
+	if t.Info() != nil {
+		for i, file := range t.Files() {
+			fileProgress := 0.0
+			if file.Length() > 0 {
+				fileProgress = float64(file.BytesCompleted()) / float64(file.Length()) * 100
+			}
+
+			files = append(files, FileInfo{
+				Name:     file.DisplayPath(),
+				Size:     file.Length(),
+				SizeStr:  formatBytes(file.Length()),
+				Progress: fileProgress,
+				Path:     file.Path(),
+				Index:    i,
+				Priority: session.filePriority(i),
+			})
+		}
+	}
+
+	// Get speed from tracker
+	var downloadSpeed, uploadSpeed int64
+	e.speedsMutex.RLock()
+	if tracker, ok := e.downloadSpeeds[hash]; ok {
+		downloadSpeed = tracker.speed
+	}
+	if tracker, ok := e.uploadSpeeds[hash]; ok {
+		uploadSpeed = tracker.speed
+	}
+	e.speedsMutex.RUnlock()
+
+	// Calculate ETA
+	eta := "Unknown"
+	if downloadSpeed > 0 && completed < wanted {
+		remaining := wanted - completed
+		seconds := remaining / downloadSpeed
+		eta = formatDuration(time.Duration(seconds) * time.Second)
+	}
+
+	// Get torrent name
+	name := t.Name()
+	if name == "" {
+		name = "Loading metadata..."
+	}
+
+	encryptedShare := 0.0
+	if conns := t.PeerConns(); len(conns) > 0 {
+		encrypted := encryptedPeers(t)
+		count := 0
+		for _, pc := range conns {
+			if encrypted[pc.RemoteAddr.String()] {
+				count++
+			}
+		}
+		encryptedShare = float64(count) / float64(len(conns)) * 100
+	}
+
+	totalUploaded, totalDownloaded := session.lifetimeCounters(stats)
+	ratio := shareRatio(totalUploaded, totalDownloaded, t.Length())
+	complete := session.downloadDone(t)
+
+	return TorrentInfo{
+		ID:              hash,
+		Name:            name,
+		InfoHash:        hash,
+		Size:            t.Length(),
+		SizeStr:         formatBytes(t.Length()),
+		Progress:        progress,
+		Status:          status,
+		DownloadSpeed:   downloadSpeed,
+		UploadSpeed:     uploadSpeed,
+		DownloadedStr:   formatSpeed(downloadSpeed),
+		UploadedStr:     formatSpeed(uploadSpeed),
+		Peers:           stats.ActivePeers,
+		Seeds:           stats.ConnectedSeeders,
+		EncryptedPeers:  encryptedShare,
+		ETA:             eta,
+		Files:           files,
+		AddedAt:         session.addedAt,
+		IsPaused:        isPaused,
+		CompletedAt:     session.completedAt,
+		LastActivityAt:  session.lastActivityAt,
+		TotalUploaded:   totalUploaded,
+		TotalDownloaded: totalDownloaded,
+		Ratio:           ratio,
+		DownloadLimit:   session.downloadLimit,
+		UploadLimit:     session.uploadLimit,
+		QueuePosition:   e.queuePosition(hash),
+		SeedGoal:        e.seedGoalProgress(session, ratio, complete, time.Now()),
+		Category:        session.category,
+		Tags:            session.tags,
+		SavePath:        session.root(e.downloadDir),
+	}
+}
+
+func (e *Engine) getTorrentStatus(t *torrent.Torrent, stats torrent.TorrentStats, session torrentSession, isPaused, isQueued bool) string {
+	// Files are being moved to a new location
+	if session.moving {
+		return "moving"
+	}
+
+	// Pieces are being verified against the data on disk
+	if session.checking {
+		return "checking"
+	}
+
+	if isPaused {
+		return "paused"
+	}
+
+	// Waiting for a download or seed slot
+	if isQueued {
+		return "queued"
+	}
+
+	// Metadata not fetched yet
+	if t.Info() == nil || t.Length() == 0 {
+		return "loading"
+	}
+
+	// Check if the chosen files are complete
+	if session.downloadDone(t) {
+		// If actively uploading to peers
+		if stats.ActivePeers > 0 {
+			return "seeding"
+		}
+
+		// Completed but no one downloading from us
+		return "completed"
+	}
+
+	// Still downloading
+	if stats.ActivePeers > 0 {
+		return "downloading"
+	}
+
+	// Has potential peers but not connected/downloading
+	if stats.TotalPeers > 0 {
+		return "stalled"
+	}
+
+	// No peers at all - looking for peers
+	return "stalled"
+}
+
+func (e *Engine) updateStatsLoop() {
+	ticker := time.NewTicker(1 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		}
+
+		// Hand out slots freed by completed, paused or removed torrents
+		e.updateQueue()
+
+		// Update speed trackers
+		e.torrentsMutex.RLock()
+		for hash, t := range e.torrents {
+			stats := t.Stats()
+			now := time.Now()
+			transferred := false
+
+			e.speedsMutex.Lock()
+			if tracker, ok := e.downloadSpeeds[hash]; ok {
+				elapsed := now.Sub(tracker.lastTime).Seconds()
+				if elapsed > 0 {
+					currentBytes := stats.BytesReadData.Int64()
+					bytesDiff := currentBytes - tracker.lastBytes
+					transferred = transferred || bytesDiff > 0
+					tracker.speed = int64(float64(bytesDiff) / elapsed)
+					tracker.lastBytes = currentBytes
+					tracker.lastTime = now
+				}
+			}
+
+			if tracker, ok := e.uploadSpeeds[hash]; ok {
+				elapsed := now.Sub(tracker.lastTime).Seconds()
+				if elapsed > 0 {
+					currentBytes := stats.BytesWrittenData.Int64()
+					bytesDiff := currentBytes - tracker.lastBytes
+					transferred = transferred || bytesDiff > 0
+					tracker.speed = int64(float64(bytesDiff) / elapsed)
+					tracker.lastBytes = currentBytes
+					tracker.lastTime = now
+				}
+			}
+			e.speedsMutex.Unlock()
+
+			e.recordActivity(hash, t, transferred, !e.isStopped(hash), now)
+		}
+		e.torrentsMutex.RUnlock()
+
+		e.updatePeerSpeeds(time.Now())
+		e.checkSeedGoals(time.Now())
+
+		// Get current state
+		torrents := e.GetTorrents()
+		stats := e.GetStats()
+
+		data := map[string]interface{}{
+			"torrents": torrents,
+			"stats":    stats,
+		}
+
+		jsonData, _ := json.Marshal(data)
+		dataStr := string(jsonData)
+
+		// Check if data has meaningfully changed
+		e.updateMutex.Lock()
+		currentHash := fmt.Sprintf("%x", dataStr) // Simple hash
+		timeSinceLastUpdate := time.Since(e.lastUpdateTime)
+
+		// Only emit if data changed OR if it's been more than 5 seconds (for speed updates)
+		if currentHash != e.lastUpdateHash || timeSinceLastUpdate > 5*time.Second {
+			e.lastUpdateHash = currentHash
+			e.lastUpdateTime = time.Now()
+			e.updateMutex.Unlock()
+
+			e.emit("torrents-update", dataStr)
+		} else {
+			e.updateMutex.Unlock()
+		}
+	}
+}
+
+func formatBytes(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}
+
+func formatSpeed(bytesPerSec int64) string {
+	return formatBytes(bytesPerSec) + "/s"
+}
+
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	if d < time.Hour {
+		return fmt.Sprintf("%dm", int(d.Minutes()))
+	}
+	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+}
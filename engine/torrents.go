@@ -0,0 +1,584 @@
+package engine
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"torrentflow/config"
+
+	"github.com/anacrolix/torrent"
+	"github.com/anacrolix/torrent/bencode"
+	"github.com/anacrolix/torrent/metainfo"
+	"github.com/anacrolix/torrent/storage"
+)
+
+// AddMagnet adds a torrent from a magnet link and returns its info hash.
+// Downloading starts once metadata has been fetched from peers. Files
+// cannot be chosen before that; use PreviewMagnet to pick them.
+func (e *Engine) AddMagnet(magnetURI string, opts AddOptions) (string, error) {
+	if e.torrentClient() == nil {
+		return "", fmt.Errorf("torrent client not initialized")
+	}
+	if opts.Files != nil {
+		return "", fmt.Errorf("files can only be chosen once metadata is available")
+	}
+	if err := opts.validate(0); err != nil {
+		return "", err
+	}
+
+	log.Printf("Adding magnet link...")
+	spec, err := torrent.TorrentSpecFromMagnetUri(magnetURI)
+	if err != nil {
+		log.Printf("❌ Failed to add magnet: %v", err)
+		return "", fmt.Errorf("failed to add magnet: %w", err)
+	}
+	hash := spec.InfoHash.String()
+	if err := e.checkNotAdded(hash); err != nil {
+		return "", err
+	}
+	e.dropPreview(hash)
+
+	storageRoot, st, err := e.storageFor(opts.SavePath)
+	if err != nil {
+		return "", err
+	}
+	spec.Storage = st
+
+	t, _, err := e.torrentClient().AddTorrentSpec(spec)
+	if err != nil {
+		log.Printf("❌ Failed to add magnet: %v", err)
+		return "", fmt.Errorf("failed to add magnet: %w", err)
+	}
+
+	log.Printf("✓ Magnet added with hash: %s", hash)
+
+	e.registerTorrent(hash, t, storageRoot, opts)
+
+	log.Printf("Waiting for metadata...")
+
+	// Emit immediately so UI shows the torrent in "loading" state
+	e.emit("torrent-added", hash)
+
+	// Wait for metadata
+	go func() {
+		select {
+		case <-t.Closed():
+			// Paused or removed first; resuming waits for metadata again
+			return
+		case <-t.GotInfo():
+			log.Printf("✓ Got metadata: %s", t.Name())
+			log.Printf("   Size: %s", formatBytes(t.Length()))
+			log.Printf("   Files: %d", len(t.Files()))
+
+			// Start downloading unless the queue is full
+			e.updateQueue()
+			if !e.isStopped(hash) {
+				e.startDownload(hash, t)
+				t.AllowDataDownload()
+				t.AllowDataUpload()
+				log.Printf("✓ Started downloading: %s", t.Name())
+			}
+
+			// Save and notify UI
+			e.saveTorrentStates()
+			e.emit("torrent-updated", hash)
+
+		case <-time.After(120 * time.Second):
+			log.Printf("⚠ Timeout waiting for metadata for hash: %s", hash)
+			log.Printf("   Torrent may have no peers or slow DHT")
+
+			// Still allow connections
+			t.AllowDataDownload()
+			t.AllowDataUpload()
+
+			// Continue waiting in background
+			go func() {
+				log.Printf("🔄 Continuing to wait for metadata...")
+				select {
+				case <-t.Closed():
+					return
+				case <-t.GotInfo():
+				}
+				log.Printf("✓ Finally got metadata: %s", t.Name())
+				e.updateQueue()
+				if !e.isStopped(hash) {
+					e.startDownload(hash, t)
+				}
+				e.saveTorrentStates()
+				e.emit("torrent-updated", hash)
+			}()
+
+			// Notify UI of timeout
+			e.emit("torrent-updated", hash)
+		}
+	}()
+
+	return hash, nil
+}
+
+// AddTorrentFile adds a torrent from a file and returns its info hash
+func (e *Engine) AddTorrentFile(filePath string, opts AddOptions) (string, error) {
+	if e.torrentClient() == nil {
+		return "", fmt.Errorf("torrent client not initialized")
+	}
+
+	mi, err := metainfo.LoadFromFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to load torrent file: %w", err)
+	}
+
+	return e.AddTorrentMetaInfo(mi, opts)
+}
+
+// AddTorrentMetaInfo adds a torrent from parsed metainfo and returns its
+// info hash
+func (e *Engine) AddTorrentMetaInfo(mi *metainfo.MetaInfo, opts AddOptions) (string, error) {
+	if e.torrentClient() == nil {
+		return "", fmt.Errorf("torrent client not initialized")
+	}
+
+	info, err := mi.UnmarshalInfo()
+	if err != nil {
+		return "", fmt.Errorf("invalid torrent info: %w", err)
+	}
+	if err := opts.validate(len(info.UpvertedFiles())); err != nil {
+		return "", err
+	}
+
+	hash := mi.HashInfoBytes().String()
+	if err := e.checkNotAdded(hash); err != nil {
+		return "", err
+	}
+	e.dropPreview(hash)
+
+	spec, err := torrent.TorrentSpecFromMetaInfoErr(mi)
+	if err != nil {
+		return "", fmt.Errorf("failed to add torrent: %w", err)
+	}
+	storageRoot, st, err := e.storageFor(opts.SavePath)
+	if err != nil {
+		return "", err
+	}
+	spec.Storage = st
+
+	t, _, err := e.torrentClient().AddTorrentSpec(spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to add torrent: %w", err)
+	}
+
+	e.registerTorrent(hash, t, storageRoot, opts)
+
+	// Start downloading unless paused or the queue is full
+	e.updateQueue()
+	if !e.isStopped(hash) {
+		e.startDownload(hash, t)
+	}
+
+	e.saveTorrentStates()
+
+	log.Printf("✓ Added torrent: %s (save path: %s, paused: %v)", t.Name(), storageRoot, opts.Paused)
+	e.emit("torrent-added", hash)
+
+	return hash, nil
+}
+
+// storageFor returns the storage root for a save path together with
+// storage rooted there, or nil storage for the client's default storage
+// in the download directory
+func (e *Engine) storageFor(savePath string) (string, storage.ClientImpl, error) {
+	if savePath == "" || filepath.Clean(savePath) == filepath.Clean(e.downloadDir) {
+		return e.downloadDir, nil, nil
+	}
+
+	if err := os.MkdirAll(savePath, 0755); err != nil {
+		return "", nil, fmt.Errorf("failed to create save path: %w", err)
+	}
+	st, err := newTorrentStorage(savePath, pathModeDefault)
+	if err != nil {
+		return "", nil, err
+	}
+	return savePath, st, nil
+}
+
+// registerTorrent starts tracking a torrent added to the client with the
+// given options
+func (e *Engine) registerTorrent(hash string, t *torrent.Torrent, storageRoot string, opts AddOptions) {
+	// Initialize speed trackers
+	e.speedsMutex.Lock()
+	e.downloadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
+	e.uploadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
+	e.speedsMutex.Unlock()
+
+	e.registerSession(hash, storageRoot, pathModeDefault)
+	e.sessionsMutex.Lock()
+	if session, ok := e.sessions[hash]; ok {
+		if t.Info() != nil {
+			session.filePriorities = opts.filePriorities(len(t.Files()))
+		}
+		session.category = opts.Category
+		session.tags = opts.Tags
+	}
+	e.sessionsMutex.Unlock()
+
+	e.torrentsMutex.Lock()
+	e.torrents[hash] = t
+	e.torrentsMutex.Unlock()
+
+	e.enqueue(hash)
+
+	if opts.Paused {
+		e.pauseTorrent(hash, t)
+	}
+}
+
+// CreateTorrentFromFiles creates a torrent from local files and starts seeding
+func (e *Engine) CreateTorrentFromFiles(files []string) (string, error) {
+	log.Printf("🚀 CreateTorrentFromFiles CALLED with %d files", len(files))
+	log.Printf("   Files: %v", files)
+
+	if e.torrentClient() == nil {
+		log.Printf("❌ Client is nil!")
+		return "", fmt.Errorf("torrent client not initialized")
+	}
+
+	if len(files) == 0 {
+		return "", fmt.Errorf("no files provided")
+	}
+
+	log.Printf("Creating torrent from %d file(s)...", len(files))
+
+	// Determine the root path for building torrent
+	var rootPath string
+	var isSingleFile bool
+
+	if len(files) == 1 {
+		// Single file
+		rootPath = files[0]
+		isSingleFile = true
+		log.Printf("✓ Single file mode: %s", rootPath)
+	} else {
+		// Multiple files - must be in same directory
+		parentDir := filepath.Dir(files[0])
+		for _, f := range files[1:] {
+			if filepath.Dir(f) != parentDir {
+				return "", fmt.Errorf("all files must be in the same directory")
+			}
+		}
+		rootPath = parentDir
+		isSingleFile = false
+		log.Printf("✓ Multiple files mode: %s", parentDir)
+	}
+
+	log.Printf("🔍 Root path: %s", rootPath)
+
+	// Build metainfo
+	log.Printf("🔍 Creating metainfo...")
+	settings := e.Settings()
+	info := metainfo.Info{
+		PieceLength: settings.PieceLength,
+	}
+
+	// Build from file path
+	log.Printf("🔍 Building from file path...")
+	if err := info.BuildFromFilePath(rootPath); err != nil {
+		log.Printf("❌ Failed to build from file path: %v", err)
+		return "", fmt.Errorf("failed to build torrent info: %w", err)
+	}
+	log.Printf("✓ Built from file path")
+
+	// For single file, ensure the path is set correctly
+	if isSingleFile {
+		info.Files = []metainfo.FileInfo{{
+			Path:   []string{},
+			Length: info.TotalLength(),
+		}}
+	}
+
+	log.Printf("Generating pieces for torrent...")
+
+	// Generate pieces (hash the data)
+	err := info.GeneratePieces(func(fi metainfo.FileInfo) (io.ReadCloser, error) {
+		var fullPath string
+		if isSingleFile {
+			fullPath = rootPath
+		} else {
+			fullPath = filepath.Join(rootPath, filepath.Join(fi.Path...))
+		}
+		log.Printf("🔍 Reading file for hashing: %s", fullPath)
+		return os.Open(fullPath)
+	})
+	if err != nil {
+		log.Printf("❌ Failed to generate pieces: %v", err)
+		return "", fmt.Errorf("failed to generate pieces: %w", err)
+	}
+
+	log.Printf("✓ Torrent info generated, size: %d bytes", info.TotalLength())
+
+	// Create metainfo with trackers
+	mi := metainfo.MetaInfo{
+		AnnounceList: settings.AnnounceList(),
+		InfoBytes:    bencode.MustMarshal(info),
+	}
+	mi.SetDefaults()
+
+	// Generate magnet link
+	magnet, err := mi.MagnetV2()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate magnet link: %w", err)
+	}
+	magnetStr := magnet.String()
+
+	hash := mi.HashInfoBytes().String()
+	log.Printf("✓ Generated torrent with hash: %s", hash)
+
+	// Determine storage base directory
+	storageBaseDir := rootPath
+
+	log.Printf("🔍 Storage base directory: %s", storageBaseDir)
+
+	// Create storage that reads the files from where they already are
+	st, err := newTorrentStorage(storageBaseDir, pathModeFlat)
+	if err != nil {
+		log.Printf("❌ Failed to create storage: %v", err)
+		return "", err
+	}
+
+	// Add torrent with custom storage
+	t, isNew := e.torrentClient().AddTorrentOpt(torrent.AddTorrentOpts{
+		InfoHash: mi.HashInfoBytes(),
+		Storage:  st,
+	})
+
+	if !isNew {
+		log.Printf("⚠ Torrent already exists, using existing instance")
+	}
+
+	// Merge the metadata
+	err = t.MergeSpec(&torrent.TorrentSpec{
+		InfoBytes: mi.InfoBytes,
+		Trackers:  mi.AnnounceList,
+	})
+	if err != nil {
+		log.Printf("❌ Failed to merge spec: %v", err)
+		return "", fmt.Errorf("failed to merge torrent spec: %w", err)
+	}
+
+	log.Printf("✓ Added torrent with hash: %s", hash)
+
+	// Wait for info
+	<-t.GotInfo()
+	log.Printf("✓ Got torrent info: %s", t.Name())
+
+	// DEBUG: Log expected file paths
+	for _, file := range t.Files() {
+		var expectedPath string
+		if isSingleFile {
+			expectedPath = storageBaseDir
+		} else {
+			expectedPath = filepath.Join(storageBaseDir, file.Path())
+		}
+		log.Printf("🔍 Torrent expects file at: %s", expectedPath)
+
+		if stat, err := os.Stat(expectedPath); err != nil {
+			log.Printf("❌ File NOT found: %s", err)
+		} else {
+			log.Printf("✓ File exists: size=%d", stat.Size())
+		}
+	}
+
+	// Tell the torrent to download all pieces
+	t.Seeding()
+	t.AllowDataUpload()
+	t.AllowDataDownload()
+
+	// Initialize speed trackers
+	e.speedsMutex.Lock()
+	e.downloadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
+	e.uploadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
+	e.speedsMutex.Unlock()
+
+	e.registerSession(hash, storageBaseDir, pathModeFlat)
+
+	// Add to torrents map
+	e.torrentsMutex.Lock()
+	e.torrents[hash] = t
+	e.torrentsMutex.Unlock()
+
+	e.enqueue(hash)
+
+	// Verify the data is there; seeding starts once it has been checked
+	log.Printf("Starting data verification...")
+	if err := e.RecheckTorrent(hash); err != nil {
+		log.Printf("⚠ Could not verify %s: %v", t.Name(), err)
+	}
+
+	e.saveTorrentStates()
+	e.emit("torrent-added", hash)
+
+	log.Printf("✓ Created torrent: %s", t.Name())
+	log.Printf("✓ Magnet link: %s", magnetStr)
+
+	return magnetStr, nil
+}
+
+// GetTorrents returns all torrents
+func (e *Engine) GetTorrents() []TorrentInfo {
+	e.torrentsMutex.RLock()
+	defer e.torrentsMutex.RUnlock()
+
+	var torrents []TorrentInfo
+	for hash, t := range e.torrents {
+		info := e.getTorrentInfo(hash, t)
+		torrents = append(torrents, info)
+	}
+
+	return torrents
+}
+
+// GetTorrent returns a single torrent by hash
+func (e *Engine) GetTorrent(infoHash string) (TorrentInfo, error) {
+	e.torrentsMutex.RLock()
+	defer e.torrentsMutex.RUnlock()
+
+	t, exists := e.torrents[infoHash]
+	if !exists {
+		return TorrentInfo{}, ErrTorrentNotFound
+	}
+
+	return e.getTorrentInfo(infoHash, t), nil
+}
+
+// RemoveTorrent removes a torrent, deleting its files if asked
+func (e *Engine) RemoveTorrent(infoHash string, deleteFiles bool) error {
+	_, err := e.RemoveTorrentWithOptions(infoHash, RemoveOptions{DeleteFiles: deleteFiles})
+	return err
+}
+
+// RemoveTorrentWithOptions removes a torrent and deletes its files or
+// moves them to the trash as chosen. Only files below the torrent's
+// storage root are touched; the report lists what happened to each.
+func (e *Engine) RemoveTorrentWithOptions(infoHash string, opts RemoveOptions) (RemoveReport, error) {
+	log.Printf("🔍 RemoveTorrent called - InfoHash: %s, DeleteFiles: %t, Trash: %t", infoHash, opts.DeleteFiles, opts.Trash)
+
+	if e.isBusy(infoHash) {
+		return RemoveReport{}, ErrTorrentBusy
+	}
+
+	e.torrentsMutex.Lock()
+	t, exists := e.torrents[infoHash]
+	if !exists {
+		e.torrentsMutex.Unlock()
+		log.Printf("❌ Torrent not found: %s", infoHash)
+		return RemoveReport{}, ErrTorrentNotFound
+	}
+	delete(e.torrents, infoHash)
+	e.torrentsMutex.Unlock()
+
+	log.Printf("✓ Torrent removed from map: %s", infoHash)
+
+	torrentName := t.Name()
+	if torrentName == "" {
+		torrentName = infoHash
+	}
+	report := RemoveReport{InfoHash: infoHash, Name: torrentName}
+
+	// Clean up speed trackers
+	e.speedsMutex.Lock()
+	delete(e.downloadSpeeds, infoHash)
+	delete(e.uploadSpeeds, infoHash)
+	e.speedsMutex.Unlock()
+
+	// Clean up paused state
+	e.pausedMutex.Lock()
+	delete(e.pausedTorrents, infoHash)
+	e.pausedMutex.Unlock()
+
+	session := e.getSession(infoHash)
+	e.sessionsMutex.Lock()
+	delete(e.sessions, infoHash)
+	e.sessionsMutex.Unlock()
+
+	e.dequeue(infoHash)
+
+	// Store file paths before dropping if we need to delete
+	deleteFiles := opts.DeleteFiles || opts.Trash
+	var filePaths []string
+	if deleteFiles && t.Info() != nil {
+		filePaths = session.filePaths(e.downloadDir, t)
+	}
+
+	// Drop torrent from client, unless pausing already did
+	e.detachTorrent(t)
+	log.Printf("✓ Torrent dropped from client")
+
+	// Delete files after dropping torrent
+	if deleteFiles {
+		deleteTorrentFiles(session.root(e.downloadDir), filePaths, opts.Trash, &report)
+		log.Printf("🗑 Removed torrent and %d files (%d missing, %d refused, %d failed): %s",
+			len(report.Removed), len(report.Missing), len(report.Refused), len(report.Errors), torrentName)
+	} else {
+		log.Printf("🗑 Removed torrent: %s", torrentName)
+	}
+
+	e.saveTorrentStates()
+	log.Printf("✓ Torrent states saved")
+
+	return report, nil
+}
+
+// GetStats returns global statistics
+func (e *Engine) GetStats() Stats {
+	e.torrentsMutex.RLock()
+	defer e.torrentsMutex.RUnlock()
+
+	var totalDown, totalUp int64
+	var activeTorrents, totalPeers int
+
+	e.speedsMutex.RLock()
+	for hash := range e.torrents {
+		if tracker, ok := e.downloadSpeeds[hash]; ok {
+			totalDown += tracker.speed
+		}
+		if tracker, ok := e.uploadSpeeds[hash]; ok {
+			totalUp += tracker.speed
+		}
+	}
+	e.speedsMutex.RUnlock()
+
+	for hash, t := range e.torrents {
+		stats := t.Stats()
+
+		e.pausedMutex.RLock()
+		isPaused := e.pausedTorrents[hash]
+		e.pausedMutex.RUnlock()
+
+		session := e.getSession(hash)
+		if !isPaused && !e.isQueued(hash) && t.Info() != nil && !session.downloadDone(t) {
+			activeTorrents++
+		}
+
+		totalPeers += stats.ActivePeers
+	}
+
+	profile := e.speedProfile()
+	downLimit, upLimit := e.Settings().Bandwidth.Limits(profile)
+	schedule := e.Schedule()
+
+	return Stats{
+		TotalDownloadSpeed: formatSpeed(totalDown),
+		TotalUploadSpeed:   formatSpeed(totalUp),
+		ActiveTorrents:     activeTorrents,
+		TotalPeers:         totalPeers,
+		DownloadLimit:      downLimit,
+		UploadLimit:        upLimit,
+		DownloadLimitStr:   formatLimit(downLimit),
+		UploadLimitStr:     formatLimit(upLimit),
+		AltSpeedEnabled:    profile == config.ProfileAlternative,
+		SpeedProfile:       profile,
+		Scheduled:          schedule.Scheduled,
+	}
+}
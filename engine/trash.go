@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// errTrashUnsupported is returned where SeedRush cannot reach the trash
+var errTrashUnsupported = errors.New("moving to the trash is not supported on this platform")
+
+// moveToTrash moves a file to the user's trash and returns where it went.
+// macOS uses ~/.Trash; other Unix systems use the freedesktop.org trash
+// in $XDG_DATA_HOME/Trash so file managers can restore the file.
+func moveToTrash(path string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		return "", errTrashUnsupported
+	case "darwin":
+		trashDir := filepath.Join(homeDir, ".Trash")
+		if err := os.MkdirAll(trashDir, 0700); err != nil {
+			return "", fmt.Errorf("failed to create trash: %w", err)
+		}
+		for i := 1; ; i++ {
+			dst := filepath.Join(trashDir, trashName(filepath.Base(path), i))
+			err := trashFile(path, dst)
+			if errors.Is(err, os.ErrExist) {
+				continue
+			}
+			if err != nil {
+				return "", err
+			}
+			return dst, nil
+		}
+	}
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(homeDir, ".local", "share")
+	}
+	filesDir := filepath.Join(dataHome, "Trash", "files")
+	infoDir := filepath.Join(dataHome, "Trash", "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", fmt.Errorf("failed to create trash: %w", err)
+		}
+	}
+
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: path}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	for i := 1; ; i++ {
+		name := trashName(filepath.Base(path), i)
+		dst := filepath.Join(filesDir, name)
+		if _, err := os.Lstat(dst); err == nil {
+			continue
+		}
+
+		// The info file is created first; it reserves the name in the
+		// trash, so deletions running at the same time pick different ones
+		infoPath := filepath.Join(infoDir, name+".trashinfo")
+		f, err := os.OpenFile(infoPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to write trash info: %w", err)
+		}
+		_, err = f.WriteString(info)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			os.Remove(infoPath)
+			return "", fmt.Errorf("failed to write trash info: %w", err)
+		}
+
+		if err := trashFile(path, dst); err != nil {
+			os.Remove(infoPath)
+			if errors.Is(err, os.ErrExist) {
+				continue
+			}
+			return "", err
+		}
+		return dst, nil
+	}
+}
+
+// trashName returns the i-th name tried in the trash for a file called
+// name: name itself, then "name 2", "name 3" and so on before the
+// extension
+func trashName(name string, i int) string {
+	if i == 1 {
+		return name
+	}
+	ext := filepath.Ext(name)
+	return fmt.Sprintf("%s %d%s", strings.TrimSuffix(name, ext), i, ext)
+}
+
+// trashFile moves the file src to dst, failing with os.ErrExist when dst
+// is taken instead of replacing it. A hard link claims dst on the same
+// filesystem; elsewhere the data is copied to a file created exclusively.
+func trashFile(src, dst string) error {
+	if err := os.Link(src, dst); err == nil {
+		return os.Remove(src)
+	} else if errors.Is(err, os.ErrExist) {
+		return err
+	}
+
+	stat, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := copyFileData(src, dst, stat.Mode(), nil); err != nil {
+		if !errors.Is(err, os.ErrExist) {
+			os.Remove(dst)
+		}
+		return fmt.Errorf("failed to copy %s: %w", src, err)
+	}
+	return os.Remove(src)
+}
@@ -0,0 +1,105 @@
+package engine
+
+import (
+	"time"
+
+	"torrentflow/config"
+)
+
+// TorrentInfo represents torrent information for the frontend
+type TorrentInfo struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	InfoHash      string     `json:"infoHash"`
+	Size          int64      `json:"size"`
+	SizeStr       string     `json:"sizeStr"`
+	Progress      float64    `json:"progress"`
+	Status        string     `json:"status"`
+	DownloadSpeed int64      `json:"downloadSpeed"`
+	UploadSpeed   int64      `json:"uploadSpeed"`
+	DownloadedStr string     `json:"downloadSpeedStr"`
+	UploadedStr   string     `json:"uploadSpeedStr"`
+	Peers         int        `json:"peers"`
+	Seeds         int        `json:"seeds"`
+	ETA           string     `json:"eta"`
+	Files         []FileInfo `json:"files"`
+	AddedAt       time.Time  `json:"addedAt"`
+	IsPaused      bool       `json:"isPaused"`
+	// Zero times mean the torrent has not completed / transferred data yet
+	CompletedAt     time.Time `json:"completedAt"`
+	LastActivityAt  time.Time `json:"lastActivityAt"`
+	TotalUploaded   int64     `json:"totalUploaded"`
+	TotalDownloaded int64     `json:"totalDownloaded"`
+	Ratio           float64   `json:"ratio"`
+	// Per-torrent limits in bytes per second, 0 for no limit
+	DownloadLimit int64 `json:"downloadLimit"`
+	UploadLimit   int64 `json:"uploadLimit"`
+	// QueuePosition is 1-based; lower positions get slots first
+	QueuePosition int              `json:"queuePosition"`
+	SeedGoal      SeedGoalProgress `json:"seedGoal"`
+	Category      string           `json:"category"`
+	Tags          []string         `json:"tags"`
+	// SavePath is the directory the torrent's data is stored below
+	SavePath string `json:"savePath"`
+	// EncryptedPeers is the share of connected peers using an encrypted
+	// connection, in percent
+	EncryptedPeers float64 `json:"encryptedPeers"`
+}
+
+// FileInfo represents file information within a torrent
+type FileInfo struct {
+	Name     string  `json:"name"`
+	Size     int64   `json:"size"`
+	SizeStr  string  `json:"sizeStr"`
+	Progress float64 `json:"progress"`
+	Path     string  `json:"path"`
+	Index    int     `json:"index"`
+	// Priority is one of the FilePriority* values
+	Priority string `json:"priority"`
+}
+
+// Stats represents global statistics
+type Stats struct {
+	TotalDownloadSpeed string `json:"totalDownload"`
+	TotalUploadSpeed   string `json:"totalUpload"`
+	ActiveTorrents     int    `json:"activeTorrents"`
+	TotalPeers         int    `json:"totalPeers"`
+	// Global limits in force, in bytes per second with 0 for unlimited
+	DownloadLimit    int64  `json:"downloadLimit"`
+	UploadLimit      int64  `json:"uploadLimit"`
+	DownloadLimitStr string `json:"downloadLimitStr"`
+	UploadLimitStr   string `json:"uploadLimitStr"`
+	AltSpeedEnabled  bool   `json:"altSpeedEnabled"`
+	SpeedProfile     string `json:"speedProfile"`
+	// Scheduled is set while a schedule rule picks the profile
+	Scheduled bool `json:"scheduled"`
+}
+
+// TorrentState represents saved torrent state for persistence
+type TorrentState struct {
+	InfoHash        string     `json:"infoHash"`
+	MagnetURI       string     `json:"magnetUri,omitempty"`
+	InfoBytes       []byte     `json:"infoBytes,omitempty"`
+	Trackers        [][]string `json:"trackers,omitempty"`
+	StorageRoot     string     `json:"storageRoot,omitempty"`
+	PathMode        string     `json:"pathMode,omitempty"`
+	FilePriorities  []string   `json:"filePriorities,omitempty"`
+	BytesUploaded   int64      `json:"bytesUploaded"`
+	BytesDownloaded int64      `json:"bytesDownloaded"`
+	IsPaused        bool       `json:"isPaused"`
+	AddedAt         time.Time  `json:"addedAt"`
+	CompletedAt     time.Time  `json:"completedAt"`
+	LastActivityAt  time.Time  `json:"lastActivityAt"`
+	IdleSeconds     int64      `json:"idleSeconds,omitempty"`
+	DownloadLimit   int64      `json:"downloadLimit,omitempty"`
+	UploadLimit     int64      `json:"uploadLimit,omitempty"`
+	ScheduledPause  bool       `json:"scheduledPause,omitempty"`
+	NetworkPause    bool       `json:"networkPause,omitempty"`
+	QueuePosition   int        `json:"queuePosition,omitempty"`
+	// SeedGoals are the torrent's own goals, nil for the global ones
+	SeedGoals *config.SeedGoals `json:"seedGoals,omitempty"`
+	Category  string            `json:"category,omitempty"`
+	Tags      []string          `json:"tags,omitempty"`
+	// Peers were added by hand as ip:port
+	Peers []string `json:"peers,omitempty"`
+}
@@ -1,33 +1,116 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
-import {main} from '../models';
+import {engine} from '../models';
+import {config} from '../models';
+
+export function AddBlocklist(arg1:string):Promise<void>;
 
 export function AddMagnet(arg1:string):Promise<void>;
 
+export function AddMagnetTo(arg1:string,arg2:string):Promise<void>;
+
+export function AddPeer(arg1:string,arg2:string):Promise<void>;
+
 export function AddTorrentFile(arg1:string):Promise<void>;
 
+export function AddTorrentFileTo(arg1:string,arg2:string):Promise<void>;
+
+export function BanPeer(arg1:string):Promise<void>;
+
+export function CancelPreview(arg1:string):Promise<void>;
+
+export function CommitPreview(arg1:string,arg2:engine.AddOptions):Promise<string>;
+
 export function CreateTorrentFromFiles(arg1:Array<string>):Promise<string>;
 
 export function GetBalance():Promise<number>;
 
+export function GetBannedPeers():Promise<Array<string>>;
+
+export function GetBlocklists():Promise<Array<engine.BlocklistInfo>>;
+
 export function GetDepositAddress():Promise<string>;
 
-export function GetStats():Promise<main.Stats>;
+export function GetNetworkInterfaces():Promise<Array<engine.NetworkInterface>>;
+
+export function GetNetworkState():Promise<engine.NetworkState>;
+
+export function GetPeers(arg1:string):Promise<Array<engine.PeerInfo>>;
+
+export function GetPieceMap(arg1:string):Promise<engine.PieceMap>;
+
+export function GetPortStatus():Promise<engine.PortStatus>;
+
+export function GetPreview(arg1:string):Promise<engine.TorrentPreview>;
+
+export function GetRecheckReport(arg1:string):Promise<engine.RecheckReport>;
+
+export function GetSchedule():Promise<engine.ScheduleState>;
+
+export function GetSettings():Promise<config.Settings>;
+
+export function GetStats():Promise<engine.Stats>;
+
+export function GetTorrent(arg1:string):Promise<engine.TorrentInfo>;
+
+export function GetTorrents():Promise<Array<engine.TorrentInfo>>;
+
+export function KickPeer(arg1:string,arg2:string):Promise<void>;
 
-export function GetTorrent(arg1:string):Promise<main.TorrentInfo>;
+export function MoveInQueue(arg1:string,arg2:string):Promise<void>;
 
-export function GetTorrents():Promise<Array<main.TorrentInfo>>;
+export function MoveTorrentStorage(arg1:string,arg2:string):Promise<void>;
 
 export function OpenDownloadFolder():Promise<void>;
 
+export function PauseAll():Promise<void>;
+
 export function PauseTorrent(arg1:string):Promise<void>;
 
+export function PreviewMagnet(arg1:string):Promise<engine.TorrentPreview>;
+
+export function PreviewTorrentFile(arg1:string):Promise<engine.TorrentPreview>;
+
+export function RecheckTorrent(arg1:string):Promise<void>;
+
+export function RemoveBlocklist(arg1:string):Promise<void>;
+
 export function RemoveTorrent(arg1:string,arg2:boolean):Promise<void>;
 
+export function RemoveTorrentWithOptions(arg1:string,arg2:engine.RemoveOptions):Promise<engine.RemoveReport>;
+
+export function ResumeAll():Promise<void>;
+
 export function ResumeTorrent(arg1:string):Promise<void>;
 
+export function SelectBlocklistFile():Promise<string>;
+
+export function SelectDirectory():Promise<string>;
+
+export function SelectFiles(arg1:string,arg2:Array<number>):Promise<void>;
+
 export function SelectLocalFiles():Promise<Array<string>>;
 
 export function SelectTorrentFile():Promise<string>;
 
+export function SetAltSpeedEnabled(arg1:boolean):Promise<void>;
+
+export function SetBindInterface(arg1:string):Promise<void>;
+
 export function SetDepositAddress(arg1:string):Promise<void>;
+
+export function SetFilePriority(arg1:string,arg2:number,arg3:string):Promise<void>;
+
+export function SetListenPort(arg1:number):Promise<void>;
+
+export function SetPortForwarding(arg1:boolean):Promise<void>;
+
+export function SetTorrentLimits(arg1:string,arg2:number,arg3:number):Promise<void>;
+
+export function SetTorrentSeedGoals(arg1:string,arg2:config.SeedGoals):Promise<void>;
+
+export function TestPort():Promise<engine.PortTest>;
+
+export function UnbanPeer(arg1:string):Promise<void>;
+
+export function UpdateSettings(arg1:config.Settings):Promise<config.UpdateResult>;
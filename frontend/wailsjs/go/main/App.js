@@ -2,14 +2,42 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function AddBlocklist(arg1) {
+  return window['go']['main']['App']['AddBlocklist'](arg1);
+}
+
 export function AddMagnet(arg1) {
   return window['go']['main']['App']['AddMagnet'](arg1);
 }
 
+export function AddMagnetTo(arg1, arg2) {
+  return window['go']['main']['App']['AddMagnetTo'](arg1, arg2);
+}
+
+export function AddPeer(arg1, arg2) {
+  return window['go']['main']['App']['AddPeer'](arg1, arg2);
+}
+
 export function AddTorrentFile(arg1) {
   return window['go']['main']['App']['AddTorrentFile'](arg1);
 }
 
+export function AddTorrentFileTo(arg1, arg2) {
+  return window['go']['main']['App']['AddTorrentFileTo'](arg1, arg2);
+}
+
+export function BanPeer(arg1) {
+  return window['go']['main']['App']['BanPeer'](arg1);
+}
+
+export function CancelPreview(arg1) {
+  return window['go']['main']['App']['CancelPreview'](arg1);
+}
+
+export function CommitPreview(arg1, arg2) {
+  return window['go']['main']['App']['CommitPreview'](arg1, arg2);
+}
+
 export function CreateTorrentFromFiles(arg1) {
   return window['go']['main']['App']['CreateTorrentFromFiles'](arg1);
 }
@@ -18,10 +46,54 @@ export function GetBalance() {
   return window['go']['main']['App']['GetBalance']();
 }
 
+export function GetBannedPeers() {
+  return window['go']['main']['App']['GetBannedPeers']();
+}
+
+export function GetBlocklists() {
+  return window['go']['main']['App']['GetBlocklists']();
+}
+
 export function GetDepositAddress() {
   return window['go']['main']['App']['GetDepositAddress']();
 }
 
+export function GetNetworkInterfaces() {
+  return window['go']['main']['App']['GetNetworkInterfaces']();
+}
+
+export function GetNetworkState() {
+  return window['go']['main']['App']['GetNetworkState']();
+}
+
+export function GetPeers(arg1) {
+  return window['go']['main']['App']['GetPeers'](arg1);
+}
+
+export function GetPieceMap(arg1) {
+  return window['go']['main']['App']['GetPieceMap'](arg1);
+}
+
+export function GetPortStatus() {
+  return window['go']['main']['App']['GetPortStatus']();
+}
+
+export function GetPreview(arg1) {
+  return window['go']['main']['App']['GetPreview'](arg1);
+}
+
+export function GetRecheckReport(arg1) {
+  return window['go']['main']['App']['GetRecheckReport'](arg1);
+}
+
+export function GetSchedule() {
+  return window['go']['main']['App']['GetSchedule']();
+}
+
+export function GetSettings() {
+  return window['go']['main']['App']['GetSettings']();
+}
+
 export function GetStats() {
   return window['go']['main']['App']['GetStats']();
 }
@@ -34,22 +106,74 @@ export function GetTorrents() {
   return window['go']['main']['App']['GetTorrents']();
 }
 
+export function KickPeer(arg1, arg2) {
+  return window['go']['main']['App']['KickPeer'](arg1, arg2);
+}
+
+export function MoveInQueue(arg1, arg2) {
+  return window['go']['main']['App']['MoveInQueue'](arg1, arg2);
+}
+
+export function MoveTorrentStorage(arg1, arg2) {
+  return window['go']['main']['App']['MoveTorrentStorage'](arg1, arg2);
+}
+
 export function OpenDownloadFolder() {
   return window['go']['main']['App']['OpenDownloadFolder']();
 }
 
+export function PauseAll() {
+  return window['go']['main']['App']['PauseAll']();
+}
+
 export function PauseTorrent(arg1) {
   return window['go']['main']['App']['PauseTorrent'](arg1);
 }
 
+export function PreviewMagnet(arg1) {
+  return window['go']['main']['App']['PreviewMagnet'](arg1);
+}
+
+export function PreviewTorrentFile(arg1) {
+  return window['go']['main']['App']['PreviewTorrentFile'](arg1);
+}
+
+export function RecheckTorrent(arg1) {
+  return window['go']['main']['App']['RecheckTorrent'](arg1);
+}
+
+export function RemoveBlocklist(arg1) {
+  return window['go']['main']['App']['RemoveBlocklist'](arg1);
+}
+
 export function RemoveTorrent(arg1, arg2) {
   return window['go']['main']['App']['RemoveTorrent'](arg1, arg2);
 }
 
+export function RemoveTorrentWithOptions(arg1, arg2) {
+  return window['go']['main']['App']['RemoveTorrentWithOptions'](arg1, arg2);
+}
+
+export function ResumeAll() {
+  return window['go']['main']['App']['ResumeAll']();
+}
+
 export function ResumeTorrent(arg1) {
   return window['go']['main']['App']['ResumeTorrent'](arg1);
 }
 
+export function SelectBlocklistFile() {
+  return window['go']['main']['App']['SelectBlocklistFile']();
+}
+
+export function SelectDirectory() {
+  return window['go']['main']['App']['SelectDirectory']();
+}
+
+export function SelectFiles(arg1, arg2) {
+  return window['go']['main']['App']['SelectFiles'](arg1, arg2);
+}
+
 export function SelectLocalFiles() {
   return window['go']['main']['App']['SelectLocalFiles']();
 }
@@ -58,6 +182,46 @@ export function SelectTorrentFile() {
   return window['go']['main']['App']['SelectTorrentFile']();
 }
 
+export function SetAltSpeedEnabled(arg1) {
+  return window['go']['main']['App']['SetAltSpeedEnabled'](arg1);
+}
+
+export function SetBindInterface(arg1) {
+  return window['go']['main']['App']['SetBindInterface'](arg1);
+}
+
 export function SetDepositAddress(arg1) {
   return window['go']['main']['App']['SetDepositAddress'](arg1);
 }
+
+export function SetFilePriority(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SetFilePriority'](arg1, arg2, arg3);
+}
+
+export function SetListenPort(arg1) {
+  return window['go']['main']['App']['SetListenPort'](arg1);
+}
+
+export function SetPortForwarding(arg1) {
+  return window['go']['main']['App']['SetPortForwarding'](arg1);
+}
+
+export function SetTorrentLimits(arg1, arg2, arg3) {
+  return window['go']['main']['App']['SetTorrentLimits'](arg1, arg2, arg3);
+}
+
+export function SetTorrentSeedGoals(arg1, arg2) {
+  return window['go']['main']['App']['SetTorrentSeedGoals'](arg1, arg2);
+}
+
+export function TestPort() {
+  return window['go']['main']['App']['TestPort']();
+}
+
+export function UnbanPeer(arg1) {
+  return window['go']['main']['App']['UnbanPeer'](arg1);
+}
+
+export function UpdateSettings(arg1) {
+  return window['go']['main']['App']['UpdateSettings'](arg1);
+}
@@ -1,11 +1,339 @@
-export namespace main {
+export namespace config {
 	
+	export class APISettings {
+	    addr: string;
+	    token?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new APISettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.addr = source["addr"];
+	        this.token = source["token"];
+	    }
+	}
+	export class BandwidthSettings {
+	    downloadLimit: number;
+	    uploadLimit: number;
+	    altDownloadLimit: number;
+	    altUploadLimit: number;
+	    altEnabled: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new BandwidthSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.downloadLimit = source["downloadLimit"];
+	        this.uploadLimit = source["uploadLimit"];
+	        this.altDownloadLimit = source["altDownloadLimit"];
+	        this.altUploadLimit = source["altUploadLimit"];
+	        this.altEnabled = source["altEnabled"];
+	    }
+	}
+	export class ProxySettings {
+	    type: string;
+	    host: string;
+	    port: number;
+	    username?: string;
+	    password?: string;
+	    trackersOnly: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ProxySettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.type = source["type"];
+	        this.host = source["host"];
+	        this.port = source["port"];
+	        this.username = source["username"];
+	        this.password = source["password"];
+	        this.trackersOnly = source["trackersOnly"];
+	    }
+	}
+	export class QueueSettings {
+	    maxActiveDownloads: number;
+	    maxActiveSeeds: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new QueueSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.maxActiveDownloads = source["maxActiveDownloads"];
+	        this.maxActiveSeeds = source["maxActiveSeeds"];
+	    }
+	}
+	export class ScheduleRule {
+	    days: string[];
+	    start: string;
+	    end: string;
+	    profile: string;
+	    pauseAll: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ScheduleRule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.days = source["days"];
+	        this.start = source["start"];
+	        this.end = source["end"];
+	        this.profile = source["profile"];
+	        this.pauseAll = source["pauseAll"];
+	    }
+	}
+	export class ScheduleSettings {
+	    enabled: boolean;
+	    rules: ScheduleRule[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ScheduleSettings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.rules = this.convertValues(source["rules"], ScheduleRule);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SeedGoals {
+	    ratio: number;
+	    minSeedMinutes: number;
+	    maxSeedMinutes: number;
+	    idleMinutes: number;
+	    action: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SeedGoals(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.ratio = source["ratio"];
+	        this.minSeedMinutes = source["minSeedMinutes"];
+	        this.maxSeedMinutes = source["maxSeedMinutes"];
+	        this.idleMinutes = source["idleMinutes"];
+	        this.action = source["action"];
+	    }
+	}
+	export class Settings {
+	    downloadDir: string;
+	    stateFile: string;
+	    listenPort: number;
+	    portForwarding: boolean;
+	    seed: boolean;
+	    enableDht: boolean;
+	    enableIpv6: boolean;
+	    enableTcp: boolean;
+	    enableUtp: boolean;
+	    encryption: string;
+	    bindInterface: string;
+	    pieceLength: number;
+	    trackers: string[];
+	    bannedPeers: string[];
+	    blocklists: string[];
+	    bandwidth: BandwidthSettings;
+	    schedule: ScheduleSettings;
+	    queue: QueueSettings;
+	    seeding: SeedGoals;
+	    proxy: ProxySettings;
+	    api: APISettings;
+	
+	    static createFrom(source: any = {}) {
+	        return new Settings(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.downloadDir = source["downloadDir"];
+	        this.stateFile = source["stateFile"];
+	        this.listenPort = source["listenPort"];
+	        this.portForwarding = source["portForwarding"];
+	        this.seed = source["seed"];
+	        this.enableDht = source["enableDht"];
+	        this.enableIpv6 = source["enableIpv6"];
+	        this.enableTcp = source["enableTcp"];
+	        this.enableUtp = source["enableUtp"];
+	        this.encryption = source["encryption"];
+	        this.bindInterface = source["bindInterface"];
+	        this.pieceLength = source["pieceLength"];
+	        this.trackers = source["trackers"];
+	        this.bannedPeers = source["bannedPeers"];
+	        this.blocklists = source["blocklists"];
+	        this.bandwidth = this.convertValues(source["bandwidth"], BandwidthSettings);
+	        this.schedule = this.convertValues(source["schedule"], ScheduleSettings);
+	        this.queue = this.convertValues(source["queue"], QueueSettings);
+	        this.seeding = this.convertValues(source["seeding"], SeedGoals);
+	        this.proxy = this.convertValues(source["proxy"], ProxySettings);
+	        this.api = this.convertValues(source["api"], APISettings);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class UpdateResult {
+	    settings: Settings;
+	    restartRequired: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new UpdateResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.settings = this.convertValues(source["settings"], Settings);
+	        this.restartRequired = source["restartRequired"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace engine {
+	
+	export class AddOptions {
+	    files: number[];
+	    savePath: string;
+	    paused: boolean;
+	    category: string;
+	    tags: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new AddOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.files = source["files"];
+	        this.savePath = source["savePath"];
+	        this.paused = source["paused"];
+	        this.category = source["category"];
+	        this.tags = source["tags"];
+	    }
+	}
+	export class AvailabilityRun {
+	    peers: number;
+	    length: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new AvailabilityRun(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.peers = source["peers"];
+	        this.length = source["length"];
+	    }
+	}
+	export class BlocklistInfo {
+	    path: string;
+	    format: string;
+	    ranges: number;
+	    skippedLines: number;
+	    blocked: number;
+	    // Go type: time
+	    loadedAt: any;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new BlocklistInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.path = source["path"];
+	        this.format = source["format"];
+	        this.ranges = source["ranges"];
+	        this.skippedLines = source["skippedLines"];
+	        this.blocked = source["blocked"];
+	        this.loadedAt = this.convertValues(source["loadedAt"], null);
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 	export class FileInfo {
 	    name: string;
 	    size: number;
 	    sizeStr: string;
 	    progress: number;
 	    path: string;
+	    index: number;
+	    priority: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new FileInfo(source);
@@ -18,6 +346,437 @@ export namespace main {
 	        this.sizeStr = source["sizeStr"];
 	        this.progress = source["progress"];
 	        this.path = source["path"];
+	        this.index = source["index"];
+	        this.priority = source["priority"];
+	    }
+	}
+	export class NetworkInterface {
+	    name: string;
+	    up: boolean;
+	    addresses: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new NetworkInterface(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.up = source["up"];
+	        this.addresses = source["addresses"];
+	    }
+	}
+	export class NetworkState {
+	    bound: boolean;
+	    interface: string;
+	    up: boolean;
+	    addresses: string[];
+	    paused: number;
+	    // Go type: time
+	    changedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new NetworkState(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.bound = source["bound"];
+	        this.interface = source["interface"];
+	        this.up = source["up"];
+	        this.addresses = source["addresses"];
+	        this.paused = source["paused"];
+	        this.changedAt = this.convertValues(source["changedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PeerInfo {
+	    address: string;
+	    port: number;
+	    client: string;
+	    encrypted: boolean;
+	    utp: boolean;
+	    incoming: boolean;
+	    choked: boolean;
+	    interested: boolean;
+	    progress: number;
+	    downloadSpeed: number;
+	    uploadSpeed: number;
+	    downloadSpeedStr: string;
+	    uploadSpeedStr: string;
+	    source: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new PeerInfo(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.address = source["address"];
+	        this.port = source["port"];
+	        this.client = source["client"];
+	        this.encrypted = source["encrypted"];
+	        this.utp = source["utp"];
+	        this.incoming = source["incoming"];
+	        this.choked = source["choked"];
+	        this.interested = source["interested"];
+	        this.progress = source["progress"];
+	        this.downloadSpeed = source["downloadSpeed"];
+	        this.uploadSpeed = source["uploadSpeed"];
+	        this.downloadSpeedStr = source["downloadSpeedStr"];
+	        this.uploadSpeedStr = source["uploadSpeedStr"];
+	        this.source = source["source"];
+	    }
+	}
+	export class PieceRun {
+	    state: string;
+	    length: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PieceRun(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.state = source["state"];
+	        this.length = source["length"];
+	    }
+	}
+	export class PieceMap {
+	    infoHash: string;
+	    numPieces: number;
+	    pieceLength: number;
+	    complete: number;
+	    partial: number;
+	    pending: number;
+	    pieces: PieceRun[];
+	    availability: AvailabilityRun[];
+	    peers: number;
+	    distributedCopies: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new PieceMap(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.infoHash = source["infoHash"];
+	        this.numPieces = source["numPieces"];
+	        this.pieceLength = source["pieceLength"];
+	        this.complete = source["complete"];
+	        this.partial = source["partial"];
+	        this.pending = source["pending"];
+	        this.pieces = this.convertValues(source["pieces"], PieceRun);
+	        this.availability = this.convertValues(source["availability"], AvailabilityRun);
+	        this.peers = source["peers"];
+	        this.distributedCopies = source["distributedCopies"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class PortMapping {
+	    protocol: string;
+	    status: string;
+	    gateway?: string;
+	    externalIp?: string;
+	    externalPort?: number;
+	    error?: string;
+	    // Go type: time
+	    updatedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new PortMapping(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.protocol = source["protocol"];
+	        this.status = source["status"];
+	        this.gateway = source["gateway"];
+	        this.externalIp = source["externalIp"];
+	        this.externalPort = source["externalPort"];
+	        this.error = source["error"];
+	        this.updatedAt = this.convertValues(source["updatedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PortTest {
+	    port: number;
+	    open: boolean;
+	    error?: string;
+	    // Go type: time
+	    testedAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new PortTest(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.port = source["port"];
+	        this.open = source["open"];
+	        this.error = source["error"];
+	        this.testedAt = this.convertValues(source["testedAt"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class PortStatus {
+	    port: number;
+	    configured: number;
+	    fallback: boolean;
+	    forwarding: boolean;
+	    mappings: PortMapping[];
+	    externalAddress: string;
+	    test?: PortTest;
+	
+	    static createFrom(source: any = {}) {
+	        return new PortStatus(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.port = source["port"];
+	        this.configured = source["configured"];
+	        this.fallback = source["fallback"];
+	        this.forwarding = source["forwarding"];
+	        this.mappings = this.convertValues(source["mappings"], PortMapping);
+	        this.externalAddress = source["externalAddress"];
+	        this.test = this.convertValues(source["test"], PortTest);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	
+	export class RecheckReport {
+	    infoHash: string;
+	    name: string;
+	    numPieces: number;
+	    piecesChecked: number;
+	    piecesComplete: number;
+	    piecesFailed: number;
+	    progress: number;
+	    missing: string[];
+	    corrupted: string[];
+	    // Go type: time
+	    startedAt: any;
+	    // Go type: time
+	    finishedAt: any;
+	    error?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RecheckReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.infoHash = source["infoHash"];
+	        this.name = source["name"];
+	        this.numPieces = source["numPieces"];
+	        this.piecesChecked = source["piecesChecked"];
+	        this.piecesComplete = source["piecesComplete"];
+	        this.piecesFailed = source["piecesFailed"];
+	        this.progress = source["progress"];
+	        this.missing = source["missing"];
+	        this.corrupted = source["corrupted"];
+	        this.startedAt = this.convertValues(source["startedAt"], null);
+	        this.finishedAt = this.convertValues(source["finishedAt"], null);
+	        this.error = source["error"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class RemoveOptions {
+	    deleteFiles: boolean;
+	    trash: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new RemoveOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.deleteFiles = source["deleteFiles"];
+	        this.trash = source["trash"];
+	    }
+	}
+	export class RemoveReport {
+	    infoHash: string;
+	    name: string;
+	    storageRoot: string;
+	    trashed: boolean;
+	    removed: string[];
+	    removedDirs: string[];
+	    missing: string[];
+	    refused: string[];
+	    errors: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new RemoveReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.infoHash = source["infoHash"];
+	        this.name = source["name"];
+	        this.storageRoot = source["storageRoot"];
+	        this.trashed = source["trashed"];
+	        this.removed = source["removed"];
+	        this.removedDirs = source["removedDirs"];
+	        this.missing = source["missing"];
+	        this.refused = source["refused"];
+	        this.errors = source["errors"];
+	    }
+	}
+	export class ScheduleState {
+	    profile: string;
+	    scheduled: boolean;
+	    paused: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new ScheduleState(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.profile = source["profile"];
+	        this.scheduled = source["scheduled"];
+	        this.paused = source["paused"];
+	    }
+	}
+	export class SeedGoalProgress {
+	    enabled: boolean;
+	    custom: boolean;
+	    action: string;
+	    targetRatio: number;
+	    ratioProgress: number;
+	    seedTime: number;
+	    minSeedTime: number;
+	    maxSeedTime: number;
+	    seedTimeProgress: number;
+	    idleTime: number;
+	    idleTimeout: number;
+	    idleProgress: number;
+	    met: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new SeedGoalProgress(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.enabled = source["enabled"];
+	        this.custom = source["custom"];
+	        this.action = source["action"];
+	        this.targetRatio = source["targetRatio"];
+	        this.ratioProgress = source["ratioProgress"];
+	        this.seedTime = source["seedTime"];
+	        this.minSeedTime = source["minSeedTime"];
+	        this.maxSeedTime = source["maxSeedTime"];
+	        this.seedTimeProgress = source["seedTimeProgress"];
+	        this.idleTime = source["idleTime"];
+	        this.idleTimeout = source["idleTimeout"];
+	        this.idleProgress = source["idleProgress"];
+	        this.met = source["met"];
 	    }
 	}
 	export class Stats {
@@ -25,6 +784,13 @@ export namespace main {
 	    totalUpload: string;
 	    activeTorrents: number;
 	    totalPeers: number;
+	    downloadLimit: number;
+	    uploadLimit: number;
+	    downloadLimitStr: string;
+	    uploadLimitStr: string;
+	    altSpeedEnabled: boolean;
+	    speedProfile: string;
+	    scheduled: boolean;
 	
 	    static createFrom(source: any = {}) {
 	        return new Stats(source);
@@ -36,6 +802,13 @@ export namespace main {
 	        this.totalUpload = source["totalUpload"];
 	        this.activeTorrents = source["activeTorrents"];
 	        this.totalPeers = source["totalPeers"];
+	        this.downloadLimit = source["downloadLimit"];
+	        this.uploadLimit = source["uploadLimit"];
+	        this.downloadLimitStr = source["downloadLimitStr"];
+	        this.uploadLimitStr = source["uploadLimitStr"];
+	        this.altSpeedEnabled = source["altSpeedEnabled"];
+	        this.speedProfile = source["speedProfile"];
+	        this.scheduled = source["scheduled"];
 	    }
 	}
 	export class TorrentInfo {
@@ -57,6 +830,21 @@ export namespace main {
 	    // Go type: time
 	    addedAt: any;
 	    isPaused: boolean;
+	    // Go type: time
+	    completedAt: any;
+	    // Go type: time
+	    lastActivityAt: any;
+	    totalUploaded: number;
+	    totalDownloaded: number;
+	    ratio: number;
+	    downloadLimit: number;
+	    uploadLimit: number;
+	    queuePosition: number;
+	    seedGoal: SeedGoalProgress;
+	    category: string;
+	    tags: string[];
+	    savePath: string;
+	    encryptedPeers: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new TorrentInfo(source);
@@ -81,6 +869,68 @@ export namespace main {
 	        this.files = this.convertValues(source["files"], FileInfo);
 	        this.addedAt = this.convertValues(source["addedAt"], null);
 	        this.isPaused = source["isPaused"];
+	        this.completedAt = this.convertValues(source["completedAt"], null);
+	        this.lastActivityAt = this.convertValues(source["lastActivityAt"], null);
+	        this.totalUploaded = source["totalUploaded"];
+	        this.totalDownloaded = source["totalDownloaded"];
+	        this.ratio = source["ratio"];
+	        this.downloadLimit = source["downloadLimit"];
+	        this.uploadLimit = source["uploadLimit"];
+	        this.queuePosition = source["queuePosition"];
+	        this.seedGoal = this.convertValues(source["seedGoal"], SeedGoalProgress);
+	        this.category = source["category"];
+	        this.tags = source["tags"];
+	        this.savePath = source["savePath"];
+	        this.encryptedPeers = source["encryptedPeers"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class TorrentPreview {
+	    id: string;
+	    name: string;
+	    ready: boolean;
+	    size: number;
+	    sizeStr: string;
+	    files: FileInfo[];
+	    trackers: string[];
+	    pieceLength: number;
+	    numPieces: number;
+	    // Go type: time
+	    expiresAt: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new TorrentPreview(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.id = source["id"];
+	        this.name = source["name"];
+	        this.ready = source["ready"];
+	        this.size = source["size"];
+	        this.sizeStr = source["sizeStr"];
+	        this.files = this.convertValues(source["files"], FileInfo);
+	        this.trackers = source["trackers"];
+	        this.pieceLength = source["pieceLength"];
+	        this.numPieces = source["numPieces"];
+	        this.expiresAt = this.convertValues(source["expiresAt"], null);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
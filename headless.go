@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"torrentflow/config"
+	"torrentflow/engine"
+)
+
+// runHeadless runs the torrent engine without a webview until SIGINT or
+// SIGTERM is received. It uses the same download folder and state file
+// as the desktop app.
+func runHeadless(cfg *config.Manager) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	settings := cfg.Settings()
+	eng := engine.New(settings)
+	keepListenPort(cfg, eng)
+	if err := eng.Start(); err != nil {
+		return err
+	}
+
+	server, err := startRemoteAPI(eng, settings.API)
+	if err != nil {
+		eng.Close()
+		return err
+	}
+
+	log.Printf("✓ Running headless - press Ctrl+C to stop")
+	<-ctx.Done()
+
+	log.Printf("Shutting down...")
+	if server != nil {
+		server.Close()
+	}
+	eng.Close()
+	return nil
+}
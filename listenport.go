@@ -0,0 +1,37 @@
+package main
+
+import (
+	"log"
+
+	"torrentflow/config"
+	"torrentflow/engine"
+)
+
+// keepListenPort saves the port the engine picked when the settings ask
+// for a random one, so that later runs listen on the same port
+func keepListenPort(cfg *config.Manager, eng *engine.Engine) {
+	eng.Subscribe(func(name string, data ...interface{}) {
+		if name != "port-status" || len(data) == 0 {
+			return
+		}
+		status, ok := data[0].(engine.PortStatus)
+		if !ok || status.Configured != 0 || status.Port == 0 {
+			return
+		}
+
+		// Handlers must not block
+		go func() {
+			effective, err := cfg.Modify(func(s *config.Settings) {
+				if s.ListenPort == 0 {
+					s.ListenPort = status.Port
+				}
+			})
+			if err != nil {
+				log.Printf("⚠ Failed to save listen port %d: %v", status.Port, err)
+				return
+			}
+			eng.ApplySettings(effective)
+			log.Printf("✓ Saved random listen port %d", status.Port)
+		}()
+	})
+}
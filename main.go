@@ -3,21 +3,20 @@ package main
 import (
 	"context"
 	"embed"
-	"encoding/json"
+	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
-	"sync"
-	"time"
+	"slices"
+	"strings"
+
+	"torrentflow/api"
+	"torrentflow/config"
+	"torrentflow/engine"
 
-	"github.com/anacrolix/torrent"
-	"github.com/anacrolix/torrent/bencode"
-	"github.com/anacrolix/torrent/metainfo"
-	"github.com/anacrolix/torrent/storage"
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/options/assetserver"
@@ -27,85 +26,20 @@ import (
 //go:embed all:frontend/dist
 var assets embed.FS
 
-// TorrentInfo represents torrent information for the frontend
-type TorrentInfo struct {
-	ID            string     `json:"id"`
-	Name          string     `json:"name"`
-	InfoHash      string     `json:"infoHash"`
-	Size          int64      `json:"size"`
-	SizeStr       string     `json:"sizeStr"`
-	Progress      float64    `json:"progress"`
-	Status        string     `json:"status"`
-	DownloadSpeed int64      `json:"downloadSpeed"`
-	UploadSpeed   int64      `json:"uploadSpeed"`
-	DownloadedStr string     `json:"downloadSpeedStr"`
-	UploadedStr   string     `json:"uploadSpeedStr"`
-	Peers         int        `json:"peers"`
-	Seeds         int        `json:"seeds"`
-	ETA           string     `json:"eta"`
-	Files         []FileInfo `json:"files"`
-	AddedAt       time.Time  `json:"addedAt"`
-	IsPaused      bool       `json:"isPaused"`
-}
-
-// FileInfo represents file information within a torrent
-type FileInfo struct {
-	Name     string  `json:"name"`
-	Size     int64   `json:"size"`
-	SizeStr  string  `json:"sizeStr"`
-	Progress float64 `json:"progress"`
-	Path     string  `json:"path"`
-}
-
-// Stats represents global statistics
-type Stats struct {
-	TotalDownloadSpeed string `json:"totalDownload"`
-	TotalUploadSpeed   string `json:"totalUpload"`
-	ActiveTorrents     int    `json:"activeTorrents"`
-	TotalPeers         int    `json:"totalPeers"`
-}
-
-// speedTracker tracks download/upload speeds
-type speedTracker struct {
-	lastBytes int64
-	lastTime  time.Time
-	speed     int64
-}
-
-// TorrentState represents saved torrent state for persistence
-type TorrentState struct {
-	InfoHash  string    `json:"infoHash"`
-	MagnetURI string    `json:"magnetUri,omitempty"`
-	IsPaused  bool      `json:"isPaused"`
-	AddedAt   time.Time `json:"addedAt"`
-}
-
 // App struct
 type App struct {
 	ctx            context.Context
-	client         *torrent.Client
-	torrents       map[string]*torrent.Torrent
-	torrentsMutex  sync.RWMutex
+	config         *config.Manager
+	engine         *engine.Engine
+	apiServer      *api.Server
 	downloadDir    string
-	stateFile      string
-	downloadSpeeds map[string]*speedTracker
-	uploadSpeeds   map[string]*speedTracker
-	speedsMutex    sync.RWMutex
-	pausedTorrents map[string]bool
-	pausedMutex    sync.RWMutex
 	depositAddress string
-	lastUpdateHash string
-	lastUpdateTime time.Time
-	updateMutex    sync.Mutex
 }
 
 // NewApp creates a new App application struct
-func NewApp() *App {
+func NewApp(cfg *config.Manager) *App {
 	return &App{
-		torrents:       make(map[string]*torrent.Torrent),
-		downloadSpeeds: make(map[string]*speedTracker),
-		uploadSpeeds:   make(map[string]*speedTracker),
-		pausedTorrents: make(map[string]bool),
+		config: cfg,
 	}
 }
 
@@ -113,738 +47,395 @@ func NewApp() *App {
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 
-	// Setup download directory
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		log.Printf("Error getting home directory: %v", err)
-		homeDir = "."
-	}
-	a.downloadDir = filepath.Join(homeDir, "TorrentFlow", "Downloads")
-	a.stateFile = filepath.Join(homeDir, "TorrentFlow", "torrents.json")
-
-	// Create directory if it doesn't exist
-	if err := os.MkdirAll(a.downloadDir, 0755); err != nil {
-		log.Printf("Error creating download directory: %v", err)
-		wailsruntime.LogError(ctx, fmt.Sprintf("Failed to create download directory: %v", err))
-		return
-	}
+	settings := a.config.Settings()
+	a.downloadDir = settings.DownloadDir
+	a.engine = engine.New(settings)
 
-	// Configure torrent client
-	cfg := torrent.NewDefaultClientConfig()
-	cfg.DataDir = a.downloadDir
-	cfg.Seed = true
-	cfg.Debug = false
-	cfg.DisableIPv6 = false
-	cfg.NoDHT = false
-
-	// Try multiple ports if the default is in use
-	ports := []int{42069, 42070, 42071, 42072, 0} // 0 means random port
-	var client *torrent.Client
-	var lastErr error
-
-	for _, port := range ports {
-		cfg.ListenPort = port
-		client, lastErr = torrent.NewClient(cfg)
-		if lastErr == nil {
-			log.Printf("✓ Torrent client listening on port: %d", port)
-			break
-		}
-		log.Printf("⚠ Port %d unavailable: %v", port, lastErr)
-	}
+	// Forward engine events to the frontend
+	a.engine.Subscribe(func(name string, data ...interface{}) {
+		wailsruntime.EventsEmit(a.ctx, name, data...)
+	})
+	keepListenPort(a.config, a.engine)
 
-	if client == nil {
-		log.Printf("❌ Error creating torrent client after trying all ports: %v", lastErr)
-		wailsruntime.LogError(ctx, fmt.Sprintf("Failed to create torrent client: %v", lastErr))
+	if err := a.engine.Start(); err != nil {
+		wailsruntime.LogError(ctx, err.Error())
 		return
 	}
 
-	a.client = client
-
-	// Load saved torrents
-	a.loadSavedTorrents()
-
-	// Start stats update loop
-	go a.updateStatsLoop()
+	server, err := startRemoteAPI(a.engine, settings.API)
+	if err != nil {
+		log.Printf("❌ Failed to start remote API: %v", err)
+		wailsruntime.LogError(ctx, fmt.Sprintf("Failed to start remote API: %v", err))
+	}
+	a.apiServer = server
 
-	log.Printf("✓ Torrent client initialized successfully")
-	log.Printf("✓ Download folder: %s", a.downloadDir)
 	wailsruntime.LogInfo(ctx, fmt.Sprintf("Torrent client ready - Downloads: %s", a.downloadDir))
 }
 
 // shutdown is called when the app stops
 func (a *App) shutdown(ctx context.Context) {
-	// Save torrent states before closing
-	a.saveTorrentStates()
-
-	if a.client != nil {
-		log.Println("Closing torrent client...")
-		a.client.Close()
-		log.Println("✓ Torrent client closed")
-	}
-}
-
-// saveTorrentStates saves current torrent states to disk
-func (a *App) saveTorrentStates() {
-	a.torrentsMutex.RLock()
-	defer a.torrentsMutex.RUnlock()
-
-	var states []TorrentState
-	for hash, t := range a.torrents {
-		// Try to get magnet URI
-		var magnetURI string
-		if t.Info() != nil {
-			mi := metainfo.MetaInfo{
-				InfoBytes: bencode.MustMarshal(*t.Info()),
-			}
-			mag, _ := mi.MagnetV2()
-			magnetURI = mag.String()
-		}
-
-		a.pausedMutex.RLock()
-		isPaused := a.pausedTorrents[hash]
-		a.pausedMutex.RUnlock()
-
-		states = append(states, TorrentState{
-			InfoHash:  hash,
-			MagnetURI: magnetURI,
-			IsPaused:  isPaused,
-			AddedAt:   time.Now(),
-		})
-	}
-
-	data, err := json.MarshalIndent(states, "", "  ")
-	if err != nil {
-		log.Printf("Error marshaling torrent states: %v", err)
-		return
-	}
-
-	if err := os.WriteFile(a.stateFile, data, 0644); err != nil {
-		log.Printf("Error saving torrent states: %v", err)
-		return
+	if a.apiServer != nil {
+		a.apiServer.Close()
 	}
-
-	log.Printf("✓ Saved %d torrent states", len(states))
-}
-
-// loadSavedTorrents loads previously saved torrents
-func (a *App) loadSavedTorrents() {
-	data, err := os.ReadFile(a.stateFile)
-	if err != nil {
-		if !os.IsNotExist(err) {
-			log.Printf("Error reading torrent states: %v", err)
-		}
-		return
-	}
-
-	var states []TorrentState
-	if err := json.Unmarshal(data, &states); err != nil {
-		log.Printf("Error unmarshaling torrent states: %v", err)
-		return
-	}
-
-	log.Printf("Loading %d saved torrents...", len(states))
-	for _, state := range states {
-		if state.MagnetURI != "" {
-			t, err := a.client.AddMagnet(state.MagnetURI)
-			if err != nil {
-				log.Printf("Error re-adding torrent %s: %v", state.InfoHash, err)
-				continue
-			}
-
-			hash := t.InfoHash().String()
-
-			// Initialize trackers
-			a.speedsMutex.Lock()
-			a.downloadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
-			a.uploadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
-			a.speedsMutex.Unlock()
-
-			a.torrentsMutex.Lock()
-			a.torrents[hash] = t
-			a.torrentsMutex.Unlock()
-
-			// Restore paused state
-			if state.IsPaused {
-				a.pausedMutex.Lock()
-				a.pausedTorrents[hash] = true
-				a.pausedMutex.Unlock()
-			} else {
-				// Wait for info and start download
-				go func(torr *torrent.Torrent) {
-					<-torr.GotInfo()
-					torr.DownloadAll()
-				}(t)
-			}
-
-			log.Printf("✓ Restored torrent: %s (paused: %v)", hash, state.IsPaused)
-		}
+	if a.engine != nil {
+		a.engine.Close()
 	}
 }
 
+// AddMagnet adds a torrent from a magnet link
 func (a *App) AddMagnet(magnetURI string) error {
-	if a.client == nil {
-		return fmt.Errorf("torrent client not initialized")
-	}
-
-	log.Printf("Adding magnet link...")
-	t, err := a.client.AddMagnet(magnetURI)
-	if err != nil {
-		log.Printf("❌ Failed to add magnet: %v", err)
-		return fmt.Errorf("failed to add magnet: %w", err)
-	}
-
-	hash := t.InfoHash().String()
-	log.Printf("✓ Magnet added with hash: %s", hash)
-
-	// Initialize trackers
-	a.speedsMutex.Lock()
-	a.downloadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
-	a.uploadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
-	a.speedsMutex.Unlock()
-
-	a.torrentsMutex.Lock()
-	a.torrents[hash] = t
-	a.torrentsMutex.Unlock()
-
-	log.Printf("Waiting for metadata...")
-
-	// Emit immediately so UI shows the torrent in "loading" state
-	wailsruntime.EventsEmit(a.ctx, "torrent-added", hash)
-
-	// Wait for metadata
-	go func() {
-		select {
-		case <-t.GotInfo():
-			log.Printf("✓ Got metadata: %s", t.Name())
-			log.Printf("   Size: %s", formatBytes(t.Length()))
-			log.Printf("   Files: %d", len(t.Files()))
-
-			// Start downloading
-			t.DownloadAll()
-			t.AllowDataDownload()
-			t.AllowDataUpload()
-
-			log.Printf("✓ Started downloading: %s", t.Name())
-
-			// Save and notify UI
-			a.saveTorrentStates()
-			wailsruntime.EventsEmit(a.ctx, "torrent-updated", hash)
-
-		case <-time.After(120 * time.Second):
-			log.Printf("⚠ Timeout waiting for metadata for hash: %s", hash)
-			log.Printf("   Torrent may have no peers or slow DHT")
-
-			// Still allow connections
-			t.AllowDataDownload()
-			t.AllowDataUpload()
-
-			// Continue waiting in background
-			go func() {
-				log.Printf("🔄 Continuing to wait for metadata...")
-				<-t.GotInfo()
-				log.Printf("✓ Finally got metadata: %s", t.Name())
-				t.DownloadAll()
-				a.saveTorrentStates()
-				wailsruntime.EventsEmit(a.ctx, "torrent-updated", hash)
-			}()
-
-			// Notify UI of timeout
-			wailsruntime.EventsEmit(a.ctx, "torrent-updated", hash)
-			wailsruntime.LogWarning(a.ctx, "Metadata fetch is taking longer than expected")
-		}
-	}()
+	_, err := a.engine.AddMagnet(magnetURI, engine.AddOptions{})
+	return err
+}
 
-	return nil
+// AddMagnetTo adds a torrent from a magnet link that stores its data in
+// savePath instead of the download folder
+func (a *App) AddMagnetTo(magnetURI, savePath string) error {
+	_, err := a.engine.AddMagnet(magnetURI, engine.AddOptions{SavePath: savePath})
+	return err
 }
 
 // AddTorrentFile adds a torrent from a file
 func (a *App) AddTorrentFile(filePath string) error {
-	if a.client == nil {
-		return fmt.Errorf("torrent client not initialized")
-	}
-
-	mi, err := metainfo.LoadFromFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to load torrent file: %w", err)
-	}
-
-	t, err := a.client.AddTorrent(mi)
-	if err != nil {
-		return fmt.Errorf("failed to add torrent: %w", err)
-	}
-
-	hash := t.InfoHash().String()
+	_, err := a.engine.AddTorrentFile(filePath, engine.AddOptions{})
+	return err
+}
 
-	// Initialize speed trackers
-	a.speedsMutex.Lock()
-	a.downloadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
-	a.uploadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
-	a.speedsMutex.Unlock()
+// AddTorrentFileTo adds a torrent from a file that stores its data in
+// savePath instead of the download folder
+func (a *App) AddTorrentFileTo(filePath, savePath string) error {
+	_, err := a.engine.AddTorrentFile(filePath, engine.AddOptions{SavePath: savePath})
+	return err
+}
 
-	t.DownloadAll()
+// PreviewMagnet starts fetching a magnet's metadata without downloading.
+// The "preview-ready" event carries the preview once it is ready.
+func (a *App) PreviewMagnet(magnetURI string) (engine.TorrentPreview, error) {
+	return a.engine.PreviewMagnet(magnetURI)
+}
 
-	a.torrentsMutex.Lock()
-	a.torrents[hash] = t
-	a.torrentsMutex.Unlock()
+// PreviewTorrentFile reads a .torrent file without adding it
+func (a *App) PreviewTorrentFile(filePath string) (engine.TorrentPreview, error) {
+	return a.engine.PreviewTorrentFile(filePath)
+}
 
-	a.saveTorrentStates()
+// GetPreview returns a pending preview
+func (a *App) GetPreview(id string) (engine.TorrentPreview, error) {
+	return a.engine.GetPreview(id)
+}
 
-	log.Printf("✓ Added torrent file: %s", t.Name())
-	wailsruntime.EventsEmit(a.ctx, "torrent-added", hash)
+// CommitPreview adds a previewed torrent with the chosen files, save
+// path, category and tags and returns its info hash
+func (a *App) CommitPreview(id string, opts engine.AddOptions) (string, error) {
+	return a.engine.CommitPreview(id, opts)
+}
 
-	return nil
+// CancelPreview drops a pending preview
+func (a *App) CancelPreview(id string) error {
+	return a.engine.CancelPreview(id)
 }
 
 // CreateTorrentFromFiles creates a torrent from local files and starts seeding
 func (a *App) CreateTorrentFromFiles(files []string) (string, error) {
-	log.Printf("🚀 CreateTorrentFromFiles CALLED with %d files", len(files))
-	log.Printf("   Files: %v", files)
-
-	if a.client == nil {
-		log.Printf("❌ Client is nil!")
-		return "", fmt.Errorf("torrent client not initialized")
-	}
+	return a.engine.CreateTorrentFromFiles(files)
+}
 
-	if len(files) == 0 {
-		return "", fmt.Errorf("no files provided")
-	}
+// GetTorrents returns all torrents
+func (a *App) GetTorrents() []engine.TorrentInfo {
+	return a.engine.GetTorrents()
+}
 
-	log.Printf("Creating torrent from %d file(s)...", len(files))
-
-	// Determine the root path for building torrent
-	var rootPath string
-	var isSingleFile bool
-
-	if len(files) == 1 {
-		// Single file
-		rootPath = files[0]
-		isSingleFile = true
-		log.Printf("✓ Single file mode: %s", rootPath)
-	} else {
-		// Multiple files - must be in same directory
-		parentDir := filepath.Dir(files[0])
-		for _, f := range files[1:] {
-			if filepath.Dir(f) != parentDir {
-				return "", fmt.Errorf("all files must be in the same directory")
-			}
-		}
-		rootPath = parentDir
-		isSingleFile = false
-		log.Printf("✓ Multiple files mode: %s", parentDir)
-	}
+// GetTorrent returns a single torrent by hash
+func (a *App) GetTorrent(infoHash string) (engine.TorrentInfo, error) {
+	return a.engine.GetTorrent(infoHash)
+}
 
-	log.Printf("🔍 Root path: %s", rootPath)
+// PauseTorrent pauses a torrent
+func (a *App) PauseTorrent(infoHash string) error {
+	return a.engine.PauseTorrent(infoHash)
+}
 
-	// Build metainfo
-	log.Printf("🔍 Creating metainfo...")
-	info := metainfo.Info{
-		PieceLength: 256 * 1024, // 256 KB pieces
-	}
+// ResumeTorrent resumes a torrent
+func (a *App) ResumeTorrent(infoHash string) error {
+	return a.engine.ResumeTorrent(infoHash)
+}
 
-	// Build from file path
-	log.Printf("🔍 Building from file path...")
-	if err := info.BuildFromFilePath(rootPath); err != nil {
-		log.Printf("❌ Failed to build from file path: %v", err)
-		return "", fmt.Errorf("failed to build torrent info: %w", err)
-	}
-	log.Printf("✓ Built from file path")
-
-	// For single file, ensure the path is set correctly
-	if isSingleFile {
-		info.Files = []metainfo.FileInfo{{
-			Path:   []string{},
-			Length: info.TotalLength(),
-		}}
-	}
+// PauseAll pauses every torrent
+func (a *App) PauseAll() {
+	a.engine.PauseAll()
+}
 
-	log.Printf("Generating pieces for torrent...")
+// ResumeAll resumes every paused torrent
+func (a *App) ResumeAll() error {
+	return a.engine.ResumeAll()
+}
 
-	// Generate pieces (hash the data)
-	err := info.GeneratePieces(func(fi metainfo.FileInfo) (io.ReadCloser, error) {
-		var fullPath string
-		if isSingleFile {
-			fullPath = rootPath
-		} else {
-			fullPath = filepath.Join(rootPath, filepath.Join(fi.Path...))
-		}
-		log.Printf("🔍 Reading file for hashing: %s", fullPath)
-		return os.Open(fullPath)
-	})
-	if err != nil {
-		log.Printf("❌ Failed to generate pieces: %v", err)
-		return "", fmt.Errorf("failed to generate pieces: %w", err)
-	}
+// RecheckTorrent verifies a torrent's data on disk. Progress is sent with
+// "recheck-progress" events and the report with "recheck-finished".
+func (a *App) RecheckTorrent(infoHash string) error {
+	return a.engine.RecheckTorrent(infoHash)
+}
 
-	log.Printf("✓ Torrent info generated, size: %d bytes", info.TotalLength())
+// GetRecheckReport returns the report of a torrent's last recheck
+func (a *App) GetRecheckReport(infoHash string) (engine.RecheckReport, error) {
+	return a.engine.GetRecheckReport(infoHash)
+}
 
-	// Create metainfo with trackers
-	mi := metainfo.MetaInfo{
-		AnnounceList: [][]string{
-			{"udp://tracker.openbittorrent.com:6969/announce"},
-			{"udp://tracker.opentrackr.org:1337/announce"},
-			{"udp://open.stealth.si:80/announce"},
-			{"udp://tracker.torrent.eu.org:451/announce"},
-			{"udp://explodie.org:6969/announce"},
-		},
-		InfoBytes: bencode.MustMarshal(info),
-	}
-	mi.SetDefaults()
+// GetPieceMap returns the run-length encoded piece states and
+// availability of a torrent for the piece bar
+func (a *App) GetPieceMap(infoHash string) (engine.PieceMap, error) {
+	return a.engine.GetPieceMap(infoHash)
+}
 
-	// Generate magnet link
-	magnet, err := mi.MagnetV2()
-	if err != nil {
-		return "", fmt.Errorf("failed to generate magnet link: %w", err)
-	}
-	magnetStr := magnet.String()
+// GetPeers returns the peers connected to a torrent with their client,
+// connection flags, progress and speeds
+func (a *App) GetPeers(infoHash string) ([]engine.PeerInfo, error) {
+	return a.engine.GetPeers(infoHash)
+}
 
-	hash := mi.HashInfoBytes().String()
-	log.Printf("✓ Generated torrent with hash: %s", hash)
+// AddPeer connects a torrent to a peer given as ip:port
+func (a *App) AddPeer(infoHash, addr string) error {
+	return a.engine.AddPeer(infoHash, addr)
+}
 
-	// Determine storage base directory
-	storageBaseDir := rootPath
+// KickPeer disconnects the peer at ip:port from a torrent
+func (a *App) KickPeer(infoHash, addr string) error {
+	return a.engine.KickPeer(infoHash, addr)
+}
 
-	log.Printf("🔍 Storage base directory: %s", storageBaseDir)
+// GetBannedPeers returns the banned IPs and ranges
+func (a *App) GetBannedPeers() []string {
+	return a.config.Settings().BannedPeers
+}
 
-	// Create piece completion in the appropriate directory
-	var pcDir string
-	if isSingleFile {
-		pcDir = filepath.Dir(rootPath)
-	} else {
-		pcDir = rootPath
-	}
-	pc, err := storage.NewDefaultPieceCompletionForDir(pcDir)
-	if err != nil {
-		log.Printf("❌ Failed to create piece completion: %v", err)
-		return "", fmt.Errorf("failed to create piece completion: %w", err)
+// BanPeer bans an IP, a CIDR prefix or a "first-last" range for every
+// torrent and disconnects the peers it covers. The ban is saved.
+func (a *App) BanPeer(ban string) error {
+	ban = strings.TrimSpace(ban)
+	if _, _, err := config.ParseIPRange(ban); err != nil {
+		return err
 	}
-
-	// Add torrent with custom storage
-	t, isNew := a.client.AddTorrentOpt(torrent.AddTorrentOpts{
-		InfoHash: mi.HashInfoBytes(),
-		Storage: storage.NewFileOpts(storage.NewFileClientOpts{
-			ClientBaseDir: storageBaseDir,
-			FilePathMaker: func(opts storage.FilePathMakerOpts) string {
-				// Return the path structure as-is from the metainfo
-				return filepath.Join(opts.File.Path...)
-			},
-			TorrentDirMaker: nil,
-			PieceCompletion: pc,
-		}),
+	effective, err := a.config.Modify(func(s *config.Settings) {
+		if !slices.Contains(s.BannedPeers, ban) {
+			s.BannedPeers = append(s.BannedPeers, ban)
+		}
 	})
-
-	if !isNew {
-		log.Printf("⚠ Torrent already exists, using existing instance")
+	if err != nil {
+		return err
 	}
+	a.engine.ApplySettings(effective)
+	log.Printf("🚫 Banned %s", ban)
+	return nil
+}
 
-	// Merge the metadata
-	err = t.MergeSpec(&torrent.TorrentSpec{
-		InfoBytes: mi.InfoBytes,
-		Trackers:  mi.AnnounceList,
+// UnbanPeer lifts a ban given exactly as it is listed
+func (a *App) UnbanPeer(ban string) error {
+	effective, err := a.config.Modify(func(s *config.Settings) {
+		s.BannedPeers = slices.DeleteFunc(s.BannedPeers, func(b string) bool {
+			return b == ban
+		})
 	})
 	if err != nil {
-		log.Printf("❌ Failed to merge spec: %v", err)
-		return "", fmt.Errorf("failed to merge torrent spec: %w", err)
+		return err
 	}
+	a.engine.ApplySettings(effective)
+	return nil
+}
 
-	log.Printf("✓ Added torrent with hash: %s", hash)
-
-	// Wait for info
-	<-t.GotInfo()
-	log.Printf("✓ Got torrent info: %s", t.Name())
-
-	// DEBUG: Log expected file paths
-	for _, file := range t.Files() {
-		var expectedPath string
-		if isSingleFile {
-			expectedPath = storageBaseDir
-		} else {
-			expectedPath = filepath.Join(storageBaseDir, file.Path())
-		}
-		log.Printf("🔍 Torrent expects file at: %s", expectedPath)
+// MoveTorrentStorage moves a torrent's files to newDir. Progress is sent
+// with "storage-move-progress" events and "storage-moved" when done, plus
+// "torrent-error" if the torrent could not be restarted afterwards.
+func (a *App) MoveTorrentStorage(infoHash, newDir string) error {
+	return a.engine.MoveTorrentStorage(infoHash, newDir)
+}
 
-		if stat, err := os.Stat(expectedPath); err != nil {
-			log.Printf("❌ File NOT found: %s", err)
-		} else {
-			log.Printf("✓ File exists: size=%d", stat.Size())
-		}
-	}
+// SelectDirectory opens a folder picker, e.g. for a torrent's save path
+func (a *App) SelectDirectory() (string, error) {
+	return wailsruntime.OpenDirectoryDialog(a.ctx, wailsruntime.OpenDialogOptions{
+		Title:            "Select Folder",
+		DefaultDirectory: a.downloadDir,
+	})
+}
 
-	// Tell the torrent to download all pieces
-	t.Seeding()
-	t.AllowDataUpload()
-	t.AllowDataDownload()
-	t.VerifyData()
-
-	log.Printf("Started verification of existing files")
-
-	// Initialize speed trackers
-	a.speedsMutex.Lock()
-	a.downloadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
-	a.uploadSpeeds[hash] = &speedTracker{lastTime: time.Now()}
-	a.speedsMutex.Unlock()
-
-	// Add to torrents map
-	a.torrentsMutex.Lock()
-	a.torrents[hash] = t
-	a.torrentsMutex.Unlock()
-
-	// Start seeding process
-	t.AllowDataUpload()
-	t.AllowDataDownload()
-
-	// Verify the data is there
-	log.Printf("Starting data verification...")
-	t.VerifyData()
-
-	// Wait for verification in background
-	go func() {
-		time.Sleep(500 * time.Millisecond)
-
-		ticker := time.NewTicker(500 * time.Millisecond)
-		defer ticker.Stop()
-
-		timeout := time.After(60 * time.Second)
-		lastCompleted := int64(0)
-
-		for {
-			select {
-			case <-ticker.C:
-				completed := t.BytesCompleted()
-				total := t.Length()
-
-				if completed != lastCompleted {
-					percentage := float64(completed) / float64(total) * 100
-					log.Printf("⏳ Verifying: %.1f%% (%d/%d bytes)", percentage, completed, total)
-					lastCompleted = completed
-				}
-
-				if completed >= total {
-					log.Printf("✓ File verification complete: 100%%")
-					log.Printf("✓ Now seeding torrent: %s", t.Name())
-
-					wailsruntime.EventsEmit(a.ctx, "torrent-added", hash)
-					a.saveTorrentStates()
-					return
-				}
-
-			case <-timeout:
-				completed := t.BytesCompleted()
-				total := t.Length()
-				percentage := float64(completed) / float64(total) * 100
-
-				if completed == 0 {
-					log.Printf("❌ Verification failed: 0%% complete")
-					log.Printf("❌ Files may not be at expected location")
-				} else if completed < total {
-					log.Printf("⚠ Verification incomplete: %.1f%% (%d/%d bytes)", percentage, completed, total)
-				} else {
-					log.Printf("✓ Verification complete: 100%%")
-				}
-
-				wailsruntime.EventsEmit(a.ctx, "torrent-added", hash)
-				a.saveTorrentStates()
-				return
-			}
-		}
-	}()
+// RemoveTorrent removes a torrent
+func (a *App) RemoveTorrent(infoHash string, deleteFiles bool) error {
+	return a.engine.RemoveTorrent(infoHash, deleteFiles)
+}
 
-	log.Printf("✓ Created torrent: %s", t.Name())
-	log.Printf("✓ Magnet link: %s", magnetStr)
+// RemoveTorrentWithOptions removes a torrent, deleting its files or moving
+// them to the trash, and reports what happened to each file
+func (a *App) RemoveTorrentWithOptions(infoHash string, opts engine.RemoveOptions) (engine.RemoveReport, error) {
+	return a.engine.RemoveTorrentWithOptions(infoHash, opts)
+}
 
-	return magnetStr, nil
+// GetStats returns global statistics
+func (a *App) GetStats() engine.Stats {
+	return a.engine.GetStats()
 }
 
-// GetTorrents returns all torrents
-func (a *App) GetTorrents() []TorrentInfo {
-	a.torrentsMutex.RLock()
-	defer a.torrentsMutex.RUnlock()
-
-	var torrents []TorrentInfo
-	for hash, t := range a.torrents {
-		info := a.getTorrentInfo(hash, t)
-		torrents = append(torrents, info)
-	}
+// SetTorrentLimits sets per-torrent speed limits in bytes per second,
+// 0 meaning only the global limits apply
+func (a *App) SetTorrentLimits(infoHash string, downloadLimit, uploadLimit int64) error {
+	return a.engine.SetTorrentLimits(infoHash, downloadLimit, uploadLimit)
+}
 
-	return torrents
+// SetTorrentSeedGoals gives a torrent its own seeding goals, or makes it
+// follow the global goals again when goals is nil
+func (a *App) SetTorrentSeedGoals(infoHash string, goals *config.SeedGoals) error {
+	return a.engine.SetTorrentSeedGoals(infoHash, goals)
 }
 
-// GetTorrent returns a single torrent by hash
-func (a *App) GetTorrent(infoHash string) (TorrentInfo, error) {
-	a.torrentsMutex.RLock()
-	defer a.torrentsMutex.RUnlock()
+// SetFilePriority sets a file's priority to "skip", "low", "normal" or
+// "high". Files are identified by their index in the torrent.
+func (a *App) SetFilePriority(infoHash string, index int, priority string) error {
+	return a.engine.SetFilePriority(infoHash, index, priority)
+}
 
-	t, exists := a.torrents[infoHash]
-	if !exists {
-		return TorrentInfo{}, fmt.Errorf("torrent not found")
-	}
+// SelectFiles downloads only the files at the given indices
+func (a *App) SelectFiles(infoHash string, indices []int) error {
+	return a.engine.SelectFiles(infoHash, indices)
+}
 
-	return a.getTorrentInfo(infoHash, t), nil
+// MoveInQueue moves a torrent "up", "down", to the "top" or to the
+// "bottom" of the download queue
+func (a *App) MoveInQueue(infoHash, move string) error {
+	return a.engine.MoveInQueue(infoHash, move)
 }
 
-// PauseTorrent pauses a torrent
-func (a *App) PauseTorrent(infoHash string) error {
-	a.torrentsMutex.RLock()
-	t, exists := a.torrents[infoHash]
-	a.torrentsMutex.RUnlock()
+// GetSchedule returns the speed profile the bandwidth schedule applies
+func (a *App) GetSchedule() engine.ScheduleState {
+	return a.engine.Schedule()
+}
 
-	if !exists {
-		return fmt.Errorf("torrent not found")
+// SetAltSpeedEnabled switches between the normal and the alternative
+// ("turtle") speed limits and remembers the choice
+func (a *App) SetAltSpeedEnabled(enabled bool) error {
+	effective, err := a.config.Modify(func(s *config.Settings) {
+		s.Bandwidth.AltEnabled = enabled
+	})
+	if err != nil {
+		return err
 	}
-
-	// Cancel all pieces to stop downloading
-	t.CancelPieces(0, t.NumPieces())
-
-	// Mark as paused
-	a.pausedMutex.Lock()
-	a.pausedTorrents[infoHash] = true
-	a.pausedMutex.Unlock()
-
-	a.saveTorrentStates()
-
-	log.Printf("⏸ Paused torrent: %s", t.Name())
+	a.engine.ApplySettings(effective)
 	return nil
 }
 
-// ResumeTorrent resumes a torrent
-func (a *App) ResumeTorrent(infoHash string) error {
-	a.torrentsMutex.RLock()
-	t, exists := a.torrents[infoHash]
-	a.torrentsMutex.RUnlock()
-
-	if !exists {
-		return fmt.Errorf("torrent not found")
-	}
-
-	// Start downloading all pieces
-	t.DownloadAll()
-
-	// Mark as not paused
-	a.pausedMutex.Lock()
-	delete(a.pausedTorrents, infoHash)
-	a.pausedMutex.Unlock()
-
-	a.saveTorrentStates()
-
-	log.Printf("▶ Resumed torrent: %s", t.Name())
-	return nil
+// GetBlocklists returns the IP filter files in use with the number of
+// connection attempts each refused
+func (a *App) GetBlocklists() []engine.BlocklistInfo {
+	return a.engine.GetBlocklists()
 }
 
-// RemoveTorrent removes a torrent
-func (a *App) RemoveTorrent(infoHash string, deleteFiles bool) error {
-	log.Printf("🔍 RemoveTorrent called - InfoHash: %s, DeleteFiles: %t", infoHash, deleteFiles)
-
-	a.torrentsMutex.Lock()
-	t, exists := a.torrents[infoHash]
-	if !exists {
-		a.torrentsMutex.Unlock()
-		log.Printf("❌ Torrent not found: %s", infoHash)
-		return fmt.Errorf("torrent not found")
+// AddBlocklist starts using an IP filter file in eMule DAT, PeerGuardian
+// P2P or CIDR format. The file is reloaded whenever it changes.
+func (a *App) AddBlocklist(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
 	}
-	delete(a.torrents, infoHash)
-	a.torrentsMutex.Unlock()
-
-	log.Printf("✓ Torrent removed from map: %s", infoHash)
-
-	torrentName := t.Name()
-	if torrentName == "" {
-		torrentName = infoHash
+	if _, err := os.Stat(path); err != nil {
+		return err
 	}
-
-	// Clean up speed trackers
-	a.speedsMutex.Lock()
-	delete(a.downloadSpeeds, infoHash)
-	delete(a.uploadSpeeds, infoHash)
-	a.speedsMutex.Unlock()
-
-	// Clean up paused state
-	a.pausedMutex.Lock()
-	delete(a.pausedTorrents, infoHash)
-	a.pausedMutex.Unlock()
-
-	// Store file paths before dropping if we need to delete
-	var filePaths []string
-	if deleteFiles && t.Info() != nil {
-		for _, file := range t.Files() {
-			path := filepath.Join(a.downloadDir, file.Path())
-			filePaths = append(filePaths, path)
-			log.Printf("📁 File to delete: %s", path)
+	effective, err := a.config.Modify(func(s *config.Settings) {
+		if !slices.Contains(s.Blocklists, path) {
+			s.Blocklists = append(s.Blocklists, path)
 		}
+	})
+	if err != nil {
+		return err
 	}
+	a.engine.ApplySettings(effective)
+	return nil
+}
 
-	// Drop torrent from client
-	t.Drop()
-	log.Printf("✓ Torrent dropped from client")
-
-	// Delete files after dropping torrent
-	if deleteFiles && len(filePaths) > 0 {
-		for _, path := range filePaths {
-			if err := os.Remove(path); err != nil {
-				log.Printf("⚠️ Warning: failed to delete file %s: %v", path, err)
-			} else {
-				log.Printf("✓ Deleted file: %s", path)
-			}
-		}
-
-		// Try to remove empty parent directories
-		if len(filePaths) > 0 {
-			parentDir := filepath.Dir(filePaths[0])
-			if parentDir != a.downloadDir {
-				if err := os.Remove(parentDir); err != nil {
-					log.Printf("⚠️ Could not remove directory %s: %v", parentDir, err)
-				}
-			}
-		}
-
-		log.Printf("🗑 Removed torrent and deleted files: %s", torrentName)
-	} else {
-		log.Printf("🗑 Removed torrent: %s", torrentName)
+// RemoveBlocklist stops using an IP filter file
+func (a *App) RemoveBlocklist(path string) error {
+	effective, err := a.config.Modify(func(s *config.Settings) {
+		s.Blocklists = slices.DeleteFunc(s.Blocklists, func(p string) bool {
+			return p == path
+		})
+	})
+	if err != nil {
+		return err
 	}
-
-	a.saveTorrentStates()
-	log.Printf("✓ Torrent states saved")
-
+	a.engine.ApplySettings(effective)
 	return nil
 }
 
-// GetStats returns global statistics
-func (a *App) GetStats() Stats {
-	a.torrentsMutex.RLock()
-	defer a.torrentsMutex.RUnlock()
-
-	var totalDown, totalUp int64
-	var activeTorrents, totalPeers int
+// SelectBlocklistFile opens file picker for IP filter files
+func (a *App) SelectBlocklistFile() (string, error) {
+	return wailsruntime.OpenFileDialog(a.ctx, wailsruntime.OpenDialogOptions{
+		Title: "Select IP Blocklist",
+		Filters: []wailsruntime.FileFilter{
+			{
+				DisplayName: "IP Filter Lists (*.dat, *.p2p, *.txt, *.gz)",
+				Pattern:     "*.dat;*.p2p;*.txt;*.gz",
+			},
+		},
+	})
+}
 
-	a.speedsMutex.RLock()
-	for hash := range a.torrents {
-		if tracker, ok := a.downloadSpeeds[hash]; ok {
-			totalDown += tracker.speed
-		}
-		if tracker, ok := a.uploadSpeeds[hash]; ok {
-			totalUp += tracker.speed
-		}
-	}
-	a.speedsMutex.RUnlock()
+// GetNetworkState returns the state of the interface torrent traffic is
+// bound to
+func (a *App) GetNetworkState() engine.NetworkState {
+	return a.engine.GetNetworkState()
+}
 
-	for hash, t := range a.torrents {
-		stats := t.Stats()
+// GetNetworkInterfaces lists the interfaces torrent traffic can be bound to
+func (a *App) GetNetworkInterfaces() ([]engine.NetworkInterface, error) {
+	return engine.NetworkInterfaces()
+}
 
-		a.pausedMutex.RLock()
-		isPaused := a.pausedTorrents[hash]
-		a.pausedMutex.RUnlock()
+// SetBindInterface pins torrent traffic to an interface name or local IP
+// address, or unbinds it when empty. Torrents pause while the interface is
+// down.
+func (a *App) SetBindInterface(name string) error {
+	effective, err := a.config.Modify(func(s *config.Settings) {
+		s.BindInterface = strings.TrimSpace(name)
+	})
+	if err != nil {
+		return err
+	}
+	a.engine.ApplySettings(effective)
+	return nil
+}
 
-		if !isPaused && t.BytesCompleted() < t.Length() {
-			activeTorrents++
-		}
+// GetPortStatus returns the listen port, how it is forwarded on the
+// router and the address peers on the internet reach it at
+func (a *App) GetPortStatus() engine.PortStatus {
+	return a.engine.GetPortStatus()
+}
 
-		totalPeers += stats.ActivePeers
+// SetListenPort moves the client to a new port without losing torrents.
+// 0 picks a random port, which is kept across runs.
+func (a *App) SetListenPort(port int) error {
+	effective, err := a.config.Modify(func(s *config.Settings) {
+		s.ListenPort = port
+	})
+	if err != nil {
+		return err
 	}
+	a.engine.ApplySettings(effective)
+	return nil
+}
 
-	return Stats{
-		TotalDownloadSpeed: formatSpeed(totalDown),
-		TotalUploadSpeed:   formatSpeed(totalUp),
-		ActiveTorrents:     activeTorrents,
-		TotalPeers:         totalPeers,
+// SetPortForwarding turns forwarding the listen port with UPnP and
+// NAT-PMP on or off
+func (a *App) SetPortForwarding(enabled bool) error {
+	effective, err := a.config.Modify(func(s *config.Settings) {
+		s.PortForwarding = enabled
+	})
+	if err != nil {
+		return err
 	}
+	a.engine.ApplySettings(effective)
+	return nil
+}
+
+// TestPort checks whether the listen port can be reached from the
+// internet
+func (a *App) TestPort() (engine.PortTest, error) {
+	return a.engine.TestPort()
 }
 
 // OpenDownloadFolder opens the download folder
@@ -895,222 +486,39 @@ func (a *App) SelectLocalFiles() ([]string, error) {
 	return files, err
 }
 
-// Helper functions
-
-func (a *App) getTorrentInfo(hash string, t *torrent.Torrent) TorrentInfo {
-	stats := t.Stats()
-
-	// Check if paused
-	a.pausedMutex.RLock()
-	isPaused := a.pausedTorrents[hash]
-	a.pausedMutex.RUnlock()
-
-	// Determine status
-	status := a.getTorrentStatus(t, stats, isPaused)
-
-	// Calculate progress
-	progress := 0.0
-	if t.Length() > 0 {
-		progress = float64(t.BytesCompleted()) / float64(t.Length()) * 100
-	}
-
-	// Get files info
-	var files []FileInfo
-	if t.Info() != nil {
-		for _, file := range t.Files() {
-			fileProgress := 0.0
-			if file.Length() > 0 {
-				fileProgress = float64(file.BytesCompleted()) / float64(file.Length()) * 100
-			}
-
-			files = append(files, FileInfo{
-				Name:     file.DisplayPath(),
-				Size:     file.Length(),
-				SizeStr:  formatBytes(file.Length()),
-				Progress: fileProgress,
-				Path:     file.Path(),
-			})
-		}
-	}
-
-	// Get speed from tracker
-	var downloadSpeed, uploadSpeed int64
-	a.speedsMutex.RLock()
-	if tracker, ok := a.downloadSpeeds[hash]; ok {
-		downloadSpeed = tracker.speed
-	}
-	if tracker, ok := a.uploadSpeeds[hash]; ok {
-		uploadSpeed = tracker.speed
-	}
-	a.speedsMutex.RUnlock()
-
-	// Calculate ETA
-	eta := "Unknown"
-	if downloadSpeed > 0 && t.BytesCompleted() < t.Length() {
-		remaining := t.Length() - t.BytesCompleted()
-		seconds := remaining / downloadSpeed
-		eta = formatDuration(time.Duration(seconds) * time.Second)
-	}
-
-	// Get torrent name
-	name := t.Name()
-	if name == "" {
-		name = "Loading metadata..."
-	}
-
-	return TorrentInfo{
-		ID:            hash,
-		Name:          name,
-		InfoHash:      hash,
-		Size:          t.Length(),
-		SizeStr:       formatBytes(t.Length()),
-		Progress:      progress,
-		Status:        status,
-		DownloadSpeed: downloadSpeed,
-		UploadSpeed:   uploadSpeed,
-		DownloadedStr: formatSpeed(downloadSpeed),
-		UploadedStr:   formatSpeed(uploadSpeed),
-		Peers:         stats.ActivePeers,
-		Seeds:         stats.ConnectedSeeders,
-		ETA:           eta,
-		Files:         files,
-		AddedAt:       time.Now(),
-		IsPaused:      isPaused,
-	}
+// GetSettings returns the current settings with the API token and proxy
+// password redacted
+func (a *App) GetSettings() config.Settings {
+	return a.config.Settings().Redacted()
 }
 
-func (a *App) getTorrentStatus(t *torrent.Torrent, stats torrent.TorrentStats, isPaused bool) string {
-	if isPaused {
-		return "paused"
-	}
-
-	// Metadata not fetched yet
-	if t.Info() == nil || t.Length() == 0 {
-		return "loading"
-	}
-
-	bytesCompleted := t.BytesCompleted()
-	totalLength := t.Length()
-
-	// Check if download is complete
-	isComplete := bytesCompleted >= totalLength
-
-	if isComplete {
-		// If actively uploading to peers
-		if stats.ActivePeers > 0 {
-			return "seeding"
-		}
-
-		// Completed but no one downloading from us
-		return "completed"
-	}
-
-	// Still downloading
-	if stats.ActivePeers > 0 {
-		return "downloading"
+// UpdateSettings validates and saves new settings and applies what can be
+// changed while running. Settings that need a restart are listed in the
+// result. Secrets left redacted keep their values.
+func (a *App) UpdateSettings(settings config.Settings) (config.UpdateResult, error) {
+	old := a.config.Settings()
+	effective, err := a.config.Update(settings)
+	if err != nil {
+		return config.UpdateResult{}, err
 	}
 
-	// Has potential peers but not connected/downloading
-	if stats.TotalPeers > 0 {
-		return "stalled"
+	result := config.UpdateResult{
+		Settings:        effective.Redacted(),
+		RestartRequired: a.engine.ApplySettings(effective),
 	}
 
-	// No peers at all - looking for peers
-	return "stalled"
-}
-
-func (a *App) updateStatsLoop() {
-	ticker := time.NewTicker(1 * time.Second)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		// Update speed trackers
-		a.torrentsMutex.RLock()
-		for hash, t := range a.torrents {
-			stats := t.Stats()
-			now := time.Now()
-
-			a.speedsMutex.Lock()
-			if tracker, ok := a.downloadSpeeds[hash]; ok {
-				elapsed := now.Sub(tracker.lastTime).Seconds()
-				if elapsed > 0 {
-					currentBytes := stats.BytesReadData.Int64()
-					bytesDiff := currentBytes - tracker.lastBytes
-					tracker.speed = int64(float64(bytesDiff) / elapsed)
-					tracker.lastBytes = currentBytes
-					tracker.lastTime = now
-				}
-			}
-
-			if tracker, ok := a.uploadSpeeds[hash]; ok {
-				elapsed := now.Sub(tracker.lastTime).Seconds()
-				if elapsed > 0 {
-					currentBytes := stats.BytesWrittenData.Int64()
-					bytesDiff := currentBytes - tracker.lastBytes
-					tracker.speed = int64(float64(bytesDiff) / elapsed)
-					tracker.lastBytes = currentBytes
-					tracker.lastTime = now
-				}
-			}
-			a.speedsMutex.Unlock()
-		}
-		a.torrentsMutex.RUnlock()
-
-		// Get current state
-		torrents := a.GetTorrents()
-		stats := a.GetStats()
-
-		data := map[string]interface{}{
-			"torrents": torrents,
-			"stats":    stats,
+	if effective.API != old.API {
+		if a.apiServer != nil {
+			a.apiServer.Close()
 		}
-
-		jsonData, _ := json.Marshal(data)
-		dataStr := string(jsonData)
-
-		// Check if data has meaningfully changed
-		a.updateMutex.Lock()
-		currentHash := fmt.Sprintf("%x", dataStr) // Simple hash
-		timeSinceLastUpdate := time.Since(a.lastUpdateTime)
-
-		// Only emit if data changed OR if it's been more than 5 seconds (for speed updates)
-		if currentHash != a.lastUpdateHash || timeSinceLastUpdate > 5*time.Second {
-			a.lastUpdateHash = currentHash
-			a.lastUpdateTime = time.Now()
-			a.updateMutex.Unlock()
-
-			wailsruntime.EventsEmit(a.ctx, "torrents-update", dataStr)
-		} else {
-			a.updateMutex.Unlock()
+		a.apiServer, err = startRemoteAPI(a.engine, effective.API)
+		if err != nil {
+			return result, fmt.Errorf("settings saved but remote API failed to start: %w", err)
 		}
 	}
-}
-
-func formatBytes(bytes int64) string {
-	const unit = 1024
-	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
-	}
-	div, exp := int64(unit), 0
-	for n := bytes / unit; n >= unit; n /= unit {
-		div *= unit
-		exp++
-	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
-}
-
-func formatSpeed(bytesPerSec int64) string {
-	return formatBytes(bytesPerSec) + "/s"
-}
 
-func formatDuration(d time.Duration) string {
-	if d < time.Minute {
-		return fmt.Sprintf("%ds", int(d.Seconds()))
-	}
-	if d < time.Hour {
-		return fmt.Sprintf("%dm", int(d.Minutes()))
-	}
-	return fmt.Sprintf("%dh %dm", int(d.Hours()), int(d.Minutes())%60)
+	log.Printf("✓ Settings updated")
+	return result, nil
 }
 
 // Wallet functions
@@ -1128,9 +536,25 @@ func (a *App) GetBalance() (float64, error) {
 }
 
 func main() {
-	app := NewApp()
+	headless := flag.Bool("headless", false, "run the torrent engine without the GUI")
+	configPath := flag.String("config", config.DefaultPath(), "path to the settings file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *headless {
+		if err := runHeadless(cfg); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	app := NewApp(cfg)
 
-	err := wails.Run(&options.App{
+	err = wails.Run(&options.App{
 		Title:  "SeedRush - Earn while you seed",
 		Width:  1400,
 		Height: 900,
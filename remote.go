@@ -0,0 +1,31 @@
+package main
+
+import (
+	"torrentflow/api"
+	"torrentflow/config"
+	"torrentflow/engine"
+)
+
+// startRemoteAPI starts the remote control API for eng. An empty address
+// disables the API. Without an explicit token one is generated and saved
+// in the SeedRush directory, where the seedrush CLI will find it.
+func startRemoteAPI(eng *engine.Engine, opts config.APISettings) (*api.Server, error) {
+	if opts.Addr == "" {
+		return nil, nil
+	}
+
+	token := opts.Token
+	if token == "" {
+		var err error
+		token, err = api.LoadOrCreateToken(api.DefaultTokenPath())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	server := api.NewServer(eng, opts.Addr, token)
+	if err := server.Start(); err != nil {
+		return nil, err
+	}
+	return server, nil
+}